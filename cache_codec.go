@@ -0,0 +1,135 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// CacheCodec encodes and decodes the values which are put into an external cache store
+type CacheCodec interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte) (interface{}, error)
+}
+
+var (
+	_ CacheCodec = GobCacheCodec{}
+	_ CacheCodec = &JSONCacheCodec{}
+)
+
+// GobCacheCodec encodes values with encoding/gob. The concrete types of the cached
+// beans should be registered via Engine.GobRegister before they are cached.
+type GobCacheCodec struct{}
+
+// Encode encodes v as gob
+func (GobCacheCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes gob data to the registered type
+func (GobCacheCodec) Decode(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+type jsonCacheEnvelope struct {
+	Type string          `json:"t"`
+	Data json.RawMessage `json:"d"`
+}
+
+// JSONCacheCodec encodes values as JSON with a type name so that they could be
+// decoded to the same type. Types which are not registered are decoded as the
+// default types of encoding/json.
+type JSONCacheCodec struct {
+	types map[string]reflect.Type
+	mutex sync.RWMutex
+}
+
+// NewJSONCacheCodec creates a JSON codec and registers the types of beans
+func NewJSONCacheCodec(beans ...interface{}) *JSONCacheCodec {
+	codec := &JSONCacheCodec{types: make(map[string]reflect.Type)}
+	for _, bean := range beans {
+		codec.Register(bean)
+	}
+	return codec
+}
+
+// Register registers the type of bean
+func (c *JSONCacheCodec) Register(bean interface{}) {
+	t := reflect.TypeOf(bean)
+	c.mutex.Lock()
+	if c.types == nil {
+		c.types = make(map[string]reflect.Type)
+	}
+	c.types[t.String()] = t
+	c.mutex.Unlock()
+}
+
+// Encode encodes v as JSON
+func (c *JSONCacheCodec) Encode(v interface{}) ([]byte, error) {
+	data, err := DefaultJSONHandler.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var env = jsonCacheEnvelope{Data: data}
+	if v != nil {
+		env.Type = reflect.TypeOf(v).String()
+	}
+	return json.Marshal(&env)
+}
+
+// Decode decodes JSON data to the registered type
+func (c *JSONCacheCodec) Decode(data []byte) (interface{}, error) {
+	var env jsonCacheEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, err
+	}
+
+	c.mutex.RLock()
+	t, ok := c.types[env.Type]
+	c.mutex.RUnlock()
+
+	if !ok {
+		switch env.Type {
+		case "string":
+			var s string
+			err := DefaultJSONHandler.Unmarshal(env.Data, &s)
+			return s, err
+		case "":
+			return nil, nil
+		}
+
+		var v interface{}
+		if err := DefaultJSONHandler.Unmarshal(env.Data, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+
+	if t.Kind() == reflect.Ptr {
+		v := reflect.New(t.Elem())
+		if err := DefaultJSONHandler.Unmarshal(env.Data, v.Interface()); err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	}
+
+	v := reflect.New(t)
+	if err := DefaultJSONHandler.Unmarshal(env.Data, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
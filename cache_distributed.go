@@ -0,0 +1,225 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// cache invalidation operations
+const (
+	CacheOpDelIds     = "del_ids"
+	CacheOpDelBean    = "del_bean"
+	CacheOpClearIds   = "clear_ids"
+	CacheOpClearBeans = "clear_beans"
+)
+
+// CacheInvalidation is a message broadcasted to other instances when some cache
+// should be evicted
+type CacheInvalidation struct {
+	Origin string `json:"origin"`
+	Op     string `json:"op"`
+	Table  string `json:"table"`
+	Key    string `json:"key,omitempty"`
+}
+
+// CacheInvalidator broadcasts cache invalidations between engines
+type CacheInvalidator interface {
+	Publish(msg *CacheInvalidation) error
+	Subscribe(handler func(*CacheInvalidation)) (io.Closer, error)
+}
+
+// RedisCacheInvalidator broadcasts cache invalidations via redis pub/sub
+type RedisCacheInvalidator struct {
+	store   *RedisStore
+	channel string
+}
+
+var _ CacheInvalidator = &RedisCacheInvalidator{}
+
+// NewRedisCacheInvalidator creates an invalidator which publishes messages on channel
+func NewRedisCacheInvalidator(store *RedisStore, channel string) *RedisCacheInvalidator {
+	return &RedisCacheInvalidator{store: store, channel: channel}
+}
+
+// Publish publishes an invalidation
+func (r *RedisCacheInvalidator) Publish(msg *CacheInvalidation) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return r.store.Publish(r.channel, data)
+}
+
+// Subscribe subscribes the invalidations
+func (r *RedisCacheInvalidator) Subscribe(handler func(*CacheInvalidation)) (io.Closer, error) {
+	return r.store.Subscribe(r.channel, func(data []byte) {
+		var msg CacheInvalidation
+		if err := json.Unmarshal(data, &msg); err == nil {
+			handler(&msg)
+		}
+	})
+}
+
+// DistributedCacher wraps a cacher and broadcasts all the evictions to the other
+// instances, and evicts the local cacher when receiving evictions from the others.
+// The beans filled by reads are not broadcasted, an update clears the ids and
+// the beans of the table so the other instances will load the updated beans
+// from database again on next query.
+type DistributedCacher struct {
+	cacher      phoenixormcore.Cacher
+	invalidator CacheInvalidator
+	origin      string
+	closer      io.Closer
+	logger      phoenixormcore.ILogger
+	mutex       sync.Mutex
+}
+
+var (
+	_ phoenixormcore.Cacher = &DistributedCacher{}
+	_ CacheStatsProvider    = &DistributedCacher{}
+)
+
+// NewDistributedCacher creates a distributed cacher which wraps cacher
+func NewDistributedCacher(cacher phoenixormcore.Cacher, invalidator CacheInvalidator) (*DistributedCacher, error) {
+	var b = make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	d := &DistributedCacher{
+		cacher:      cacher,
+		invalidator: invalidator,
+		origin:      hex.EncodeToString(b),
+	}
+	closer, err := invalidator.Subscribe(d.handle)
+	if err != nil {
+		return nil, err
+	}
+	d.closer = closer
+	return d, nil
+}
+
+// SetLogger sets the logger which records the failures of broadcasting
+func (d *DistributedCacher) SetLogger(logger phoenixormcore.ILogger) {
+	d.mutex.Lock()
+	d.logger = logger
+	d.mutex.Unlock()
+}
+
+// Cacher returns the wrapped cacher
+func (d *DistributedCacher) Cacher() phoenixormcore.Cacher {
+	return d.cacher
+}
+
+func (d *DistributedCacher) handle(msg *CacheInvalidation) {
+	if msg.Origin == d.origin {
+		return
+	}
+	switch msg.Op {
+	case CacheOpDelIds:
+		d.cacher.DelIds(msg.Table, msg.Key)
+	case CacheOpDelBean:
+		d.cacher.DelBean(msg.Table, msg.Key)
+	case CacheOpClearIds:
+		d.cacher.ClearIds(msg.Table)
+	case CacheOpClearBeans:
+		d.cacher.ClearBeans(msg.Table)
+	}
+}
+
+func (d *DistributedCacher) publish(op, tableName, key string) {
+	err := d.invalidator.Publish(&CacheInvalidation{
+		Origin: d.origin,
+		Op:     op,
+		Table:  tableName,
+		Key:    key,
+	})
+	if err != nil {
+		d.mutex.Lock()
+		logger := d.logger
+		d.mutex.Unlock()
+		if logger != nil {
+			logger.Errorf("[cache] broadcast %s on %s failed: %v", op, tableName, err)
+		}
+	}
+}
+
+// GetIds returns all bean's ids according to sql and parameter from cache
+func (d *DistributedCacher) GetIds(tableName, sql string) interface{} {
+	return d.cacher.GetIds(tableName, sql)
+}
+
+// GetBean returns bean according tableName and id from cache
+func (d *DistributedCacher) GetBean(tableName string, id string) interface{} {
+	return d.cacher.GetBean(tableName, id)
+}
+
+// PutIds puts ids into table
+func (d *DistributedCacher) PutIds(tableName, sql string, ids interface{}) {
+	d.cacher.PutIds(tableName, sql, ids)
+}
+
+// PutBean puts beans into table, it's invoked when the bean is read from database
+// so the other instances are not evicted
+func (d *DistributedCacher) PutBean(tableName string, id string, obj interface{}) {
+	d.cacher.PutBean(tableName, id, obj)
+}
+
+// DelIds deletes ids
+func (d *DistributedCacher) DelIds(tableName, sql string) {
+	d.cacher.DelIds(tableName, sql)
+	d.publish(CacheOpDelIds, tableName, sql)
+}
+
+// DelBean deletes beans in some table
+func (d *DistributedCacher) DelBean(tableName string, id string) {
+	d.cacher.DelBean(tableName, id)
+	d.publish(CacheOpDelBean, tableName, id)
+}
+
+// ClearIds clears all sql-ids mapping on table tableName from cache
+func (d *DistributedCacher) ClearIds(tableName string) {
+	d.cacher.ClearIds(tableName)
+	d.publish(CacheOpClearIds, tableName, "")
+}
+
+// ClearBeans clears all beans in some table
+func (d *DistributedCacher) ClearBeans(tableName string) {
+	d.cacher.ClearBeans(tableName)
+	d.publish(CacheOpClearBeans, tableName, "")
+}
+
+// Close stops receiving the invalidations and closes the wrapped cacher
+func (d *DistributedCacher) Close() error {
+	if err := d.closer.Close(); err != nil {
+		return err
+	}
+	if c, ok := d.cacher.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Stats returns the statistics of the wrapped cacher
+func (d *DistributedCacher) Stats() CacheStats {
+	if p, ok := d.cacher.(CacheStatsProvider); ok {
+		return p.Stats()
+	}
+	return CacheStats{Tables: make(map[string]*CacheTableStats)}
+}
+
+// ResetStats resets the statistics of the wrapped cacher
+func (d *DistributedCacher) ResetStats() {
+	if p, ok := d.cacher.(CacheStatsProvider); ok {
+		p.ResetStats()
+	}
+}
@@ -6,8 +6,12 @@ package xorm
 
 import (
 	"container/list"
+	"context"
 	"fmt"
+	"hash/fnv"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
@@ -15,15 +19,70 @@ import (
 
 // LRUCacher implments cache object facilities
 type LRUCacher struct {
-	idList         *list.List
-	sqlList        *list.List
-	idIndex        map[string]map[string]*list.Element
-	sqlIndex       map[string]map[string]*list.Element
+	shards         []*lruShard
 	store          phoenixormcore.CacheStore
-	mutex          sync.Mutex
 	MaxElementSize int
 	Expired        time.Duration
 	GcInterval     time.Duration
+	// MaxCost is the approximate max bytes of all the cached values, zero means no limit
+	MaxCost int64
+	// CostFunc returns the cost of a cached value, default is the approximate size in bytes
+	CostFunc func(value interface{}) int64
+
+	cost        int64
+	idSize      int64
+	sqlSize     int64
+	metricsHook CacheMetricsHook
+	hookLock    sync.RWMutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	gcOnce sync.Once
+}
+
+// lruShard holds the caches of some tables, all the caches of one table are
+// always in the same shard so that the tables in different shards could be
+// visited concurrently
+type lruShard struct {
+	idList   *list.List
+	sqlList  *list.List
+	idIndex  map[string]map[string]*list.Element
+	sqlIndex map[string]map[string]*list.Element
+	stats    map[string]*CacheTableStats
+	events   []lruEvent
+	mutex    sync.Mutex
+}
+
+// lruEvent is an event recorded while the shard is locked, the metrics hook is
+// invoked with it after the shard is unlocked
+type lruEvent struct {
+	tableName string
+	event     CacheEvent
+}
+
+var (
+	_ phoenixormcore.Cacher = &LRUCacher{}
+	_ CacheStatsProvider    = &LRUCacher{}
+)
+
+// LRUCacherOptions represents the options of LRUCacher
+type LRUCacherOptions struct {
+	// Expired is the duration a cached element will be expired after last visit, default is one hour
+	Expired time.Duration
+	// MaxElementSize is the max elements of ids and of sqls
+	MaxElementSize int
+	// MaxCost is the approximate max bytes of all the cached values, zero means no limit
+	MaxCost int64
+	// CostFunc returns the cost of a cached value, default is the approximate size in bytes
+	CostFunc func(value interface{}) int64
+	// Shards is the number of locks, the tables will be sharded by name, default is 1.
+	// MaxElementSize and MaxCost are the limits of all the shards, the least recently
+	// visited elements of all the shards are evicted first.
+	Shards int
+	// GcInterval is the interval of GC, default is phoenixormcore.CacheGcInterval
+	GcInterval time.Duration
+	// Context stops the GC when it's done
+	Context context.Context
 }
 
 // NewLRUCacher creates a cacher
@@ -33,36 +92,104 @@ func NewLRUCacher(store phoenixormcore.CacheStore, maxElementSize int) *LRUCache
 
 // NewLRUCacher2 creates a cache include different params
 func NewLRUCacher2(store phoenixormcore.CacheStore, expired time.Duration, maxElementSize int) *LRUCacher {
-	cacher := &LRUCacher{store: store, idList: list.New(),
-		sqlList: list.New(), Expired: expired,
-		GcInterval: phoenixormcore.CacheGcInterval, MaxElementSize: maxElementSize,
-		sqlIndex: make(map[string]map[string]*list.Element),
-		idIndex:  make(map[string]map[string]*list.Element),
+	return NewLRUCacherWithOptions(store, LRUCacherOptions{
+		Expired:        expired,
+		MaxElementSize: maxElementSize,
+	})
+}
+
+// NewLRUCacherWithOptions creates a cacher with options
+func NewLRUCacherWithOptions(store phoenixormcore.CacheStore, opts LRUCacherOptions) *LRUCacher {
+	if opts.Expired <= 0 {
+		opts.Expired = 3600 * time.Second
+	}
+	if opts.Shards <= 0 {
+		opts.Shards = 1
+	}
+	if opts.GcInterval <= 0 {
+		opts.GcInterval = phoenixormcore.CacheGcInterval
+	}
+	if opts.Context == nil {
+		opts.Context = context.Background()
 	}
+
+	cacher := &LRUCacher{
+		store:          store,
+		shards:         make([]*lruShard, opts.Shards),
+		Expired:        opts.Expired,
+		GcInterval:     opts.GcInterval,
+		MaxElementSize: opts.MaxElementSize,
+		MaxCost:        opts.MaxCost,
+		CostFunc:       opts.CostFunc,
+	}
+	for i := 0; i < opts.Shards; i++ {
+		cacher.shards[i] = &lruShard{
+			idList:   list.New(),
+			sqlList:  list.New(),
+			sqlIndex: make(map[string]map[string]*list.Element),
+			idIndex:  make(map[string]map[string]*list.Element),
+			stats:    make(map[string]*CacheTableStats),
+		}
+	}
+	cacher.ctx, cacher.cancel = context.WithCancel(opts.Context)
 	cacher.RunGC()
 	return cacher
 }
 
-// RunGC run once every m.GcInterval
+func (m *LRUCacher) shard(tableName string) *lruShard {
+	if len(m.shards) == 1 {
+		return m.shards[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(tableName))
+	return m.shards[h.Sum32()%uint32(len(m.shards))]
+}
+
+// RunGC run once every m.GcInterval until the cacher is closed
 func (m *LRUCacher) RunGC() {
-	time.AfterFunc(m.GcInterval, func() {
-		m.RunGC()
-		m.GC()
+	m.gcOnce.Do(func() {
+		go m.gcLoop()
 	})
 }
 
+func (m *LRUCacher) gcLoop() {
+	for {
+		timer := time.NewTimer(m.GcInterval)
+		select {
+		case <-m.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			m.GC()
+		}
+	}
+}
+
+// Close stops the GC of the cacher
+func (m *LRUCacher) Close() error {
+	m.cancel()
+	return nil
+}
+
 // GC check ids lit and sql list to remove all element expired
 func (m *LRUCacher) GC() {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	for _, s := range m.shards {
+		m.gcShard(s)
+	}
+}
+
+func (m *LRUCacher) gcShard(s *lruShard) {
+	s.mutex.Lock()
+	defer m.unlock(s)
 	var removedNum int
-	for e := m.idList.Front(); e != nil; {
+	for e := s.idList.Front(); e != nil; {
 		if removedNum <= phoenixormcore.CacheGcMaxRemoved &&
 			time.Now().Sub(e.Value.(*idNode).lastVisit) > m.Expired {
 			removedNum++
 			next := e.Next()
 			node := e.Value.(*idNode)
-			m.delBean(node.tbName, node.id)
+			m.delBean(s, node.tbName, node.id)
+			m.record(s, node.tbName, CacheExpiration)
 			e = next
 		} else {
 			break
@@ -70,13 +197,14 @@ func (m *LRUCacher) GC() {
 	}
 
 	removedNum = 0
-	for e := m.sqlList.Front(); e != nil; {
+	for e := s.sqlList.Front(); e != nil; {
 		if removedNum <= phoenixormcore.CacheGcMaxRemoved &&
 			time.Now().Sub(e.Value.(*sqlNode).lastVisit) > m.Expired {
 			removedNum++
 			next := e.Next()
 			node := e.Value.(*sqlNode)
-			m.delIds(node.tbName, node.sql)
+			m.delIds(s, node.tbName, node.sql)
+			m.record(s, node.tbName, CacheExpiration)
 			e = next
 		} else {
 			break
@@ -86,146 +214,245 @@ func (m *LRUCacher) GC() {
 
 // GetIds returns all bean's ids according to sql and parameter from cache
 func (m *LRUCacher) GetIds(tableName, sql string) interface{} {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-	if _, ok := m.sqlIndex[tableName]; !ok {
-		m.sqlIndex[tableName] = make(map[string]*list.Element)
+	s := m.shard(tableName)
+	s.mutex.Lock()
+	defer m.unlock(s)
+	if _, ok := s.sqlIndex[tableName]; !ok {
+		s.sqlIndex[tableName] = make(map[string]*list.Element)
 	}
 	if v, err := m.store.Get(sql); err == nil {
-		if el, ok := m.sqlIndex[tableName][sql]; !ok {
-			el = m.sqlList.PushBack(newSQLNode(tableName, sql))
-			m.sqlIndex[tableName][sql] = el
+		if el, ok := s.sqlIndex[tableName][sql]; !ok {
+			el = s.sqlList.PushBack(newSQLNode(tableName, sql, m.costOf(v)))
+			s.sqlIndex[tableName][sql] = el
+			atomic.AddInt64(&m.sqlSize, 1)
+			m.addCost(el.Value.(*sqlNode).cost)
 		} else {
 			lastTime := el.Value.(*sqlNode).lastVisit
 			// if expired, remove the node and return nil
 			if time.Now().Sub(lastTime) > m.Expired {
-				m.delIds(tableName, sql)
+				m.delIds(s, tableName, sql)
+				m.record(s, tableName, CacheExpiration)
+				m.record(s, tableName, CacheMiss)
 				return nil
 			}
-			m.sqlList.MoveToBack(el)
+			s.sqlList.MoveToBack(el)
 			el.Value.(*sqlNode).lastVisit = time.Now()
 		}
+		m.record(s, tableName, CacheHit)
 		return v
 	}
 
-	m.delIds(tableName, sql)
+	m.delIds(s, tableName, sql)
+	m.record(s, tableName, CacheMiss)
 	return nil
 }
 
 // GetBean returns bean according tableName and id from cache
 func (m *LRUCacher) GetBean(tableName string, id string) interface{} {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-	if _, ok := m.idIndex[tableName]; !ok {
-		m.idIndex[tableName] = make(map[string]*list.Element)
+	s := m.shard(tableName)
+	s.mutex.Lock()
+	defer m.unlock(s)
+	if _, ok := s.idIndex[tableName]; !ok {
+		s.idIndex[tableName] = make(map[string]*list.Element)
 	}
 	tid := genID(tableName, id)
 	if v, err := m.store.Get(tid); err == nil {
-		if el, ok := m.idIndex[tableName][id]; ok {
+		if el, ok := s.idIndex[tableName][id]; ok {
 			lastTime := el.Value.(*idNode).lastVisit
 			// if expired, remove the node and return nil
 			if time.Now().Sub(lastTime) > m.Expired {
-				m.delBean(tableName, id)
+				m.delBean(s, tableName, id)
+				m.record(s, tableName, CacheExpiration)
+				m.record(s, tableName, CacheMiss)
 				return nil
 			}
-			m.idList.MoveToBack(el)
+			s.idList.MoveToBack(el)
 			el.Value.(*idNode).lastVisit = time.Now()
 		} else {
-			el = m.idList.PushBack(newIDNode(tableName, id))
-			m.idIndex[tableName][id] = el
+			el = s.idList.PushBack(newIDNode(tableName, id, m.costOf(v)))
+			s.idIndex[tableName][id] = el
+			atomic.AddInt64(&m.idSize, 1)
+			m.addCost(el.Value.(*idNode).cost)
 		}
+		m.record(s, tableName, CacheHit)
 		return v
 	}
 
 	// store bean is not exist, then remove memory's index
-	m.delBean(tableName, id)
+	m.delBean(s, tableName, id)
+	m.record(s, tableName, CacheMiss)
 	return nil
 }
 
 // clearIds clears all sql-ids mapping on table tableName from cache
-func (m *LRUCacher) clearIds(tableName string) {
-	if tis, ok := m.sqlIndex[tableName]; ok {
+func (m *LRUCacher) clearIds(s *lruShard, tableName string) {
+	if tis, ok := s.sqlIndex[tableName]; ok {
 		for sql, v := range tis {
-			m.sqlList.Remove(v)
+			s.sqlList.Remove(v)
+			m.addCost(-v.Value.(*sqlNode).cost)
 			m.store.Del(sql)
 		}
+		atomic.AddInt64(&m.sqlSize, -int64(len(tis)))
 	}
-	m.sqlIndex[tableName] = make(map[string]*list.Element)
+	s.sqlIndex[tableName] = make(map[string]*list.Element)
 }
 
 // ClearIds clears all sql-ids mapping on table tableName from cache
 func (m *LRUCacher) ClearIds(tableName string) {
-	m.mutex.Lock()
-	m.clearIds(tableName)
-	m.mutex.Unlock()
+	s := m.shard(tableName)
+	s.mutex.Lock()
+	m.clearIds(s, tableName)
+	m.unlock(s)
 }
 
-func (m *LRUCacher) clearBeans(tableName string) {
-	if tis, ok := m.idIndex[tableName]; ok {
+func (m *LRUCacher) clearBeans(s *lruShard, tableName string) {
+	if tis, ok := s.idIndex[tableName]; ok {
 		for id, v := range tis {
-			m.idList.Remove(v)
+			s.idList.Remove(v)
+			m.addCost(-v.Value.(*idNode).cost)
 			tid := genID(tableName, id)
 			m.store.Del(tid)
 		}
+		atomic.AddInt64(&m.idSize, -int64(len(tis)))
 	}
-	m.idIndex[tableName] = make(map[string]*list.Element)
+	s.idIndex[tableName] = make(map[string]*list.Element)
 }
 
 // ClearBeans clears all beans in some table
 func (m *LRUCacher) ClearBeans(tableName string) {
-	m.mutex.Lock()
-	m.clearBeans(tableName)
-	m.mutex.Unlock()
+	s := m.shard(tableName)
+	s.mutex.Lock()
+	m.clearBeans(s, tableName)
+	m.unlock(s)
 }
 
 // PutIds pus ids into table
 func (m *LRUCacher) PutIds(tableName, sql string, ids interface{}) {
-	m.mutex.Lock()
-	if _, ok := m.sqlIndex[tableName]; !ok {
-		m.sqlIndex[tableName] = make(map[string]*list.Element)
+	s := m.shard(tableName)
+	s.mutex.Lock()
+	if _, ok := s.sqlIndex[tableName]; !ok {
+		s.sqlIndex[tableName] = make(map[string]*list.Element)
 	}
-	if el, ok := m.sqlIndex[tableName][sql]; !ok {
-		el = m.sqlList.PushBack(newSQLNode(tableName, sql))
-		m.sqlIndex[tableName][sql] = el
+	cost := m.costOf(ids)
+	if el, ok := s.sqlIndex[tableName][sql]; !ok {
+		el = s.sqlList.PushBack(newSQLNode(tableName, sql, cost))
+		s.sqlIndex[tableName][sql] = el
+		atomic.AddInt64(&m.sqlSize, 1)
 	} else {
-		el.Value.(*sqlNode).lastVisit = time.Now()
+		node := el.Value.(*sqlNode)
+		node.lastVisit = time.Now()
+		m.addCost(-node.cost)
+		node.cost = cost
 	}
+	m.addCost(cost)
 	m.store.Put(sql, ids)
-	if m.sqlList.Len() > m.MaxElementSize {
-		e := m.sqlList.Front()
-		node := e.Value.(*sqlNode)
-		m.delIds(node.tbName, node.sql)
-	}
-	m.mutex.Unlock()
+	m.unlock(s)
+	m.evict()
 }
 
 // PutBean puts beans into table
 func (m *LRUCacher) PutBean(tableName string, id string, obj interface{}) {
-	m.mutex.Lock()
+	s := m.shard(tableName)
+	s.mutex.Lock()
 	var el *list.Element
 	var ok bool
 
-	if el, ok = m.idIndex[tableName][id]; !ok {
-		el = m.idList.PushBack(newIDNode(tableName, id))
-		m.idIndex[tableName][id] = el
+	if _, ok = s.idIndex[tableName]; !ok {
+		s.idIndex[tableName] = make(map[string]*list.Element)
+	}
+	cost := m.costOf(obj)
+	if el, ok = s.idIndex[tableName][id]; !ok {
+		el = s.idList.PushBack(newIDNode(tableName, id, cost))
+		s.idIndex[tableName][id] = el
+		atomic.AddInt64(&m.idSize, 1)
 	} else {
-		el.Value.(*idNode).lastVisit = time.Now()
+		node := el.Value.(*idNode)
+		node.lastVisit = time.Now()
+		m.addCost(-node.cost)
+		node.cost = cost
 	}
+	m.addCost(cost)
 
 	m.store.Put(genID(tableName, id), obj)
-	if m.idList.Len() > m.MaxElementSize {
-		e := m.idList.Front()
-		node := e.Value.(*idNode)
-		m.delBean(node.tbName, node.id)
+	m.unlock(s)
+	m.evict()
+}
+
+// evict removes the least recently visited elements of all the shards until the
+// ids, the sqls and the cost are under MaxElementSize and MaxCost, the caller
+// should not hold any lock of the shards
+func (m *LRUCacher) evict() {
+	for {
+		var maxSize = int64(m.MaxElementSize)
+		var overCost = m.MaxCost > 0 && atomic.LoadInt64(&m.cost) > m.MaxCost
+		var ids = overCost || (maxSize > 0 && atomic.LoadInt64(&m.idSize) > maxSize)
+		var sqls = overCost || (maxSize > 0 && atomic.LoadInt64(&m.sqlSize) > maxSize)
+		if !ids && !sqls {
+			return
+		}
+
+		// the shards are locked one by one so the oldest front may be visited
+		// before it's evicted, then the new front of the shard is evicted
+		var oldest *lruShard
+		var oldestVisit time.Time
+		for _, s := range m.shards {
+			s.mutex.Lock()
+			if front := s.front(ids, sqls); front != nil {
+				if visit := lastVisitOf(front); oldest == nil || visit.Before(oldestVisit) {
+					oldest, oldestVisit = s, visit
+				}
+			}
+			s.mutex.Unlock()
+		}
+		if oldest == nil {
+			return
+		}
+
+		oldest.mutex.Lock()
+		if front := oldest.front(ids, sqls); front != nil {
+			switch node := front.Value.(type) {
+			case *idNode:
+				m.delBean(oldest, node.tbName, node.id)
+				m.record(oldest, node.tbName, CacheEviction)
+			case *sqlNode:
+				m.delIds(oldest, node.tbName, node.sql)
+				m.record(oldest, node.tbName, CacheEviction)
+			}
+		}
+		m.unlock(oldest)
+	}
+}
+
+// front returns the least recently visited element of the ids or of the sqls of
+// the shard, the caller should hold the lock of the shard
+func (s *lruShard) front(ids, sqls bool) *list.Element {
+	var idFront, sqlFront *list.Element
+	if ids {
+		idFront = s.idList.Front()
+	}
+	if sqls {
+		sqlFront = s.sqlList.Front()
+	}
+	if sqlFront == nil || (idFront != nil && lastVisitOf(idFront).Before(lastVisitOf(sqlFront))) {
+		return idFront
 	}
-	m.mutex.Unlock()
+	return sqlFront
 }
 
-func (m *LRUCacher) delIds(tableName, sql string) {
-	if _, ok := m.sqlIndex[tableName]; ok {
-		if el, ok := m.sqlIndex[tableName][sql]; ok {
-			delete(m.sqlIndex[tableName], sql)
-			m.sqlList.Remove(el)
+func lastVisitOf(el *list.Element) time.Time {
+	if node, ok := el.Value.(*idNode); ok {
+		return node.lastVisit
+	}
+	return el.Value.(*sqlNode).lastVisit
+}
+
+func (m *LRUCacher) delIds(s *lruShard, tableName, sql string) {
+	if _, ok := s.sqlIndex[tableName]; ok {
+		if el, ok := s.sqlIndex[tableName][sql]; ok {
+			delete(s.sqlIndex[tableName], sql)
+			s.sqlList.Remove(el)
+			atomic.AddInt64(&m.sqlSize, -1)
+			m.addCost(-el.Value.(*sqlNode).cost)
 		}
 	}
 	m.store.Del(sql)
@@ -233,38 +460,196 @@ func (m *LRUCacher) delIds(tableName, sql string) {
 
 // DelIds deletes ids
 func (m *LRUCacher) DelIds(tableName, sql string) {
-	m.mutex.Lock()
-	m.delIds(tableName, sql)
-	m.mutex.Unlock()
+	s := m.shard(tableName)
+	s.mutex.Lock()
+	m.delIds(s, tableName, sql)
+	m.unlock(s)
 }
 
-func (m *LRUCacher) delBean(tableName string, id string) {
+func (m *LRUCacher) delBean(s *lruShard, tableName string, id string) {
 	tid := genID(tableName, id)
-	if el, ok := m.idIndex[tableName][id]; ok {
-		delete(m.idIndex[tableName], id)
-		m.idList.Remove(el)
-		m.clearIds(tableName)
+	if el, ok := s.idIndex[tableName][id]; ok {
+		delete(s.idIndex[tableName], id)
+		s.idList.Remove(el)
+		atomic.AddInt64(&m.idSize, -1)
+		m.addCost(-el.Value.(*idNode).cost)
+		m.clearIds(s, tableName)
 	}
 	m.store.Del(tid)
 }
 
 // DelBean deletes beans in some table
 func (m *LRUCacher) DelBean(tableName string, id string) {
-	m.mutex.Lock()
-	m.delBean(tableName, id)
-	m.mutex.Unlock()
+	s := m.shard(tableName)
+	s.mutex.Lock()
+	m.delBean(s, tableName, id)
+	m.unlock(s)
+}
+
+func (m *LRUCacher) costOf(value interface{}) int64 {
+	if m.MaxCost <= 0 {
+		return 0
+	}
+	if m.CostFunc != nil {
+		return m.CostFunc(value)
+	}
+	return sizeOfValue(reflect.ValueOf(value), 0)
+}
+
+// addCost adds the total cost of the cached values
+func (m *LRUCacher) addCost(cost int64) {
+	if cost != 0 {
+		atomic.AddInt64(&m.cost, cost)
+	}
+}
+
+// Cost returns the total cost of the cached values
+func (m *LRUCacher) Cost() int64 {
+	return atomic.LoadInt64(&m.cost)
+}
+
+// sizeOfValue returns the approximate size in bytes of a value
+func sizeOfValue(v reflect.Value, depth int) int64 {
+	if !v.IsValid() {
+		return 0
+	}
+	if depth > 8 {
+		return int64(v.Type().Size())
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return int64(v.Type().Size())
+		}
+		return int64(v.Type().Size()) + sizeOfValue(v.Elem(), depth+1)
+	case reflect.String:
+		return int64(v.Type().Size()) + int64(v.Len())
+	case reflect.Slice, reflect.Array:
+		size := int64(v.Type().Size())
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return size + int64(v.Len())
+		}
+		for i := 0; i < v.Len(); i++ {
+			size += sizeOfValue(v.Index(i), depth+1)
+		}
+		return size
+	case reflect.Map:
+		size := int64(v.Type().Size())
+		for _, key := range v.MapKeys() {
+			size += sizeOfValue(key, depth+1) + sizeOfValue(v.MapIndex(key), depth+1)
+		}
+		return size
+	case reflect.Struct:
+		var size int64
+		for i := 0; i < v.NumField(); i++ {
+			size += sizeOfValue(v.Field(i), depth+1)
+		}
+		if size == 0 {
+			size = int64(v.Type().Size())
+		}
+		return size
+	}
+	return int64(v.Type().Size())
+}
+
+// record records an event of the table, the caller should hold the lock of the
+// shard and release it by unlock so that the metrics hook will be invoked
+func (m *LRUCacher) record(s *lruShard, tableName string, event CacheEvent) {
+	stats, ok := s.stats[tableName]
+	if !ok {
+		stats = &CacheTableStats{}
+		s.stats[tableName] = stats
+	}
+	stats.incr(event)
+	s.events = append(s.events, lruEvent{tableName: tableName, event: event})
+}
+
+// unlock unlocks the shard and then invokes the metrics hook with the events
+// recorded while the shard was locked
+func (m *LRUCacher) unlock(s *lruShard) {
+	events := s.events
+	s.events = nil
+	s.mutex.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	m.hookLock.RLock()
+	hook := m.metricsHook
+	m.hookLock.RUnlock()
+	if hook != nil {
+		for _, e := range events {
+			hook(e.tableName, e.event)
+		}
+	}
+}
+
+// SetMetricsHook sets a hook which will be invoked on every cache event
+func (m *LRUCacher) SetMetricsHook(hook CacheMetricsHook) {
+	m.hookLock.Lock()
+	m.metricsHook = hook
+	m.hookLock.Unlock()
+}
+
+// Stats returns the statistics of the cacher
+func (m *LRUCacher) Stats() CacheStats {
+	var stats = CacheStats{Tables: make(map[string]*CacheTableStats)}
+	var tableStats = func(tableName string) *CacheTableStats {
+		t, ok := stats.Tables[tableName]
+		if !ok {
+			t = &CacheTableStats{}
+			stats.Tables[tableName] = t
+		}
+		return t
+	}
+
+	for _, s := range m.shards {
+		s.mutex.Lock()
+		for tableName, counter := range s.stats {
+			t := tableStats(tableName)
+			*t = *counter
+		}
+		for tableName, ids := range s.idIndex {
+			if len(ids) > 0 {
+				tableStats(tableName).IDSize = len(ids)
+			}
+		}
+		for tableName, sqls := range s.sqlIndex {
+			if len(sqls) > 0 {
+				tableStats(tableName).SQLSize = len(sqls)
+			}
+		}
+		s.mutex.Unlock()
+	}
+
+	for _, t := range stats.Tables {
+		stats.add(t)
+	}
+	return stats
+}
+
+// ResetStats resets the counters of the cacher
+func (m *LRUCacher) ResetStats() {
+	for _, s := range m.shards {
+		s.mutex.Lock()
+		s.stats = make(map[string]*CacheTableStats)
+		s.mutex.Unlock()
+	}
 }
 
 type idNode struct {
 	tbName    string
 	id        string
 	lastVisit time.Time
+	cost      int64
 }
 
 type sqlNode struct {
 	tbName    string
 	sql       string
 	lastVisit time.Time
+	cost      int64
 }
 
 func genSQLKey(sql string, args interface{}) string {
@@ -275,10 +660,10 @@ func genID(prefix string, id string) string {
 	return fmt.Sprintf("%v-%v", prefix, id)
 }
 
-func newIDNode(tbName string, id string) *idNode {
-	return &idNode{tbName, id, time.Now()}
+func newIDNode(tbName string, id string, cost int64) *idNode {
+	return &idNode{tbName, id, time.Now(), cost}
 }
 
-func newSQLNode(tbName, sql string) *sqlNode {
-	return &sqlNode{tbName, sql, time.Now()}
+func newSQLNode(tbName, sql string, cost int64) *sqlNode {
+	return &sqlNode{tbName, sql, time.Now(), cost}
 }
@@ -5,7 +5,13 @@
 package xorm
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
@@ -50,3 +56,198 @@ func TestLRUCache(t *testing.T) {
 		assert.Nil(t, obj4)
 	}
 }
+
+func TestLRUCacheStats(t *testing.T) {
+	store := NewMemoryStore()
+	cacher := NewLRUCacher(store, 2)
+
+	var events = make(map[CacheEvent]int)
+	cacher.SetMetricsHook(func(tableName string, event CacheEvent) {
+		events[event]++
+	})
+
+	tableName := "cache_stats_object"
+	assert.Nil(t, cacher.GetBean(tableName, "1"))
+	cacher.PutBean(tableName, "1", "bean1")
+	assert.EqualValues(t, "bean1", cacher.GetBean(tableName, "1"))
+	cacher.PutBean(tableName, "2", "bean2")
+	cacher.PutBean(tableName, "3", "bean3")
+
+	cacher.PutIds(tableName, "select * from cache_stats_object", "ids")
+	assert.EqualValues(t, "ids", cacher.GetIds(tableName, "select * from cache_stats_object"))
+
+	stats := cacher.Stats()
+	assert.EqualValues(t, 2, stats.Hits)
+	assert.EqualValues(t, 1, stats.Misses)
+	assert.EqualValues(t, 1, stats.Evictions)
+	assert.EqualValues(t, 2, stats.IDSize)
+	assert.EqualValues(t, 1, stats.SQLSize)
+	assert.EqualValues(t, 2.0/3.0, stats.HitRatio())
+	assert.NotNil(t, stats.Tables[tableName])
+	assert.EqualValues(t, 2, stats.Tables[tableName].Hits)
+	assert.EqualValues(t, 2, events[CacheHit])
+	assert.EqualValues(t, 1, events[CacheMiss])
+	assert.EqualValues(t, 1, events[CacheEviction])
+
+	cacher.ResetStats()
+	stats = cacher.Stats()
+	assert.EqualValues(t, 0, stats.Hits)
+	assert.EqualValues(t, 0, stats.Misses)
+	assert.EqualValues(t, 2, stats.IDSize)
+}
+
+func TestLRUCacheClose(t *testing.T) {
+	cacher := NewLRUCacherWithOptions(NewMemoryStore(), LRUCacherOptions{
+		Expired:        10 * time.Millisecond,
+		MaxElementSize: 100,
+		GcInterval:     20 * time.Millisecond,
+	})
+
+	var expirations int64
+	cacher.SetMetricsHook(func(tableName string, event CacheEvent) {
+		if event == CacheExpiration {
+			atomic.AddInt64(&expirations, 1)
+		}
+	})
+
+	tableName := "cache_close_object"
+	cacher.PutBean(tableName, "1", "bean1")
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&expirations) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, cacher.Close())
+	time.Sleep(30 * time.Millisecond)
+
+	cacher.PutBean(tableName, "2", "bean2")
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, 1, atomic.LoadInt64(&expirations))
+	assert.EqualValues(t, 1, cacher.Stats().IDSize)
+}
+
+func TestLRUCacheShards(t *testing.T) {
+	cacher := NewLRUCacherWithOptions(NewMemoryStore(), LRUCacherOptions{
+		MaxElementSize: 10000,
+		Shards:         8,
+	})
+	defer cacher.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tableName := fmt.Sprintf("cache_shard_object%d", i)
+			for j := 0; j < 100; j++ {
+				id := strconv.Itoa(j)
+				cacher.PutBean(tableName, id, j)
+				assert.EqualValues(t, j, cacher.GetBean(tableName, id))
+			}
+			cacher.ClearBeans(tableName)
+		}(i)
+	}
+	wg.Wait()
+
+	stats := cacher.Stats()
+	assert.EqualValues(t, 16*100, stats.Hits)
+	assert.EqualValues(t, 0, stats.IDSize)
+	assert.EqualValues(t, 16, len(stats.Tables))
+}
+
+func TestLRUCacheMaxCost(t *testing.T) {
+	cacher := NewLRUCacherWithOptions(NewMemoryStore(), LRUCacherOptions{
+		MaxElementSize: 10000,
+		MaxCost:        100,
+		CostFunc: func(value interface{}) int64 {
+			return int64(len(value.(string)))
+		},
+	})
+	defer cacher.Close()
+
+	tableName := "cache_cost_object"
+	for i := 0; i < 10; i++ {
+		cacher.PutBean(tableName, strconv.Itoa(i), strings.Repeat("a", 30))
+	}
+
+	assert.True(t, cacher.Cost() <= 100)
+	stats := cacher.Stats()
+	assert.EqualValues(t, 3, stats.IDSize)
+	assert.EqualValues(t, 7, stats.Evictions)
+	assert.Nil(t, cacher.GetBean(tableName, "0"))
+	assert.NotNil(t, cacher.GetBean(tableName, "9"))
+
+	cacher.DelBean(tableName, "9")
+	assert.EqualValues(t, 60, cacher.Cost())
+}
+
+func TestLRUCacheShardLimits(t *testing.T) {
+	cacher := NewLRUCacherWithOptions(NewMemoryStore(), LRUCacherOptions{
+		MaxElementSize: 8,
+		Shards:         4,
+	})
+	defer cacher.Close()
+
+	// the max elements are the limit of all the shards, one table could use all of them
+	for j := 0; j < 8; j++ {
+		cacher.PutBean("cache_shard_limit_hot", strconv.Itoa(j), j)
+	}
+	assert.EqualValues(t, 8, cacher.Stats().IDSize)
+
+	for i := 0; i < 8; i++ {
+		tableName := fmt.Sprintf("cache_shard_limit_object%d", i)
+		for j := 0; j < 10; j++ {
+			cacher.PutBean(tableName, strconv.Itoa(j), j)
+		}
+	}
+	assert.EqualValues(t, 8, cacher.Stats().IDSize)
+	// the least recently visited beans of all the shards are evicted
+	assert.Nil(t, cacher.GetBean("cache_shard_limit_hot", "7"))
+	assert.EqualValues(t, 9, cacher.GetBean("cache_shard_limit_object7", "9"))
+
+	cacher = NewLRUCacherWithOptions(NewMemoryStore(), LRUCacherOptions{
+		MaxElementSize: 10000,
+		MaxCost:        400,
+		CostFunc: func(value interface{}) int64 {
+			return int64(len(value.(string)))
+		},
+		Shards: 4,
+	})
+	defer cacher.Close()
+
+	for j := 0; j < 12; j++ {
+		cacher.PutBean("cache_shard_cost_hot", strconv.Itoa(j), strings.Repeat("a", 30))
+	}
+	assert.EqualValues(t, 360, cacher.Cost())
+
+	for i := 0; i < 8; i++ {
+		tableName := fmt.Sprintf("cache_shard_cost_object%d", i)
+		for j := 0; j < 10; j++ {
+			cacher.PutBean(tableName, strconv.Itoa(j), strings.Repeat("a", 30))
+		}
+		assert.NotNil(t, cacher.GetBean(tableName, "9"))
+	}
+	assert.True(t, cacher.Cost() <= 400)
+}
+
+func TestLRUCacheMetricsHookUnlocked(t *testing.T) {
+	cacher := NewLRUCacherWithOptions(NewMemoryStore(), LRUCacherOptions{
+		MaxElementSize: 1,
+		Shards:         2,
+	})
+	defer cacher.Close()
+
+	// the hook is invoked after the shards are unlocked so it could visit the cacher
+	var evictions int
+	cacher.SetMetricsHook(func(tableName string, event CacheEvent) {
+		if event == CacheEviction {
+			evictions++
+			assert.EqualValues(t, 1, cacher.Stats().IDSize)
+		}
+	})
+
+	tableName := "cache_hook_object"
+	cacher.PutBean(tableName, "1", "bean1")
+	cacher.PutBean(tableName, "2", "bean2")
+	assert.EqualValues(t, 1, evictions)
+	assert.EqualValues(t, "bean2", cacher.GetBean(tableName, "2"))
+}
@@ -0,0 +1,419 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+var _ phoenixormcore.CacheStore = &RedisStore{}
+
+// RedisOptions represents the options to connect a server which speaks the redis protocol
+type RedisOptions struct {
+	Addr     string
+	Password string
+	DB       int
+	// Prefix will be prepended to all the keys
+	Prefix string
+	// Expiration is the TTL of every key, zero means never expire
+	Expiration  time.Duration
+	DialTimeout time.Duration
+	// ReadTimeout and WriteTimeout are the deadlines of reading the reply and
+	// writing the command of every command, default is 3 seconds, negative
+	// means no deadline
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// PoolSize is the max idle connections, default is 4
+	PoolSize int
+	// Codec encodes the cached values, default is GobCacheCodec
+	Codec CacheCodec
+}
+
+// RedisStore represents a cache store on a server which speaks the redis protocol
+type RedisStore struct {
+	opts  RedisOptions
+	conns chan *redisConn
+}
+
+// NewRedisStore creates a store on a redis compatible server
+func NewRedisStore(opts RedisOptions) *RedisStore {
+	if opts.Codec == nil {
+		opts.Codec = GobCacheCodec{}
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 4
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = 3 * time.Second
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = 3 * time.Second
+	}
+	return &RedisStore{
+		opts:  opts,
+		conns: make(chan *redisConn, opts.PoolSize),
+	}
+}
+
+func (s *RedisStore) key(key string) string {
+	return s.opts.Prefix + key
+}
+
+func (s *RedisStore) getConn() (*redisConn, error) {
+	select {
+	case conn := <-s.conns:
+		return conn, nil
+	default:
+		return dialRedis(&s.opts)
+	}
+}
+
+func (s *RedisStore) putConn(conn *redisConn, err error) {
+	if err != nil {
+		if _, ok := err.(redisError); !ok {
+			conn.Close()
+			return
+		}
+	}
+	select {
+	case s.conns <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func (s *RedisStore) do(args ...string) (interface{}, error) {
+	conn, err := s.getConn()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(args...)
+	s.putConn(conn, err)
+	return reply, err
+}
+
+// Put puts object into store
+func (s *RedisStore) Put(key string, value interface{}) error {
+	data, err := s.opts.Codec.Encode(value)
+	if err != nil {
+		return err
+	}
+	if s.opts.Expiration > 0 {
+		_, err = s.do("SET", s.key(key), string(data), "PX", strconv.FormatInt(int64(s.opts.Expiration/time.Millisecond), 10))
+	} else {
+		_, err = s.do("SET", s.key(key), string(data))
+	}
+	return err
+}
+
+// Get gets object from store
+func (s *RedisStore) Get(key string) (interface{}, error) {
+	reply, err := s.do("GET", s.key(key))
+	if err != nil {
+		return nil, err
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, ErrNotExist
+	}
+	return s.opts.Codec.Decode(data)
+}
+
+// Del deletes object
+func (s *RedisStore) Del(key string) error {
+	_, err := s.do("DEL", s.key(key))
+	return err
+}
+
+// Publish publishes a message on the channel
+func (s *RedisStore) Publish(channel string, message []byte) error {
+	_, err := s.do("PUBLISH", channel, string(message))
+	return err
+}
+
+// Subscribe subscribes the channel on a dedicated connection, handler will be invoked
+// for every message until the returned closer is closed. The connection will be
+// re-established if it is broken.
+func (s *RedisStore) Subscribe(channel string, handler func([]byte)) (io.Closer, error) {
+	conn, err := dialRedis(&s.opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.send("SUBSCRIBE", channel); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err = conn.receive(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// the messages are waited without deadline
+	conn.readTimeout = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &redisSubscription{conn: conn, ctx: ctx, cancel: cancel}
+	go sub.loop(s, channel, handler)
+	return sub, nil
+}
+
+// Close closes all the idle connections
+func (s *RedisStore) Close() error {
+	for {
+		select {
+		case conn := <-s.conns:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+type redisSubscription struct {
+	conn   *redisConn
+	mutex  sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (sub *redisSubscription) loop(s *RedisStore, channel string, handler func([]byte)) {
+	for {
+		sub.mutex.Lock()
+		conn := sub.conn
+		sub.mutex.Unlock()
+
+		reply, err := conn.receive()
+		if err != nil {
+			conn.Close()
+			if !sub.resubscribe(s, channel) {
+				return
+			}
+			continue
+		}
+
+		// a message is replied as ["message", channel, payload]
+		vals, ok := reply.([]interface{})
+		if !ok || len(vals) != 3 {
+			continue
+		}
+		if kind, ok := vals[0].([]byte); !ok || string(kind) != "message" {
+			continue
+		}
+		if payload, ok := vals[2].([]byte); ok {
+			handler(payload)
+		}
+	}
+}
+
+func (sub *redisSubscription) resubscribe(s *RedisStore, channel string) bool {
+	for {
+		select {
+		case <-sub.ctx.Done():
+			return false
+		case <-time.After(time.Second):
+		}
+
+		conn, err := dialRedis(&s.opts)
+		if err != nil {
+			continue
+		}
+		if err = conn.send("SUBSCRIBE", channel); err == nil {
+			_, err = conn.receive()
+		}
+		if err != nil {
+			conn.Close()
+			continue
+		}
+		conn.readTimeout = 0
+
+		sub.mutex.Lock()
+		sub.conn = conn
+		sub.mutex.Unlock()
+
+		select {
+		case <-sub.ctx.Done():
+			conn.Close()
+			return false
+		default:
+			return true
+		}
+	}
+}
+
+// Close stops the subscription
+func (sub *redisSubscription) Close() error {
+	sub.cancel()
+	sub.mutex.Lock()
+	sub.conn.Close()
+	sub.mutex.Unlock()
+	return nil
+}
+
+// redisError is an error replied by server, the connection is still usable
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisConn is a connection speaks RESP, the redis serialization protocol
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+func dialRedis(opts *RedisOptions) (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", opts.Addr, opts.DialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{
+		conn:         conn,
+		r:            bufio.NewReader(conn),
+		w:            bufio.NewWriter(conn),
+		readTimeout:  opts.ReadTimeout,
+		writeTimeout: opts.WriteTimeout,
+	}
+	if opts.Password != "" {
+		if _, err = c.do("AUTH", opts.Password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if opts.DB != 0 {
+		if _, err = c.do("SELECT", strconv.Itoa(opts.DB)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *redisConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.receive()
+}
+
+// send writes the command, a hung server fails it after the write timeout
+func (c *redisConn) send(args ...string) error {
+	if err := c.conn.SetWriteDeadline(deadlineOf(c.writeTimeout)); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.w, "*%d\r\n", len(args)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if _, err := fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg); err != nil {
+			return err
+		}
+	}
+	return c.w.Flush()
+}
+
+// receive reads a reply, a hung server fails it after the read timeout
+func (c *redisConn) receive() (interface{}, error) {
+	if err := c.conn.SetReadDeadline(deadlineOf(c.readTimeout)); err != nil {
+		return nil, err
+	}
+	reply, err := readRedisReply(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(redisError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// deadlineOf returns the deadline after timeout, zero time means no deadline
+func deadlineOf(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+func readRedisLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.New("redis: invalid reply line")
+	}
+	return line[:len(line)-2], nil
+}
+
+// readRedisReply reads a reply, the simple string will be returned as string,
+// the bulk string as []byte, the integer as int64 and the array as []interface{}
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readRedisLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		vals := make([]interface{}, n)
+		for i := 0; i < n; i++ {
+			if vals[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return vals, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", line[0])
+}
@@ -0,0 +1,276 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bufio"
+	"encoding/gob"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedisServer is an in-process server which speaks a subset of the redis protocol
+type fakeRedisServer struct {
+	listener    net.Listener
+	mutex       sync.Mutex
+	data        map[string]string
+	expires     map[string]time.Time
+	subscribers map[string][]*fakeRedisClient
+}
+
+type fakeRedisClient struct {
+	conn  net.Conn
+	mutex sync.Mutex
+}
+
+func (c *fakeRedisClient) write(s string) {
+	c.mutex.Lock()
+	c.conn.Write([]byte(s))
+	c.mutex.Unlock()
+}
+
+func newFakeRedisServer(t *testing.T) *fakeRedisServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	s := &fakeRedisServer{
+		listener:    l,
+		data:        make(map[string]string),
+		expires:     make(map[string]time.Time),
+		subscribers: make(map[string][]*fakeRedisClient),
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedisServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeRedisServer) Close() {
+	s.listener.Close()
+}
+
+func bulkString(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func (s *fakeRedisServer) serve(conn net.Conn) {
+	defer conn.Close()
+	client := &fakeRedisClient{conn: conn}
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := readRedisReply(r)
+		if err != nil {
+			return
+		}
+		vals, ok := reply.([]interface{})
+		if !ok || len(vals) == 0 {
+			return
+		}
+		var args = make([]string, len(vals))
+		for i, v := range vals {
+			args[i] = string(v.([]byte))
+		}
+
+		s.mutex.Lock()
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			client.write("+PONG\r\n")
+		case "SET":
+			s.data[args[1]] = args[2]
+			delete(s.expires, args[1])
+			if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+				ms, _ := strconv.Atoi(args[4])
+				s.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+			client.write("+OK\r\n")
+		case "GET":
+			v, ok := s.data[args[1]]
+			if exp, has := s.expires[args[1]]; has && time.Now().After(exp) {
+				delete(s.data, args[1])
+				delete(s.expires, args[1])
+				ok = false
+			}
+			if ok {
+				client.write(bulkString(v))
+			} else {
+				client.write("$-1\r\n")
+			}
+		case "DEL":
+			_, ok := s.data[args[1]]
+			delete(s.data, args[1])
+			delete(s.expires, args[1])
+			if ok {
+				client.write(":1\r\n")
+			} else {
+				client.write(":0\r\n")
+			}
+		case "SUBSCRIBE":
+			s.subscribers[args[1]] = append(s.subscribers[args[1]], client)
+			client.write("*3\r\n" + bulkString("subscribe") + bulkString(args[1]) + ":1\r\n")
+		case "PUBLISH":
+			subs := s.subscribers[args[1]]
+			for _, sub := range subs {
+				sub.write("*3\r\n" + bulkString("message") + bulkString(args[1]) + bulkString(args[2]))
+			}
+			client.write(":" + strconv.Itoa(len(subs)) + "\r\n")
+		default:
+			client.write("-ERR unknown command '" + args[0] + "'\r\n")
+		}
+		s.mutex.Unlock()
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	server := newFakeRedisServer(t)
+	defer server.Close()
+
+	type RedisCacheObject struct {
+		Id   int64
+		Name string
+	}
+
+	for _, codec := range []CacheCodec{GobCacheCodec{}, NewJSONCacheCodec(new(RedisCacheObject))} {
+		store := NewRedisStore(RedisOptions{
+			Addr:   server.Addr(),
+			Prefix: "xorm:",
+			Codec:  codec,
+		})
+
+		if _, ok := codec.(GobCacheCodec); ok {
+			gob.Register(new(RedisCacheObject))
+		}
+
+		assert.NoError(t, store.Put("a", "b"))
+		v, err := store.Get("a")
+		assert.NoError(t, err)
+		assert.EqualValues(t, "b", v)
+
+		var obj = &RedisCacheObject{Id: 1, Name: "xorm"}
+		assert.NoError(t, store.Put("obj", obj))
+		v, err = store.Get("obj")
+		assert.NoError(t, err)
+		assert.EqualValues(t, obj, v)
+
+		assert.NoError(t, store.Del("a"))
+		_, err = store.Get("a")
+		assert.EqualValues(t, ErrNotExist, err)
+
+		assert.NoError(t, store.Close())
+	}
+}
+
+func TestRedisStoreExpiration(t *testing.T) {
+	server := newFakeRedisServer(t)
+	defer server.Close()
+
+	store := NewRedisStore(RedisOptions{
+		Addr:       server.Addr(),
+		Expiration: 50 * time.Millisecond,
+	})
+	defer store.Close()
+
+	assert.NoError(t, store.Put("a", "b"))
+	v, err := store.Get("a")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "b", v)
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = store.Get("a")
+	assert.EqualValues(t, ErrNotExist, err)
+}
+
+func TestDistributedCacher(t *testing.T) {
+	server := newFakeRedisServer(t)
+	defer server.Close()
+
+	store := NewRedisStore(RedisOptions{Addr: server.Addr()})
+	defer store.Close()
+
+	var newCacher = func() (*DistributedCacher, *LRUCacher) {
+		local := NewLRUCacher(NewMemoryStore(), 1000)
+		cacher, err := NewDistributedCacher(local, NewRedisCacheInvalidator(store, "xorm:invalidation"))
+		assert.NoError(t, err)
+		return cacher, local
+	}
+
+	cacher1, local1 := newCacher()
+	defer cacher1.Close()
+	cacher2, local2 := newCacher()
+	defer cacher2.Close()
+
+	tableName := "distributed_object"
+	cacher1.PutBean(tableName, "1", "bean1")
+	cacher1.PutIds(tableName, "select * from distributed_object", "ids1")
+	cacher2.PutBean(tableName, "2", "bean2")
+	cacher2.PutIds(tableName, "select * from distributed_object", "ids2")
+
+	cacher1.ClearIds(tableName)
+	assert.Eventually(t, func() bool {
+		return local2.GetIds(tableName, "select * from distributed_object") == nil
+	}, time.Second, 10*time.Millisecond)
+
+	cacher1.DelBean(tableName, "2")
+	assert.Eventually(t, func() bool {
+		return local2.GetBean(tableName, "2") == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// bean put by itself should not be evicted by its own broadcast
+	assert.EqualValues(t, "bean1", local1.GetBean(tableName, "1"))
+
+	// the beans filled by reads are not broadcasted
+	cacher2.PutBean(tableName, "1", "bean1")
+	time.Sleep(50 * time.Millisecond)
+	assert.EqualValues(t, "bean1", local1.GetBean(tableName, "1"))
+
+	// an update clears the beans of the table on all the instances
+	cacher2.ClearBeans(tableName)
+	assert.Eventually(t, func() bool {
+		return local1.GetBean(tableName, "1") == nil
+	}, time.Second, 10*time.Millisecond)
+	assert.Nil(t, local2.GetBean(tableName, "1"))
+}
+
+func TestRedisStoreReadTimeout(t *testing.T) {
+	// the server accepts the connections but never replies
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	store := NewRedisStore(RedisOptions{
+		Addr:        listener.Addr().String(),
+		ReadTimeout: 50 * time.Millisecond,
+	})
+	defer store.Close()
+
+	var start = time.Now()
+	_, err = store.Get("a")
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
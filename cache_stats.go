@@ -0,0 +1,142 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+// CacheEvent represents an event happened on a cacher
+type CacheEvent int
+
+// cache events
+const (
+	CacheHit CacheEvent = iota
+	CacheMiss
+	CacheEviction
+	CacheExpiration
+)
+
+func (e CacheEvent) String() string {
+	switch e {
+	case CacheHit:
+		return "hit"
+	case CacheMiss:
+		return "miss"
+	case CacheEviction:
+		return "eviction"
+	case CacheExpiration:
+		return "expiration"
+	}
+	return "unknown"
+}
+
+// CacheMetricsHook will be invoked when an event happened on table of a cacher,
+// it should return quickly and should not invoke the cacher.
+type CacheMetricsHook func(tableName string, event CacheEvent)
+
+// CacheTableStats represents the statistics of a table in cache
+type CacheTableStats struct {
+	Hits        int64
+	Misses      int64
+	Evictions   int64
+	Expirations int64
+	IDSize      int
+	SQLSize     int
+}
+
+func (s *CacheTableStats) add(other *CacheTableStats) {
+	s.Hits += other.Hits
+	s.Misses += other.Misses
+	s.Evictions += other.Evictions
+	s.Expirations += other.Expirations
+	s.IDSize += other.IDSize
+	s.SQLSize += other.SQLSize
+}
+
+func (s *CacheTableStats) incr(event CacheEvent) {
+	switch event {
+	case CacheHit:
+		s.Hits++
+	case CacheMiss:
+		s.Misses++
+	case CacheEviction:
+		s.Evictions++
+	case CacheExpiration:
+		s.Expirations++
+	}
+}
+
+// CacheStats represents the statistics of cachers
+type CacheStats struct {
+	CacheTableStats
+	Tables map[string]*CacheTableStats
+}
+
+// HitRatio returns the ratio of hits in all the lookups
+func (s *CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (s *CacheStats) merge(other *CacheStats) {
+	s.add(&other.CacheTableStats)
+	if s.Tables == nil {
+		s.Tables = make(map[string]*CacheTableStats)
+	}
+	for tableName, stats := range other.Tables {
+		if t, ok := s.Tables[tableName]; ok {
+			t.add(stats)
+		} else {
+			var t = *stats
+			s.Tables[tableName] = &t
+		}
+	}
+}
+
+// CacheStatsProvider represents a cacher which could report its statistics
+type CacheStatsProvider interface {
+	Stats() CacheStats
+	ResetStats()
+}
+
+func (engine *Engine) statsProviders() []CacheStatsProvider {
+	var providers []CacheStatsProvider
+	var seen = make(map[CacheStatsProvider]bool)
+	var appendCacher = func(cacher interface{}) {
+		if p, ok := cacher.(CacheStatsProvider); ok && !seen[p] {
+			seen[p] = true
+			providers = append(providers, p)
+		}
+	}
+
+	engine.cacherLock.RLock()
+	for _, cacher := range engine.cachers {
+		if cacher != nil {
+			appendCacher(cacher)
+		}
+	}
+	engine.cacherLock.RUnlock()
+	if engine.Cacher != nil {
+		appendCacher(engine.Cacher)
+	}
+	return providers
+}
+
+// CacheStats aggregates the statistics of the default cacher and the cachers
+// registered via SetCacher and MapCacher
+func (engine *Engine) CacheStats() CacheStats {
+	var stats = CacheStats{Tables: make(map[string]*CacheTableStats)}
+	for _, p := range engine.statsProviders() {
+		s := p.Stats()
+		stats.merge(&s)
+	}
+	return stats
+}
+
+// ResetCacheStats resets the statistics of all the cachers
+func (engine *Engine) ResetCacheStats() {
+	for _, p := range engine.statsProviders() {
+		p.ResetStats()
+	}
+}
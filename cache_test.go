@@ -177,3 +177,76 @@ func TestCacheGet(t *testing.T) {
 
 	testEngine.SetDefaultCacher(oldCacher)
 }
+
+func TestCacheStats(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type CacheStatsBox struct {
+		Id       int64
+		Username string
+	}
+
+	assert.NoError(t, testEngine.Sync2(new(CacheStatsBox)))
+
+	cacher := NewLRUCacher2(NewMemoryStore(), time.Hour, 10000)
+	assert.NoError(t, testEngine.MapCacher(new(CacheStatsBox), cacher))
+	defer testEngine.MapCacher(new(CacheStatsBox), nil)
+
+	_, err := testEngine.Insert(&CacheStatsBox{Username: "user1"})
+	assert.NoError(t, err)
+
+	var boxes []CacheStatsBox
+	assert.NoError(t, testEngine.Find(&boxes))
+	assert.EqualValues(t, 1, len(boxes))
+
+	boxes = make([]CacheStatsBox, 0, 1)
+	assert.NoError(t, testEngine.Find(&boxes))
+	assert.EqualValues(t, 1, len(boxes))
+
+	var stats CacheStats
+	switch e := testEngine.(type) {
+	case *Engine:
+		stats = e.CacheStats()
+	case *EngineGroup:
+		stats = e.CacheStats()
+	}
+	tableStats := stats.Tables[testEngine.TableName(new(CacheStatsBox))]
+	assert.NotNil(t, tableStats)
+	assert.True(t, tableStats.Hits > 0)
+	assert.True(t, tableStats.Misses > 0)
+	assert.EqualValues(t, 1, tableStats.IDSize)
+}
+
+func TestEngineCloseCachers(t *testing.T) {
+	if *cluster {
+		t.Skip("engine group has its own engines")
+		return
+	}
+
+	type CloseCacherObject struct {
+		Id   int64
+		Name string `xorm:"cache"`
+	}
+
+	engine, err := NewEngine(dbType, connString)
+	assert.NoError(t, err)
+
+	// the cacher of the cache tag is created by the engine
+	table := engine.TableInfo(new(CloseCacherObject))
+	assert.True(t, table.IsValid())
+	ownedCacher, ok := engine.GetCacher(table.Name).(*LRUCacher)
+	assert.True(t, ok)
+
+	defaultCacher := NewLRUCacher2(NewMemoryStore(), time.Hour, 10000)
+	defer defaultCacher.Close()
+	tableCacher := NewLRUCacher2(NewMemoryStore(), time.Hour, 10000)
+	defer tableCacher.Close()
+	engine.SetDefaultCacher(defaultCacher)
+	engine.SetCacher("close_cacher_object_set", tableCacher)
+
+	assert.NoError(t, engine.Close())
+	assert.Error(t, ownedCacher.ctx.Err())
+	// the cachers set by the user may be shared by other engines
+	assert.NoError(t, defaultCacher.ctx.Err())
+	assert.NoError(t, tableCacher.ctx.Err())
+}
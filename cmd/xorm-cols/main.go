@@ -0,0 +1,513 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// xorm-cols generates the column name constants and the typed columns of the
+// structs in a package, the fields and the tags are mapped by the same rules as
+// xorm does. Add a go:generate directive in the package to keep them in sync:
+//
+//	//go:generate go run xorm.io/xorm/cmd/xorm-cols -types User,Order
+//
+// The structs which have xorm tags will be generated if -types is not provided.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+	"xorm.io/xorm"
+)
+
+// Config is the config of the generator
+type Config struct {
+	// Dir is the directory of the package
+	Dir string
+	// Types are the struct names, all the structs which have the tags will be
+	// generated if it's empty
+	Types []string
+	// Output is the file name of the generated code which will be ignored when parsing
+	Output string
+	// Mapper is the name mapper: snake, same or gonic
+	Mapper string
+	// TagIdentifier is the struct tag key
+	TagIdentifier string
+	// XormPath is the import path of xorm in the generated code
+	XormPath string
+}
+
+func main() {
+	var cfg Config
+	var typeNames string
+	flag.StringVar(&cfg.Dir, "dir", ".", "the directory of the package")
+	flag.StringVar(&typeNames, "types", "", "comma separated struct names, default all the structs with tags")
+	flag.StringVar(&cfg.Output, "o", "xorm_cols_gen.go", "the output file name")
+	flag.StringVar(&cfg.Mapper, "mapper", "snake", "the name mapper: snake, same or gonic")
+	flag.StringVar(&cfg.TagIdentifier, "tag", "xorm", "the struct tag key")
+	flag.StringVar(&cfg.XormPath, "xorm", xorm.DefaultXormPath, "the import path of xorm")
+	flag.Parse()
+
+	if typeNames != "" {
+		cfg.Types = strings.Split(typeNames, ",")
+	}
+
+	src, err := Generate(&cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	output := cfg.Output
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(cfg.Dir, output)
+	}
+	if err := ioutil.WriteFile(output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func newMapper(name string) (phoenixormcore.IMapper, error) {
+	switch name {
+	case "", "snake":
+		return phoenixormcore.SnakeMapper{}, nil
+	case "same":
+		return phoenixormcore.SameMapper{}, nil
+	case "gonic":
+		return phoenixormcore.LintGonicMapper, nil
+	}
+	return nil, fmt.Errorf("unknown mapper %s", name)
+}
+
+// pkgInfo is the parsed package
+type pkgInfo struct {
+	name        string
+	structs     map[string]*ast.StructType
+	files       map[string]*ast.File // struct name -> file
+	structFiles map[*ast.StructType]*ast.File
+	tableNames  map[string]string
+	order       []string
+	// types are the resolved types of the expressions, the types of the
+	// packages which could not be imported are invalid
+	types *types.Info
+}
+
+func parsePackage(cfg *Config) (*pkgInfo, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, cfg.Dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != filepath.Base(cfg.Output)
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("%d packages found in %s", len(pkgs), cfg.Dir)
+	}
+
+	var info = pkgInfo{
+		structs:     make(map[string]*ast.StructType),
+		files:       make(map[string]*ast.File),
+		structFiles: make(map[*ast.StructType]*ast.File),
+		tableNames:  make(map[string]string),
+		types:       &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)},
+	}
+	for name, pkg := range pkgs {
+		info.name = name
+		var fileNames = make([]string, 0, len(pkg.Files))
+		for fileName := range pkg.Files {
+			fileNames = append(fileNames, fileName)
+		}
+		sort.Strings(fileNames)
+
+		var files = make([]*ast.File, 0, len(fileNames))
+		for _, fileName := range fileNames {
+			files = append(files, pkg.Files[fileName])
+		}
+		// the errors are ignored, the types which could not be resolved are
+		// mapped by their expressions
+		conf := types.Config{
+			Importer: importer.ForCompiler(fset, "source", nil),
+			Error:    func(error) {},
+		}
+		conf.Check(name, fset, files, info.types)
+
+		for _, fileName := range fileNames {
+			file := pkg.Files[fileName]
+			for _, decl := range file.Decls {
+				switch decl := decl.(type) {
+				case *ast.GenDecl:
+					for _, spec := range decl.Specs {
+						typeSpec, ok := spec.(*ast.TypeSpec)
+						if !ok || typeSpec.TypeParams != nil {
+							continue
+						}
+						if st, ok := typeSpec.Type.(*ast.StructType); ok {
+							info.structs[typeSpec.Name.Name] = st
+							info.files[typeSpec.Name.Name] = file
+							info.structFiles[st] = file
+							info.order = append(info.order, typeSpec.Name.Name)
+						}
+					}
+				case *ast.FuncDecl:
+					if recv, name, ok := tableNameMethod(decl); ok {
+						info.tableNames[recv] = name
+					}
+				}
+			}
+		}
+	}
+	return &info, nil
+}
+
+// tableNameMethod returns the receiver and the result of the method
+// "TableName() string" which returns a string literal
+func tableNameMethod(decl *ast.FuncDecl) (string, string, bool) {
+	if decl.Recv == nil || len(decl.Recv.List) != 1 || decl.Name.Name != "TableName" ||
+		decl.Body == nil || len(decl.Body.List) != 1 {
+		return "", "", false
+	}
+	recvType := decl.Recv.List[0].Type
+	if star, ok := recvType.(*ast.StarExpr); ok {
+		recvType = star.X
+	}
+	recv, ok := recvType.(*ast.Ident)
+	if !ok {
+		return "", "", false
+	}
+	ret, ok := decl.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return "", "", false
+	}
+	lit, ok := ret.Results[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", "", false
+	}
+	name, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", "", false
+	}
+	return recv.Name, name, true
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	snapshotType = reflect.TypeOf(xorm.Snapshot{})
+)
+
+var basicKinds = map[types.BasicKind]reflect.Type{
+	types.Bool:    reflect.TypeOf(false),
+	types.String:  reflect.TypeOf(""),
+	types.Int:     reflect.TypeOf(int(0)),
+	types.Int8:    reflect.TypeOf(int8(0)),
+	types.Int16:   reflect.TypeOf(int16(0)),
+	types.Int32:   reflect.TypeOf(int32(0)),
+	types.Int64:   reflect.TypeOf(int64(0)),
+	types.Uint:    reflect.TypeOf(uint(0)),
+	types.Uint8:   reflect.TypeOf(uint8(0)),
+	types.Uint16:  reflect.TypeOf(uint16(0)),
+	types.Uint32:  reflect.TypeOf(uint32(0)),
+	types.Uint64:  reflect.TypeOf(uint64(0)),
+	types.Float32: reflect.TypeOf(float32(0)),
+	types.Float64: reflect.TypeOf(float64(0)),
+}
+
+// isXormPath returns true if path is the import path of xorm
+func isXormPath(cfg *Config, path string) bool {
+	return path == cfg.XormPath || path == xorm.DefaultXormPath
+}
+
+// reflectType returns a type which is mapped as t by xorm, the named types are
+// converted to their underlying types except time.Time and xorm.Snapshot, and
+// the types which have no counterparts, such as the structs of the package, are
+// mapped as strings. False will be returned if t is not resolved.
+func reflectType(cfg *Config, t types.Type) (reflect.Type, bool) {
+	switch t := t.(type) {
+	case *types.Named:
+		if obj := t.Obj(); obj.Pkg() != nil {
+			switch {
+			case obj.Pkg().Path() == "time" && obj.Name() == "Time":
+				return timeType, true
+			case isXormPath(cfg, obj.Pkg().Path()) && obj.Name() == "Snapshot":
+				return snapshotType, true
+			}
+		}
+		return reflectType(cfg, t.Underlying())
+	case *types.Basic:
+		if t.Kind() == types.Invalid {
+			return nil, false
+		}
+		if rt, ok := basicKinds[t.Kind()]; ok {
+			return rt, true
+		}
+	case *types.Pointer:
+		elem, ok := reflectType(cfg, t.Elem())
+		if !ok {
+			return nil, false
+		}
+		return reflect.PtrTo(elem), true
+	case *types.Slice:
+		elem, ok := reflectType(cfg, t.Elem())
+		if !ok {
+			return nil, false
+		}
+		return reflect.SliceOf(elem), true
+	case *types.Array:
+		elem, ok := reflectType(cfg, t.Elem())
+		if !ok {
+			return nil, false
+		}
+		return reflect.ArrayOf(int(t.Len()), elem), true
+	case *types.Map:
+		key, ok := reflectType(cfg, t.Key())
+		if !ok || !key.Comparable() {
+			return nil, false
+		}
+		elem, ok := reflectType(cfg, t.Elem())
+		if !ok {
+			return nil, false
+		}
+		return reflect.MapOf(key, elem), true
+	}
+	return reflect.TypeOf(""), true
+}
+
+// isSnapshotExpr returns true if expr is xorm.Snapshot or a pointer to it, it's
+// used when the type of xorm could not be resolved
+func isSnapshotExpr(cfg *Config, file *ast.File, expr ast.Expr) bool {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Snapshot" {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok || file == nil {
+		return false
+	}
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || !isXormPath(cfg, path) {
+			continue
+		}
+		if (spec.Name != nil && spec.Name.Name == x.Name) || (spec.Name == nil && x.Name == "xorm") {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldType returns the type of the field which is mapped as the field by xorm
+func (info *pkgInfo) fieldType(cfg *Config, st *ast.StructType, expr ast.Expr) reflect.Type {
+	if isSnapshotExpr(cfg, info.structFiles[st], expr) {
+		if _, ok := expr.(*ast.StarExpr); ok {
+			return reflect.PtrTo(snapshotType)
+		}
+		return snapshotType
+	}
+	if t := info.types.TypeOf(expr); t != nil {
+		if rt, ok := reflectType(cfg, t); ok {
+			return rt
+		}
+	}
+	switch types.ExprString(expr) {
+	case "time.Time":
+		return timeType
+	case "[]byte":
+		return reflect.TypeOf([]byte{})
+	}
+	return reflect.TypeOf("")
+}
+
+// fieldName returns the name of the field, the type name is returned for an
+// embedded field
+func fieldName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.StarExpr:
+		return fieldName(expr.X)
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	}
+	return ""
+}
+
+func isExtends(tag string) bool {
+	fields := strings.Fields(tag)
+	return len(fields) > 0 && strings.HasPrefix(strings.ToUpper(fields[0]), "EXTENDS")
+}
+
+// structType builds a struct type which has the same field names and tags as
+// st, so that it could be mapped by xorm. The Go types of the fields are
+// recorded into fieldTypes.
+func (info *pkgInfo) structType(cfg *Config, st *ast.StructType, prefix string, fieldTypes map[string]string, visiting map[*ast.StructType]bool) (reflect.Type, error) {
+	if visiting[st] {
+		return nil, fmt.Errorf("%s: recursive extends", strings.TrimSuffix(prefix, "."))
+	}
+	visiting[st] = true
+	defer delete(visiting, st)
+
+	var fields []reflect.StructField
+	for _, field := range st.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			s, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(s)
+		}
+
+		var names []string
+		if len(field.Names) == 0 {
+			names = []string{fieldName(field.Type)}
+		}
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+
+		for _, name := range names {
+			if !ast.IsExported(name) {
+				continue
+			}
+
+			typeExpr := types.ExprString(field.Type)
+			t := info.fieldType(cfg, st, field.Type)
+			if t == snapshotType || t == reflect.PtrTo(snapshotType) {
+				// the snapshot is not mapped as a column
+				continue
+			}
+			if isExtends(tag.Get(cfg.TagIdentifier)) {
+				parent, ok := info.structs[fieldName(field.Type)]
+				if !ok {
+					return nil, fmt.Errorf("%s%s: extends type %s is not a struct of the package", prefix, name, typeExpr)
+				}
+				var err error
+				t, err = info.structType(cfg, parent, prefix+name+".", fieldTypes, visiting)
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				fieldTypes[prefix+name] = typeExpr
+			}
+
+			fields = append(fields, reflect.StructField{
+				Name: name,
+				Type: t,
+				Tag:  tag,
+			})
+		}
+	}
+	return reflect.StructOf(fields), nil
+}
+
+func hasTags(cfg *Config, st *ast.StructType) bool {
+	for _, field := range st.Fields.List {
+		if field.Tag == nil {
+			continue
+		}
+		s, err := strconv.Unquote(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+		if _, ok := reflect.StructTag(s).Lookup(cfg.TagIdentifier); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func fileImports(file *ast.File) []string {
+	var imports = make([]string, 0, len(file.Imports))
+	for _, spec := range file.Imports {
+		if spec.Name != nil {
+			if spec.Name.Name == "_" || spec.Name.Name == "." {
+				continue
+			}
+			imports = append(imports, spec.Name.Name+" "+spec.Path.Value)
+		} else {
+			imports = append(imports, spec.Path.Value)
+		}
+	}
+	return imports
+}
+
+// Generate returns the generated code of the package
+func Generate(cfg *Config) ([]byte, error) {
+	if cfg.TagIdentifier == "" {
+		cfg.TagIdentifier = "xorm"
+	}
+	mapper, err := newMapper(cfg.Mapper)
+	if err != nil {
+		return nil, err
+	}
+	info, err := parsePackage(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var typeNames = cfg.Types
+	if len(typeNames) == 0 {
+		for _, name := range info.order {
+			if ast.IsExported(name) && hasTags(cfg, info.structs[name]) {
+				typeNames = append(typeNames, name)
+			}
+		}
+	}
+	if len(typeNames) == 0 {
+		return nil, fmt.Errorf("no struct found in %s", cfg.Dir)
+	}
+
+	var opts = xorm.ColumnsOptions{
+		Package:       info.name,
+		XormPath:      cfg.XormPath,
+		TableMapper:   mapper,
+		ColumnMapper:  mapper,
+		TagIdentifier: cfg.TagIdentifier,
+		Generator:     "xorm-cols",
+	}
+	var sources = make([]xorm.ColumnsSource, 0, len(typeNames))
+	var visited = make(map[*ast.File]bool)
+	for _, name := range typeNames {
+		name = strings.TrimSpace(name)
+		st, ok := info.structs[name]
+		if !ok {
+			return nil, fmt.Errorf("struct %s is not found in %s", name, cfg.Dir)
+		}
+		var fieldTypes = make(map[string]string)
+		t, err := info.structType(cfg, st, "", fieldTypes, make(map[*ast.StructType]bool))
+		if err != nil {
+			return nil, fmt.Errorf("%s.%v", name, err)
+		}
+		sources = append(sources, xorm.ColumnsSource{
+			Name:       name,
+			Bean:       reflect.New(t).Interface(),
+			TableName:  info.tableNames[name],
+			FieldTypes: fieldTypes,
+		})
+
+		if file := info.files[name]; !visited[file] {
+			visited[file] = true
+			opts.Imports = append(opts.Imports, fileImports(file)...)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := xorm.GenerateColumns(&buf, opts, sources...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
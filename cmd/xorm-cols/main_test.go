@@ -0,0 +1,148 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testModels = `package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Base struct {
+	Id      int64
+	Created time.Time ` + "`xorm:\"created\"`" + `
+}
+
+type User struct {
+	Base     ` + "`xorm:\"extends\"`" + `
+	Name     string         ` + "`xorm:\"'user_name' unique\"`" + `
+	Nick     sql.NullString ` + "`xorm:\"varchar(20)\"`" + `
+	Ignored  string         ` + "`xorm:\"-\"`" + `
+	password string
+}
+
+func (User) TableName() string {
+	return "users"
+}
+
+type helper struct{}
+`
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xorm-cols")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "models.go"), []byte(testModels), 0644))
+	// the output of the last generation should be ignored
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "xorm_cols_gen.go"), []byte("package models\nbroken"), 0644))
+
+	src, err := Generate(&Config{
+		Dir:    dir,
+		Types:  []string{"User"},
+		Output: "xorm_cols_gen.go",
+	})
+	assert.NoError(t, err)
+
+	_, err = parser.ParseFile(token.NewFileSet(), "xorm_cols_gen.go", src, 0)
+	assert.NoError(t, err)
+
+	code := string(src)
+	assert.Contains(t, code, "// Code generated by xorm-cols. DO NOT EDIT.")
+	assert.Contains(t, code, "package models")
+	assert.Contains(t, code, "\t\"database/sql\"\n")
+	assert.Contains(t, code, "\t\"time\"\n")
+	assert.Contains(t, code, `UserTableName      = "users"`)
+	assert.Contains(t, code, `UserColBaseId      = "id"`)
+	assert.Contains(t, code, `UserColBaseCreated = "created"`)
+	assert.Contains(t, code, `UserColName        = "user_name"`)
+	assert.Contains(t, code, `UserColNick        = "nick"`)
+	assert.Contains(t, code, "Nick        xorm.Column[sql.NullString]")
+	assert.Contains(t, code, "BaseCreated xorm.Column[time.Time]")
+	assert.NotContains(t, code, "Ignored")
+	assert.NotContains(t, code, "password")
+
+	_, err = Generate(&Config{Dir: dir, Types: []string{"Order"}, Output: "xorm_cols_gen.go"})
+	assert.Error(t, err)
+
+	_, err = Generate(&Config{Dir: dir, Mapper: "unknown", Output: "xorm_cols_gen.go"})
+	assert.Error(t, err)
+}
+
+const testSnapshotModels = `package models
+
+import (
+	"strings"
+
+	"xorm.io/xorm"
+)
+
+type Status int
+
+type Tags []string
+
+// FromDB implements Conversion
+func (tags *Tags) FromDB(data []byte) error {
+	*tags = strings.Split(string(data), ",")
+	return nil
+}
+
+// ToDB implements Conversion
+func (tags Tags) ToDB() ([]byte, error) {
+	return []byte(strings.Join(tags, ",")), nil
+}
+
+type Article struct {
+	xorm.Snapshot
+	Id       int64
+	Status   Status
+	Tags     Tags
+	Labels   []string ` + "`xorm:\"json\"`" + `
+	Summary  *string
+	Metadata map[string]interface{} ` + "`xorm:\"json\"`" + `
+}
+`
+
+func TestGenerateSnapshotAndConversion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xorm-cols")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "models.go"), []byte(testSnapshotModels), 0644))
+
+	src, err := Generate(&Config{
+		Dir:    dir,
+		Types:  []string{"Article"},
+		Output: "xorm_cols_gen.go",
+	})
+	assert.NoError(t, err)
+
+	_, err = parser.ParseFile(token.NewFileSet(), "xorm_cols_gen.go", src, 0)
+	assert.NoError(t, err)
+
+	code := string(src)
+	// the embedded snapshot is not a column
+	assert.NotContains(t, code, "Snapshot")
+	assert.NotContains(t, code, `"snapshot"`)
+	assert.Contains(t, code, `ArticleColStatus   = "status"`)
+	assert.Contains(t, code, "Status   xorm.Column[Status]")
+	assert.Contains(t, code, "Tags     xorm.Column[Tags]")
+	assert.Contains(t, code, "Labels   xorm.Column[[]string]")
+	assert.Contains(t, code, "Summary  xorm.Column[*string]")
+	assert.Contains(t, code, "Metadata xorm.Column[map[string]interface{}]")
+	assert.NotContains(t, code, "\"strings\"")
+}
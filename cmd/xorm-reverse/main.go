@@ -0,0 +1,129 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// xorm-reverse generates the Go structs of the tables in an existing database,
+// for example:
+//
+//	xorm-reverse -driver mysql -dsn "root:@/test?charset=utf8" -pkg models -o models/models.go \
+//		-include "user*" -types "DECIMAL=decimal.Decimal" -imports github.com/shopspring/decimal
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	_ "github.com/denisenkom/go-mssqldb"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+	_ "github.com/ziutek/mymysql/godrv"
+	"xorm.io/xorm"
+)
+
+// Config is the config of the generator
+type Config struct {
+	Driver  string
+	DSN     string
+	Package string
+	Output  string
+	Mapper  string
+	Include string
+	Exclude string
+	Types   string
+	Imports string
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Options returns the reverse options of the config
+func (cfg *Config) Options() (xorm.ReverseOptions, error) {
+	var opts = xorm.ReverseOptions{
+		Package:       cfg.Package,
+		Include:       splitList(cfg.Include),
+		Exclude:       splitList(cfg.Exclude),
+		TypeOverrides: make(map[string]string),
+		Generator:     "xorm-reverse",
+	}
+
+	switch cfg.Mapper {
+	case "", "snake":
+		opts.Mapper = phoenixormcore.SnakeMapper{}
+	case "same":
+		opts.Mapper = phoenixormcore.SameMapper{}
+	case "gonic":
+		opts.Mapper = phoenixormcore.LintGonicMapper
+	default:
+		return opts, fmt.Errorf("unknown mapper %s", cfg.Mapper)
+	}
+
+	for _, item := range splitList(cfg.Types) {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return opts, fmt.Errorf("type override %s should be key=type", item)
+		}
+		opts.TypeOverrides[kv[0]] = kv[1]
+	}
+
+	for _, item := range splitList(cfg.Imports) {
+		fields := strings.Fields(item)
+		fields[len(fields)-1] = strconv.Quote(strings.Trim(fields[len(fields)-1], `"`))
+		opts.Imports = append(opts.Imports, strings.Join(fields, " "))
+	}
+	return opts, nil
+}
+
+func main() {
+	var cfg Config
+	flag.StringVar(&cfg.Driver, "driver", "mysql", "the database driver name")
+	flag.StringVar(&cfg.DSN, "dsn", "", "the data source name")
+	flag.StringVar(&cfg.Package, "pkg", "models", "the package name of the generated code")
+	flag.StringVar(&cfg.Output, "o", "", "the output file, default stdout")
+	flag.StringVar(&cfg.Mapper, "mapper", "snake", "the name mapper: snake, same or gonic")
+	flag.StringVar(&cfg.Include, "include", "", "comma separated patterns of the tables to generate")
+	flag.StringVar(&cfg.Exclude, "exclude", "", "comma separated patterns of the tables not to generate")
+	flag.StringVar(&cfg.Types, "types", "", "comma separated type overrides like table.column=Type, column=Type or DECIMAL=Type")
+	flag.StringVar(&cfg.Imports, "imports", "", "comma separated imports used by the type overrides, an import could be \"name path\"")
+	flag.Parse()
+
+	opts, err := cfg.Options()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	engine, err := xorm.NewEngine(cfg.Driver, cfg.DSN)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer engine.Close()
+	// keep the logs out of the generated code written to stdout
+	engine.SetLogger(xorm.NewSimpleLogger(os.Stderr))
+
+	var buf bytes.Buffer
+	if err := engine.Reverse(&buf, opts); err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.Output == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := ioutil.WriteFile(cfg.Output, buf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
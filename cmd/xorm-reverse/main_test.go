@@ -0,0 +1,41 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions(t *testing.T) {
+	cfg := Config{
+		Package: "models",
+		Mapper:  "gonic",
+		Include: "user*, order",
+		Exclude: "tmp_*",
+		Types:   "DECIMAL=decimal.Decimal,user.tags=[]string",
+		Imports: "github.com/shopspring/decimal, d \"example.com/dec\"",
+	}
+	opts, err := cfg.Options()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "models", opts.Package)
+	assert.EqualValues(t, []string{"user*", "order"}, opts.Include)
+	assert.EqualValues(t, []string{"tmp_*"}, opts.Exclude)
+	assert.EqualValues(t, map[string]string{
+		"DECIMAL":   "decimal.Decimal",
+		"user.tags": "[]string",
+	}, opts.TypeOverrides)
+	assert.EqualValues(t, []string{`"github.com/shopspring/decimal"`, `d "example.com/dec"`}, opts.Imports)
+
+	cfg.Mapper = "unknown"
+	_, err = cfg.Options()
+	assert.Error(t, err)
+
+	cfg.Mapper = ""
+	cfg.Types = "DECIMAL"
+	_, err = cfg.Options()
+	assert.Error(t, err)
+}
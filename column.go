@@ -0,0 +1,102 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+
+package xorm
+
+import (
+	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
+)
+
+// Column is a typed reference of a column, T is the type of the mapped field.
+// The columns are usually generated by cmd/xorm-cols, for example:
+//
+//	engine.Where(UserCols.Age.Gt(18)).Desc(UserCols.Id.ColumnName()).Find(&users)
+type Column[T any] struct {
+	name string
+}
+
+// NewColumn returns a typed reference of the column
+func NewColumn[T any](name string) Column[T] {
+	return Column[T]{name: name}
+}
+
+// ColumnName returns the name of the column
+func (c Column[T]) ColumnName() string {
+	return c.name
+}
+
+// String returns the name of the column
+func (c Column[T]) String() string {
+	return c.name
+}
+
+// Eq returns the condition column = v
+func (c Column[T]) Eq(v T) phoenixormbuilder.Cond {
+	return phoenixormbuilder.Eq{c.name: v}
+}
+
+// Neq returns the condition column <> v
+func (c Column[T]) Neq(v T) phoenixormbuilder.Cond {
+	return phoenixormbuilder.Neq{c.name: v}
+}
+
+// Gt returns the condition column > v
+func (c Column[T]) Gt(v T) phoenixormbuilder.Cond {
+	return phoenixormbuilder.Gt{c.name: v}
+}
+
+// Gte returns the condition column >= v
+func (c Column[T]) Gte(v T) phoenixormbuilder.Cond {
+	return phoenixormbuilder.Gte{c.name: v}
+}
+
+// Lt returns the condition column < v
+func (c Column[T]) Lt(v T) phoenixormbuilder.Cond {
+	return phoenixormbuilder.Lt{c.name: v}
+}
+
+// Lte returns the condition column <= v
+func (c Column[T]) Lte(v T) phoenixormbuilder.Cond {
+	return phoenixormbuilder.Lte{c.name: v}
+}
+
+// Between returns the condition column BETWEEN less AND more
+func (c Column[T]) Between(less, more T) phoenixormbuilder.Cond {
+	return phoenixormbuilder.Between{Col: c.name, LessVal: less, MoreVal: more}
+}
+
+// In returns the condition column IN (values...)
+func (c Column[T]) In(values ...T) phoenixormbuilder.Cond {
+	return phoenixormbuilder.In(c.name, typedArgs(values)...)
+}
+
+// NotIn returns the condition column NOT IN (values...)
+func (c Column[T]) NotIn(values ...T) phoenixormbuilder.Cond {
+	return phoenixormbuilder.NotIn(c.name, typedArgs(values)...)
+}
+
+// Like returns the condition column LIKE '%s%'
+func (c Column[T]) Like(s string) phoenixormbuilder.Cond {
+	return phoenixormbuilder.Like{c.name, s}
+}
+
+// IsNull returns the condition column IS NULL
+func (c Column[T]) IsNull() phoenixormbuilder.Cond {
+	return phoenixormbuilder.IsNull{c.name}
+}
+
+// NotNull returns the condition column IS NOT NULL
+func (c Column[T]) NotNull() phoenixormbuilder.Cond {
+	return phoenixormbuilder.NotNull{c.name}
+}
+
+func typedArgs[T any](values []T) []interface{} {
+	var args = make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
@@ -0,0 +1,337 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// DefaultXormPath is the import path of xorm in the generated code
+const DefaultXormPath = "xorm.io/xorm"
+
+// ColumnsSource is a struct whose column references will be generated
+type ColumnsSource struct {
+	// Name is the type name of the struct
+	Name string
+	// Bean is the struct or a pointer to it, the fields and the tags are mapped
+	// by the same rules as Engine does
+	Bean interface{}
+	// TableName overrides the mapped table name if it's not empty
+	TableName string
+	// FieldTypes are the Go types of the fields written to the generated code,
+	// the keys are the field names of the columns, e.g. "Base.Id". The types of
+	// the fields of Bean are used if they are absent.
+	FieldTypes map[string]string
+}
+
+// ColumnsOptions are the options of GenerateColumns
+type ColumnsOptions struct {
+	// Package is the package name of the generated code
+	Package string
+	// PkgPath is the import path of the package, the types of it will not be
+	// qualified
+	PkgPath string
+	// XormPath is the import path of xorm, DefaultXormPath will be used if it's empty
+	XormPath string
+	// Imports are the imports could be used by FieldTypes, an import is a path
+	// or a name followed by a path. Only the used ones will be written.
+	Imports []string
+	// TableMapper and ColumnMapper map the names as Engine.SetTableMapper and
+	// Engine.SetColumnMapper, SnakeMapper will be used if they are nil
+	TableMapper  phoenixormcore.IMapper
+	ColumnMapper phoenixormcore.IMapper
+	// TagIdentifier is the struct tag key, "xorm" will be used if it's empty
+	TagIdentifier string
+	// Generator is the name of the generator written to the header
+	Generator string
+}
+
+// newMappingEngine returns an engine without database which maps the structs
+func newMappingEngine(opts *ColumnsOptions) (*Engine, error) {
+	dialect := phoenixormcore.QueryDialect(phoenixormcore.SQLITE)
+	if dialect == nil {
+		return nil, fmt.Errorf("Unsupported dialect type: %v", phoenixormcore.SQLITE)
+	}
+
+	engine := &Engine{
+		dialect:        dialect,
+		mutex:          &sync.RWMutex{},
+		TagIdentifier:  "xorm",
+		TZLocation:     time.Local,
+		tagHandlers:    newTagHandlers(),
+		cachers:        make(map[string]phoenixormcore.Cacher),
+		defaultContext: context.Background(),
+		TableMapper:    phoenixormcore.SnakeMapper{},
+		ColumnMapper:   phoenixormcore.SnakeMapper{},
+	}
+	engine.SetLogger(NewSimpleLogger(ioutil.Discard))
+	if opts.TagIdentifier != "" {
+		engine.TagIdentifier = opts.TagIdentifier
+	}
+	if opts.TableMapper != nil {
+		engine.TableMapper = opts.TableMapper
+	}
+	if opts.ColumnMapper != nil {
+		engine.ColumnMapper = opts.ColumnMapper
+	}
+	return engine, nil
+}
+
+type generatedColumn struct {
+	field string
+	name  string
+	typ   string
+}
+
+type generatedTable struct {
+	name    string
+	table   string
+	columns []generatedColumn
+}
+
+// columnsImports records the imports used by the generated code
+type columnsImports struct {
+	pkgPath  string
+	declared map[string]string // name -> import spec
+	used     map[string]string // name -> import spec
+}
+
+func newColumnsImports(opts *ColumnsOptions) *columnsImports {
+	imports := &columnsImports{
+		pkgPath:  opts.PkgPath,
+		declared: make(map[string]string),
+		used:     make(map[string]string),
+	}
+	for _, spec := range opts.Imports {
+		fields := strings.Fields(spec)
+		switch len(fields) {
+		case 1:
+			imports.declared[path.Base(strings.Trim(fields[0], `"`))] = spec
+		case 2:
+			imports.declared[fields[0]] = spec
+		}
+	}
+	return imports
+}
+
+var qualifierRegexp = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_]*)\.[A-Za-z_]`)
+
+// use records the imports referenced by the type expression
+func (imports *columnsImports) use(typeExpr string) {
+	for _, match := range qualifierRegexp.FindAllStringSubmatch(typeExpr, -1) {
+		if spec, ok := imports.declared[match[1]]; ok {
+			imports.used[match[1]] = spec
+		}
+	}
+}
+
+// typeExpr returns the Go expression of t
+func (imports *columnsImports) typeExpr(t reflect.Type) string {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name()
+		}
+		if t.PkgPath() == imports.pkgPath {
+			return t.Name()
+		}
+		name := strings.SplitN(t.String(), ".", 2)[0]
+		if name == path.Base(t.PkgPath()) {
+			imports.used[name] = strconv.Quote(t.PkgPath())
+		} else {
+			imports.used[name] = fmt.Sprintf("%s %q", name, t.PkgPath())
+		}
+		return name + "." + t.Name()
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + imports.typeExpr(t.Elem())
+	case reflect.Slice:
+		return "[]" + imports.typeExpr(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), imports.typeExpr(t.Elem()))
+	case reflect.Map:
+		return fmt.Sprintf("map[%s]%s", imports.typeExpr(t.Key()), imports.typeExpr(t.Elem()))
+	}
+	return t.String()
+}
+
+// fieldType returns the type of the field whose name could be a path, e.g. "Base.Id"
+func fieldType(t reflect.Type, fieldName string) (reflect.Type, bool) {
+	for _, name := range strings.Split(fieldName, ".") {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil, false
+		}
+		field, ok := t.FieldByName(name)
+		if !ok {
+			return nil, false
+		}
+		t = field.Type
+	}
+	return t, true
+}
+
+// importPath returns the path of the import spec
+func importPath(spec string) string {
+	fields := strings.Fields(spec)
+	return strings.Trim(fields[len(fields)-1], `"`)
+}
+
+// write writes the used imports, the standard packages are grouped first
+func (imports *columnsImports) write(buf *bytes.Buffer) {
+	if len(imports.used) == 0 {
+		return
+	}
+	var std, others []string
+	for _, spec := range imports.used {
+		if p := importPath(spec); strings.Contains(strings.SplitN(p, "/", 2)[0], ".") {
+			others = append(others, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	byPath := func(specs []string) func(i, j int) bool {
+		return func(i, j int) bool {
+			return importPath(specs[i]) < importPath(specs[j])
+		}
+	}
+	sort.Slice(std, byPath(std))
+	sort.Slice(others, byPath(others))
+
+	buf.WriteString("import (\n")
+	for _, spec := range std {
+		buf.WriteString("\t" + spec + "\n")
+	}
+	if len(std) > 0 && len(others) > 0 {
+		buf.WriteString("\n")
+	}
+	for _, spec := range others {
+		buf.WriteString("\t" + spec + "\n")
+	}
+	buf.WriteString(")\n")
+}
+
+// exportedName returns the identifier of the field name in the generated code
+func exportedName(fieldName string) string {
+	return strings.Replace(fieldName, ".", "", -1)
+}
+
+// GenerateColumns writes a Go file which declares the column name constants and
+// the typed columns of the sources. For a struct User, the table name constant
+// UserTableName, the column name constants like UserColName and the typed columns
+// UserCols will be generated. The typed columns could be used as conditions and
+// column references, for example:
+//
+//	engine.Where(UserCols.Age.Gt(18)).DescColumns(UserCols.Id).Find(&users)
+func GenerateColumns(w io.Writer, opts ColumnsOptions, sources ...ColumnsSource) error {
+	if opts.Package == "" {
+		return errors.New("package name is required")
+	}
+	if opts.XormPath == "" {
+		opts.XormPath = DefaultXormPath
+	}
+	if opts.Generator == "" {
+		opts.Generator = "xorm"
+	}
+
+	engine, err := newMappingEngine(&opts)
+	if err != nil {
+		return err
+	}
+	imports := newColumnsImports(&opts)
+
+	var tables = make([]generatedTable, 0, len(sources))
+	for _, source := range sources {
+		v := rValue(source.Bean)
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("%s: %v", source.Name, ErrTableNotFound)
+		}
+		table, err := engine.mapType(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", source.Name, err)
+		}
+
+		var gen = generatedTable{
+			name:  source.Name,
+			table: table.Name,
+		}
+		if source.TableName != "" {
+			gen.table = source.TableName
+		} else if v.Type().Name() == "" {
+			gen.table = engine.TableMapper.Obj2Table(source.Name)
+		}
+
+		for _, col := range table.Columns() {
+			typ, ok := source.FieldTypes[col.FieldName]
+			if ok {
+				imports.use(typ)
+			} else if t, ok := fieldType(v.Type(), col.FieldName); ok {
+				typ = imports.typeExpr(t)
+			} else {
+				typ = "interface{}"
+			}
+			gen.columns = append(gen.columns, generatedColumn{
+				field: exportedName(col.FieldName),
+				name:  col.Name,
+				typ:   typ,
+			})
+		}
+		tables = append(tables, gen)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by %s. DO NOT EDIT.\n\n", opts.Generator)
+	fmt.Fprintf(&buf, "package %s\n\n", opts.Package)
+
+	imports.used["xorm"] = fmt.Sprintf("xorm %q", opts.XormPath)
+	imports.write(&buf)
+
+	for _, table := range tables {
+		fmt.Fprintf(&buf, "\n// The names of table %s and its columns\n", table.table)
+		buf.WriteString("const (\n")
+		fmt.Fprintf(&buf, "\t%sTableName = %q\n", table.name, table.table)
+		for _, col := range table.columns {
+			fmt.Fprintf(&buf, "\t%sCol%s = %q\n", table.name, col.field, col.name)
+		}
+		buf.WriteString(")\n")
+
+		fmt.Fprintf(&buf, "\n// %sCols are the typed columns of table %s\n", table.name, table.table)
+		fmt.Fprintf(&buf, "var %sCols = struct {\n", table.name)
+		for _, col := range table.columns {
+			fmt.Fprintf(&buf, "\t%s xorm.Column[%s]\n", col.field, col.typ)
+		}
+		buf.WriteString("}{\n")
+		for _, col := range table.columns {
+			fmt.Fprintf(&buf, "\t%s: xorm.NewColumn[%s](%sCol%s),\n", col.field, col.typ, table.name, col.field)
+		}
+		buf.WriteString("}\n")
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("format generated code: %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ColumnGenBase struct {
+	Id      int64
+	Created time.Time `xorm:"created"`
+}
+
+type ColumnGenUser struct {
+	Base     ColumnGenBase `xorm:"extends"`
+	UserName string        `xorm:"'login' unique"`
+	Ignored  string        `xorm:"-"`
+	Tags     []string      `xorm:"json"`
+}
+
+func (ColumnGenUser) TableName() string {
+	return "gen_user"
+}
+
+func TestGenerateColumns(t *testing.T) {
+	var buf bytes.Buffer
+	err := GenerateColumns(&buf, ColumnsOptions{
+		Package: "models",
+		PkgPath: "example.com/models",
+	}, ColumnsSource{
+		Name: "ColumnGenUser",
+		Bean: new(ColumnGenUser),
+		FieldTypes: map[string]string{
+			"Tags": "[]Tag",
+		},
+	})
+	assert.NoError(t, err)
+
+	src := buf.String()
+	assert.Contains(t, src, "// Code generated by xorm. DO NOT EDIT.")
+	assert.Contains(t, src, "package models")
+	assert.Contains(t, src, `xorm "xorm.io/xorm"`)
+	assert.Contains(t, src, "\t\"time\"\n")
+	assert.Contains(t, src, `ColumnGenUserTableName      = "gen_user"`)
+	assert.Contains(t, src, `ColumnGenUserColBaseId      = "id"`)
+	assert.Contains(t, src, `ColumnGenUserColBaseCreated = "created"`)
+	assert.Contains(t, src, `ColumnGenUserColUserName    = "login"`)
+	assert.NotContains(t, src, "Ignored")
+	assert.Contains(t, src, "BaseCreated xorm.Column[time.Time]")
+	assert.Contains(t, src, "Tags        xorm.Column[[]Tag]")
+	assert.Contains(t, src, "UserName:    xorm.NewColumn[string](ColumnGenUserColUserName),")
+
+	err = GenerateColumns(&buf, ColumnsOptions{}, ColumnsSource{Name: "ColumnGenUser", Bean: new(ColumnGenUser)})
+	assert.Error(t, err)
+}
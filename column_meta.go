@@ -0,0 +1,47 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// columnMeta holds the metadata of a column which could not be recorded
+// on phoenixormcore.Column
+type columnMeta struct {
+	deletedMode     DeletedMode
+	deletedSentinel interface{}
+	isDeletedBy     bool
+	isTenant        bool
+	encryption      *columnEncryption
+	attrs           map[string]interface{}
+}
+
+// columnMeta returns the metadata of the column, nil will be returned if the
+// column has no more metadata
+func (engine *Engine) columnMeta(col *phoenixormcore.Column) *columnMeta {
+	if meta, ok := engine.columnMetas.Load(col); ok {
+		return meta.(*columnMeta)
+	}
+	return nil
+}
+
+// ColumnAttr returns the custom attribute of the column which is recorded by
+// TagContext.SetColumnAttr
+func (engine *Engine) ColumnAttr(col *phoenixormcore.Column, key string) (interface{}, bool) {
+	meta := engine.columnMeta(col)
+	if meta == nil {
+		return nil, false
+	}
+	value, ok := meta.attrs[key]
+	return value, ok
+}
+
+// columnMeta returns the metadata of the current column, it will be created if
+// it's not exist
+func (ctx *TagContext) columnMeta() *columnMeta {
+	meta, _ := ctx.engine.columnMetas.LoadOrStore(ctx.col, &columnMeta{})
+	return meta.(*columnMeta)
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+
+package xorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+type ColumnUser struct {
+	Id   int64
+	Name string
+	Age  int
+}
+
+type columnUserCols struct {
+	Id   Column[int64]
+	Name Column[string]
+	Age  Column[int]
+}
+
+func newColumnUserCols(mapper func(string) string) columnUserCols {
+	return columnUserCols{
+		Id:   NewColumn[int64](mapper("Id")),
+		Name: NewColumn[string](mapper("Name")),
+		Age:  NewColumn[int](mapper("Age")),
+	}
+}
+
+func TestColumnConds(t *testing.T) {
+	var cols = newColumnUserCols(func(name string) string {
+		return phoenixormcore.SnakeMapper{}.Obj2Table(name)
+	})
+	var cases = []struct {
+		cond phoenixormbuilder.Cond
+		sql  string
+		args []interface{}
+	}{
+		{cols.Age.Eq(18), "age=?", []interface{}{18}},
+		{cols.Age.Neq(18), "age<>?", []interface{}{18}},
+		{cols.Age.Gt(18), "age>?", []interface{}{18}},
+		{cols.Age.Gte(18), "age>=?", []interface{}{18}},
+		{cols.Age.Lt(18), "age<?", []interface{}{18}},
+		{cols.Age.Lte(18), "age<=?", []interface{}{18}},
+		{cols.Age.Between(18, 30), "age BETWEEN ? AND ?", []interface{}{18, 30}},
+		{cols.Id.In(1, 2), "id IN (?,?)", []interface{}{int64(1), int64(2)}},
+		{cols.Id.NotIn(1, 2), "id NOT IN (?,?)", []interface{}{int64(1), int64(2)}},
+		{cols.Name.Like("a"), "name LIKE ?", []interface{}{"%a%"}},
+		{cols.Name.IsNull(), "name IS NULL", nil},
+		{cols.Name.NotNull(), "name IS NOT NULL", nil},
+	}
+	for _, c := range cases {
+		sql, args, err := phoenixormbuilder.ToSQL(c.cond)
+		assert.NoError(t, err)
+		assert.EqualValues(t, c.sql, sql)
+		assert.EqualValues(t, c.args, args)
+	}
+}
+
+func TestColumnRefs(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(ColumnUser))
+	var cols = newColumnUserCols(testEngine.GetColumnMapper().Obj2Table)
+
+	cnt, err := testEngine.Insert([]ColumnUser{
+		{Name: "a", Age: 10},
+		{Name: "b", Age: 20},
+		{Name: "c", Age: 30},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, cnt)
+
+	var users []ColumnUser
+	err = testEngine.Where(cols.Age.Gt(10)).
+		Columns(cols.Id, cols.Name).
+		DescColumns(cols.Age).
+		Find(&users)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(users))
+	assert.EqualValues(t, "c", users[0].Name)
+	assert.EqualValues(t, "b", users[1].Name)
+
+	cnt, err = testEngine.Where(cols.Name.Eq("a")).
+		OmitColumns(cols.Name).
+		Update(&ColumnUser{Name: "x", Age: 11})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	found, err := Query[ColumnUser](testEngine).Where(cols.Age.Eq(11)).
+		AscColumns(cols.Id).Find(nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(found))
+	assert.EqualValues(t, "a", found[0].Name)
+}
@@ -0,0 +1,352 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// KeyProvider provides the AES keys of the encrypted columns. The id of the key
+// is stored with the ciphertext, so the keys could be rotated by changing the
+// current key while the old ones could still be found by their ids.
+type KeyProvider interface {
+	// CurrentKey returns the id and the key to encrypt the values, the key
+	// should be 16, 24 or 32 bytes to select AES-128, AES-192 or AES-256
+	CurrentKey() (string, []byte, error)
+	// Key returns the key of the id to decrypt the values
+	Key(id string) ([]byte, error)
+}
+
+// BlindIndexKeyProvider is implemented by the KeyProviders which support the
+// blind indexes, the key should not be rotated since the indexes are stored
+type BlindIndexKeyProvider interface {
+	BlindIndexKey() ([]byte, error)
+}
+
+// KeyRing is a KeyProvider of the static keys
+type KeyRing struct {
+	// CurrentID is the id of the key to encrypt the values
+	CurrentID string
+	// Keys are the keys by id
+	Keys map[string][]byte
+	// IndexKey is the HMAC key of the blind indexes
+	IndexKey []byte
+}
+
+// CurrentKey implements KeyProvider
+func (ring *KeyRing) CurrentKey() (string, []byte, error) {
+	key, err := ring.Key(ring.CurrentID)
+	return ring.CurrentID, key, err
+}
+
+// Key implements KeyProvider
+func (ring *KeyRing) Key(id string) ([]byte, error) {
+	key, ok := ring.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("key %s is not found", id)
+	}
+	return key, nil
+}
+
+// BlindIndexKey implements BlindIndexKeyProvider
+func (ring *KeyRing) BlindIndexKey() ([]byte, error) {
+	if len(ring.IndexKey) == 0 {
+		return nil, fmt.Errorf("blind index key is not set")
+	}
+	return ring.IndexKey, nil
+}
+
+// columnEncryption is the metadata of an encrypted column or a blind index column
+type columnEncryption struct {
+	// blindIndex is the name of the blind index column of an encrypted column
+	blindIndex string
+	// encrypted is the encrypted column of a blind index column
+	encrypted *phoenixormcore.Column
+}
+
+// EncryptedTagHandler describes encrypted tag handler, the values of the column
+// are encrypted by the KeyProvider of the engine. With a param like
+// encrypted(email_index), the blind indexes of the values are stored into the
+// column email_index, so that the bean conditions on the column still work.
+// The quoted 'encrypted' is the name of a plain column.
+func EncryptedTagHandler(ctx *TagContext) error {
+	var encryption columnEncryption
+	if len(ctx.params) > 0 {
+		encryption.blindIndex = strings.Trim(strings.TrimSpace(ctx.params[0]), "'\"")
+	}
+	ctx.columnMeta().encryption = &encryption
+	// the ciphertexts are stored as text whatever the type of the field is
+	if ctx.col.SQLType.Name == "" {
+		ctx.col.SQLType = phoenixormcore.SQLType{Name: phoenixormcore.Text}
+	}
+	return nil
+}
+
+// addBlindIndexColumns adds the blind index columns of the encrypted columns,
+// they are mapped to the same fields as the encrypted columns but only written
+func (engine *Engine) addBlindIndexColumns(table *phoenixormcore.Table) {
+	for _, col := range table.Columns() {
+		encryption := engine.columnEncryption(col)
+		if encryption == nil || encryption.blindIndex == "" {
+			continue
+		}
+		indexCol := &phoenixormcore.Column{
+			Name:           encryption.blindIndex,
+			FieldName:      col.FieldName,
+			SQLType:        phoenixormcore.SQLType{Name: phoenixormcore.Varchar},
+			Length:         sha256.Size * 2,
+			Nullable:       true,
+			MapType:        phoenixormcore.ONLYTODB,
+			Indexes:        make(map[string]int),
+			DefaultIsEmpty: true,
+		}
+		engine.columnMetas.Store(indexCol, &columnMeta{
+			encryption: &columnEncryption{encrypted: col},
+		})
+		addIndex(indexCol.Name, table, indexCol, phoenixormcore.IndexType)
+		table.AddColumn(indexCol)
+	}
+}
+
+// columnEncryption returns the encryption metadata of the column, nil will be
+// returned if the column is not encrypted
+func (engine *Engine) columnEncryption(col *phoenixormcore.Column) *columnEncryption {
+	if meta := engine.columnMeta(col); meta != nil {
+		return meta.encryption
+	}
+	return nil
+}
+
+// columnFilterName returns the name of the column matched by Cols and Omit, a
+// blind index column is matched by the name of its encrypted column
+func (engine *Engine) columnFilterName(col *phoenixormcore.Column) string {
+	if encryption := engine.columnEncryption(col); encryption != nil && encryption.encrypted != nil {
+		return encryption.encrypted.Name
+	}
+	return col.Name
+}
+
+// SetKeyProvider sets the KeyProvider of the encrypted columns
+func (engine *Engine) SetKeyProvider(provider KeyProvider) {
+	engine.keyProvider = provider
+}
+
+// encryptedPlaintext returns the bytes of the value to be encrypted, they are
+// converted back to the field as the values read from database
+func encryptedPlaintext(v interface{}) ([]byte, error) {
+	switch x := v.(type) {
+	case string:
+		return []byte(x), nil
+	case []byte:
+		return x, nil
+	case time.Time:
+		return []byte(x.Format(time.RFC3339Nano)), nil
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.String:
+		return []byte(rv.String()), nil
+	case reflect.Bool:
+		return []byte(strconv.FormatBool(rv.Bool())), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return []byte(strconv.FormatInt(rv.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return []byte(strconv.FormatUint(rv.Uint(), 10)), nil
+	case reflect.Float32, reflect.Float64:
+		return []byte(strconv.FormatFloat(rv.Float(), 'g', -1, 64)), nil
+	}
+	return nil, fmt.Errorf("unsupported encrypted value type %T", v)
+}
+
+// encryptColumnValue encrypts the value of an encrypted column or returns the
+// blind index of the value of a blind index column, the values of the other
+// columns are returned directly
+func (engine *Engine) encryptColumnValue(col *phoenixormcore.Column, v interface{}) (interface{}, error) {
+	encryption := engine.columnEncryption(col)
+	if encryption == nil || v == nil {
+		return v, nil
+	}
+	if encryption.encrypted != nil {
+		return engine.blindIndex(encryption.encrypted.Name, v)
+	}
+
+	if engine.keyProvider == nil {
+		return nil, ErrNoKeyProvider
+	}
+	plaintext, err := encryptedPlaintext(v)
+	if err != nil {
+		return nil, err
+	}
+	id, key, err := engine.keyProvider.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(id, ":") {
+		return nil, fmt.Errorf("key id %s should not contain ':'", id)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	// the column name is authenticated so the ciphertexts could not be moved
+	// to another column
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(col.Name))
+	return id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// encryptMapValues encrypts the values of the encrypted columns of a map bean and
+// adds the blind indexes of them, the blind index columns given in the map are
+// replaced by the ones of the encrypted values
+func (engine *Engine) encryptMapValues(table *phoenixormcore.Table, columns []string, args []interface{}) ([]string, []interface{}, error) {
+	if table == nil {
+		return columns, args, nil
+	}
+
+	var indexes = make(map[string]interface{})
+	var indexNames []string
+	var encrypted = make([]interface{}, len(args))
+	for i, name := range columns {
+		encrypted[i] = args[i]
+		col := table.GetColumn(name)
+		if col == nil {
+			continue
+		}
+		encryption := engine.columnEncryption(col)
+		if encryption == nil || encryption.encrypted != nil {
+			continue
+		}
+		if encryption.blindIndex != "" {
+			var index interface{}
+			if args[i] != nil {
+				var err error
+				if index, err = engine.blindIndex(col.Name, args[i]); err != nil {
+					return nil, nil, err
+				}
+			}
+			indexes[strings.ToLower(encryption.blindIndex)] = index
+			indexNames = append(indexNames, encryption.blindIndex)
+		}
+		v, err := engine.encryptColumnValue(col, args[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		encrypted[i] = v
+	}
+	if len(indexNames) == 0 {
+		return columns, encrypted, nil
+	}
+
+	var newColumns = make([]string, 0, len(columns)+len(indexNames))
+	var newArgs = make([]interface{}, 0, len(columns)+len(indexNames))
+	for i, name := range columns {
+		if _, ok := indexes[strings.ToLower(name)]; !ok {
+			newColumns = append(newColumns, name)
+			newArgs = append(newArgs, encrypted[i])
+		}
+	}
+	for _, name := range indexNames {
+		newColumns = append(newColumns, name)
+		newArgs = append(newArgs, indexes[strings.ToLower(name)])
+	}
+	return newColumns, newArgs, nil
+}
+
+// decryptColumnValue decrypts the raw value of an encrypted column
+func (engine *Engine) decryptColumnValue(col *phoenixormcore.Column, raw interface{}) ([]byte, error) {
+	if engine.keyProvider == nil {
+		return nil, ErrNoKeyProvider
+	}
+
+	var s string
+	switch x := raw.(type) {
+	case string:
+		s = x
+	case []byte:
+		s = string(x)
+	default:
+		return nil, fmt.Errorf("column %s: unsupported encrypted data type %T", col.Name, raw)
+	}
+	pos := strings.Index(s, ":")
+	if pos < 0 {
+		return nil, fmt.Errorf("column %s: the value is not encrypted", col.Name)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(s[pos+1:])
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %v", col.Name, err)
+	}
+	key, err := engine.keyProvider.Key(s[:pos])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("column %s: the ciphertext is too short", col.Name)
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(col.Name))
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %v", col.Name, err)
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (engine *Engine) blindIndex(colName string, v interface{}) (string, error) {
+	provider, ok := engine.keyProvider.(BlindIndexKeyProvider)
+	if !ok {
+		return "", fmt.Errorf("the key provider doesn't support blind index of column %s", colName)
+	}
+	plaintext, err := encryptedPlaintext(v)
+	if err != nil {
+		return "", err
+	}
+	key, err := provider.BlindIndexKey()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(colName))
+	mac.Write([]byte{0})
+	mac.Write(plaintext)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// BlindIndex returns the blind index of the value of the encrypted column, it
+// could be used to find the records by the blind index column, for example:
+//
+//	index, err := engine.BlindIndex("email", "someone@example.com")
+//	has, err := engine.Where("email_index = ?", index).Get(&user)
+func (engine *Engine) BlindIndex(colName string, value interface{}) (string, error) {
+	return engine.blindIndex(colName, value)
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type EncryptedUser struct {
+	Id    int64
+	Name  string
+	Email string `xorm:"encrypted(email_index)"`
+	Phone string `xorm:"encrypted"`
+	Age   int    `xorm:"encrypted"`
+}
+
+func TestEncryptedColumns(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	ring := &KeyRing{
+		CurrentID: "k1",
+		Keys: map[string][]byte{
+			"k1": []byte("0123456789abcdef0123456789abcdef"),
+			"k2": []byte("fedcba9876543210"),
+		},
+		IndexKey: []byte("blind index key"),
+	}
+	testEngine.SetKeyProvider(ring)
+	defer testEngine.SetKeyProvider(nil)
+
+	assertSync(t, new(EncryptedUser))
+
+	colMapper := testEngine.GetColumnMapper()
+	table := testEngine.TableInfo(new(EncryptedUser))
+	assert.NotNil(t, table.GetColumn("email_index"))
+
+	user := EncryptedUser{Name: "lunny", Email: "lunny@example.com", Phone: "123456", Age: 18}
+	cnt, err := testEngine.Insert(&user)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// the values are stored as ciphertexts with the key id
+	results, err := testEngine.QueryString("SELECT * FROM " + testEngine.Quote(testEngine.TableName(new(EncryptedUser), true)))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(results))
+	for _, name := range []string{"Email", "Phone", "Age"} {
+		value := results[0][colMapper.Obj2Table(name)]
+		assert.True(t, strings.HasPrefix(value, "k1:"), value)
+		assert.NotContains(t, value, "lunny")
+	}
+	assert.EqualValues(t, 64, len(results[0]["email_index"]))
+
+	var got EncryptedUser
+	has, err := testEngine.ID(user.Id).Get(&got)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, user, got)
+
+	// the bean conditions use the blind index
+	var byEmail = EncryptedUser{Email: "lunny@example.com"}
+	has, err = testEngine.Get(&byEmail)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, user, byEmail)
+
+	index, err := testEngine.BlindIndex(colMapper.Obj2Table("Email"), "lunny@example.com")
+	assert.NoError(t, err)
+	var users []EncryptedUser
+	assert.NoError(t, testEngine.Where("email_index = ?", index).Find(&users))
+	assert.EqualValues(t, []EncryptedUser{user}, users)
+
+	// the blind index is updated with the column
+	cnt, err = testEngine.ID(user.Id).Cols(colMapper.Obj2Table("Email")).Update(&EncryptedUser{Email: "xlw@example.com"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	has, err = testEngine.Exist(&EncryptedUser{Email: "lunny@example.com"})
+	assert.NoError(t, err)
+	assert.False(t, has)
+	has, err = testEngine.Exist(&EncryptedUser{Email: "xlw@example.com"})
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	cnt, err = testEngine.ID(user.Id).Update(&EncryptedUser{Phone: "999"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// the records encrypted by the old keys could still be read after rotation
+	ring.CurrentID = "k2"
+	cnt, err = testEngine.Insert(&EncryptedUser{Name: "xlw", Email: "xlw2@example.com", Phone: "654321", Age: 20})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	users = nil
+	assert.NoError(t, testEngine.Asc("id").Find(&users))
+	assert.EqualValues(t, 2, len(users))
+	assert.EqualValues(t, "xlw@example.com", users[0].Email)
+	assert.EqualValues(t, "999", users[0].Phone)
+	assert.EqualValues(t, "xlw2@example.com", users[1].Email)
+	assert.EqualValues(t, 20, users[1].Age)
+
+	delete(ring.Keys, "k1")
+	sess := testEngine.NewSession()
+	defer sess.Close()
+	users = nil
+	assert.Error(t, sess.NoCache().Asc("id").Find(&users))
+
+	testEngine.SetKeyProvider(nil)
+	_, err = testEngine.Insert(&EncryptedUser{Name: "nokey", Email: "nokey@example.com"})
+	assert.EqualValues(t, ErrNoKeyProvider, err)
+}
+
+func TestEncryptedColumnsOfMaps(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	testEngine.SetKeyProvider(&KeyRing{
+		CurrentID: "k1",
+		Keys:      map[string][]byte{"k1": []byte("0123456789abcdef0123456789abcdef")},
+		IndexKey:  []byte("blind index key"),
+	})
+	defer testEngine.SetKeyProvider(nil)
+
+	assertSync(t, new(EncryptedUser))
+
+	colMapper := testEngine.GetColumnMapper()
+	tableName := testEngine.TableName(new(EncryptedUser), true)
+	emailCol := colMapper.Obj2Table("Email")
+
+	// the values of the maps are encrypted and the blind indexes are added
+	cnt, err := testEngine.Table(new(EncryptedUser)).Insert(map[string]interface{}{
+		colMapper.Obj2Table("Name"): "lunny",
+		emailCol:                    "lunny@example.com",
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	cnt, err = testEngine.Table(tableName).Insert(map[string]string{
+		colMapper.Obj2Table("Name"): "xlw",
+		emailCol:                    "xlw@example.com",
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	results, err := testEngine.QueryString("SELECT * FROM " + testEngine.Quote(tableName))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(results))
+	for _, result := range results {
+		assert.True(t, strings.HasPrefix(result[emailCol], "k1:"), result[emailCol])
+	}
+
+	var user = EncryptedUser{Email: "xlw@example.com"}
+	has, err := testEngine.Get(&user)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "xlw", user.Name)
+
+	// the blind index is updated with the column
+	cnt, err = testEngine.Table(new(EncryptedUser)).ID(user.Id).Update(map[string]interface{}{
+		emailCol: "xlw2@example.com",
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	has, err = testEngine.Exist(&EncryptedUser{Email: "xlw@example.com"})
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	var updated = EncryptedUser{Email: "xlw2@example.com"}
+	has, err = testEngine.Get(&updated)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, user.Id, updated.Id)
+}
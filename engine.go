@@ -20,7 +20,6 @@ import (
 	"sync"
 	"time"
 
-	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
 	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
 )
 
@@ -33,7 +32,6 @@ type Engine struct {
 	ColumnMapper  phoenixormcore.IMapper
 	TableMapper   phoenixormcore.IMapper
 	TagIdentifier string
-	Tables        map[reflect.Type]*phoenixormcore.Table
 
 	mutex  *sync.RWMutex
 	Cacher phoenixormcore.Cacher
@@ -47,14 +45,37 @@ type Engine struct {
 
 	disableGlobalCache bool
 
-	tagHandlers map[string]tagHandler
+	tagHandlers map[string]TagHandler
 
 	engineGroup *EngineGroup
 
 	cachers    map[string]phoenixormcore.Cacher
 	cacherLock sync.RWMutex
+	// ownedCachers are the cachers created by the engine which will be closed by Close
+	ownedCachers []*LRUCacher
 
 	defaultContext context.Context
+
+	safetyPolicy *SafetyPolicy
+
+	columnMetas sync.Map
+	scanPlans   sync.Map
+
+	tables        sync.Map // reflect.Type -> *phoenixormcore.Table
+	mappingsMutex sync.Mutex
+	mappings      map[reflect.Type]*tableMapping
+
+	keyProvider KeyProvider
+
+	tenantResolver TenantResolver
+	tenantSchema   TenantSchemaResolver
+
+	globalScopes sync.Map // reflect.Type -> []globalScope
+	rowPolicies  sync.Map // reflect.Type -> RowPolicy
+
+	auditSink     AuditSink
+	auditedTables sync.Map
+	auditEnabled  int32
 }
 
 func (engine *Engine) setCacher(tableName string, cacher phoenixormcore.Cacher) {
@@ -90,14 +111,6 @@ func (engine *Engine) BufferSize(size int) *Session {
 	return session.BufferSize(size)
 }
 
-// CondDeleted returns the conditions whether a record is soft deleted.
-func (engine *Engine) CondDeleted(colName string) phoenixormbuilder.Cond {
-	if engine.dialect.DBType() == phoenixormcore.MSSQL {
-		return phoenixormbuilder.IsNull{colName}
-	}
-	return phoenixormbuilder.IsNull{colName}.Or(phoenixormbuilder.Eq{colName: zeroTime1})
-}
-
 // ShowSQL show SQL statement or not on logger if log level is great than INFO
 func (engine *Engine) ShowSQL(show ...bool) {
 	engine.logger.ShowSQL(show...)
@@ -167,6 +180,22 @@ func (engine *Engine) SetColumnMapper(mapper phoenixormcore.IMapper) {
 	engine.ColumnMapper = mapper
 }
 
+// RegisterTag registers a handler of the tag name on this engine, the name is
+// case insensitive and the handler of an existing tag will be replaced. It
+// should be called before the structs are mapped.
+func (engine *Engine) RegisterTag(name string, handler TagHandler) {
+	engine.mutex.Lock()
+	engine.tagHandlers[strings.ToUpper(name)] = handler
+	engine.mutex.Unlock()
+}
+
+func (engine *Engine) tagHandler(name string) (TagHandler, bool) {
+	engine.mutex.RLock()
+	h, ok := engine.tagHandlers[name]
+	engine.mutex.RUnlock()
+	return h, ok
+}
+
 // SupportInsertMany If engine's database support batch insert records like
 // "insert into user values (name, age), (name, age)".
 // When the return is ture, then engine.Insert(&users) will
@@ -339,11 +368,26 @@ func (engine *Engine) NewSession() *Session {
 	return session
 }
 
-// Close the engine
+// Close the engine and stop the cachers created by the engine, the cachers set
+// by SetDefaultCacher and SetCacher are not closed as they may be shared
 func (engine *Engine) Close() error {
+	engine.closeCachers()
 	return engine.db.Close()
 }
 
+func (engine *Engine) closeCachers() {
+	engine.cacherLock.Lock()
+	var cachers = engine.ownedCachers
+	engine.ownedCachers = nil
+	engine.cacherLock.Unlock()
+
+	for _, cacher := range cachers {
+		if err := cacher.Close(); err != nil {
+			engine.logger.Error("close cacher failed:", err)
+		}
+	}
+}
+
 // Ping tests if database is alive
 func (engine *Engine) Ping() error {
 	session := engine.NewSession()
@@ -721,6 +765,34 @@ func (engine *Engine) Omit(columns ...string) *Session {
 	return session.Omit(columns...)
 }
 
+// Columns only use the column references as select or update columns
+func (engine *Engine) Columns(cols ...ColumnRef) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Columns(cols...)
+}
+
+// OmitColumns only not use the column references as select or update columns
+func (engine *Engine) OmitColumns(cols ...ColumnRef) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.OmitColumns(cols...)
+}
+
+// AscColumns will generate "ORDER BY column1,column2 Asc" by column references
+func (engine *Engine) AscColumns(cols ...ColumnRef) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.AscColumns(cols...)
+}
+
+// DescColumns will generate "ORDER BY column1,column2 Desc" by column references
+func (engine *Engine) DescColumns(cols ...ColumnRef) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.DescColumns(cols...)
+}
+
 // Nullable set null when column is zero-value and nullable for update
 func (engine *Engine) Nullable(columns ...string) *Session {
 	session := engine.NewSession()
@@ -832,41 +904,10 @@ func (engine *Engine) GroupBy(keys string) *Session {
 }
 
 // Having generate having statement
-func (engine *Engine) Having(conditions string) *Session {
+func (engine *Engine) Having(conditions string, args ...interface{}) *Session {
 	session := engine.NewSession()
 	session.isAutoClose = true
-	return session.Having(conditions)
-}
-
-// UnMapType removes the datbase mapper of a type
-func (engine *Engine) UnMapType(t reflect.Type) {
-	engine.mutex.Lock()
-	defer engine.mutex.Unlock()
-	delete(engine.Tables, t)
-}
-
-func (engine *Engine) autoMapType(v reflect.Value) (*phoenixormcore.Table, error) {
-	t := v.Type()
-	engine.mutex.Lock()
-	defer engine.mutex.Unlock()
-	table, ok := engine.Tables[t]
-	if !ok {
-		var err error
-		table, err = engine.mapType(v)
-		if err != nil {
-			return nil, err
-		}
-
-		engine.Tables[t] = table
-		if engine.Cacher != nil {
-			if v.CanAddr() {
-				engine.GobRegister(v.Addr().Interface())
-			} else {
-				engine.GobRegister(v.Interface())
-			}
-		}
-	}
-	return table, nil
+	return session.Having(conditions, args...)
 }
 
 // GobRegister register one struct to gob for cache use
@@ -933,6 +974,9 @@ func (engine *Engine) mapType(v reflect.Value) (*phoenixormcore.Table, error) {
 		var col *phoenixormcore.Column
 		fieldValue := v.Field(i)
 		fieldType := fieldValue.Type()
+		if isSnapshotType(fieldType) {
+			continue
+		}
 
 		if ormTagStr != "" {
 			col = &phoenixormcore.Column{
@@ -951,7 +995,7 @@ func (engine *Engine) mapType(v reflect.Value) (*phoenixormcore.Table, error) {
 					continue
 				}
 
-				var ctx = tagContext{
+				var ctx = TagContext{
 					table:      table,
 					col:        col,
 					fieldValue: fieldValue,
@@ -1007,7 +1051,7 @@ func (engine *Engine) mapType(v reflect.Value) (*phoenixormcore.Table, error) {
 						ctx.nextTag = ""
 					}
 
-					if h, ok := engine.tagHandlers[ctx.tagName]; ok {
+					if h, ok := engine.tagHandler(ctx.tagName); ok {
 						if err := h(&ctx); err != nil {
 							return nil, err
 						}
@@ -1079,6 +1123,8 @@ func (engine *Engine) mapType(v reflect.Value) (*phoenixormcore.Table, error) {
 
 	} // end for
 
+	engine.addBlindIndexColumns(table)
+
 	if idFieldColName != "" && len(table.PrimaryKeys) == 0 {
 		col := table.GetColumn(idFieldColName)
 		col.IsPrimaryKey = true
@@ -1094,7 +1140,11 @@ func (engine *Engine) mapType(v reflect.Value) (*phoenixormcore.Table, error) {
 			engine.setCacher(table.Name, engine.Cacher)
 		} else {
 			engine.logger.Info("enable LRU cache on table:", table.Name)
-			engine.setCacher(table.Name, NewLRUCacher2(NewMemoryStore(), time.Hour, 10000))
+			cacher := NewLRUCacher2(NewMemoryStore(), time.Hour, 10000)
+			engine.cacherLock.Lock()
+			engine.ownedCachers = append(engine.ownedCachers, cacher)
+			engine.cacherLock.Unlock()
+			engine.setCacher(table.Name, cacher)
 		}
 	}
 	if hasNoCacheTag {
@@ -1453,6 +1503,20 @@ func (engine *Engine) Update(bean interface{}, condiBeans ...interface{}) (int64
 	return session.Update(bean, condiBeans...)
 }
 
+// UpdateWithRetry updates bean and retries with the reloaded row on version conflict
+func (engine *Engine) UpdateWithRetry(bean interface{}, mutate func(bean interface{}) error, maxAttempts int) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.UpdateWithRetry(bean, mutate, maxAttempts)
+}
+
+// UpdateChanged updates the columns of bean which are changed since it's loaded
+func (engine *Engine) UpdateChanged(bean interface{}) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.UpdateChanged(bean)
+}
+
 // Delete records, bean's non-empty fields are conditions
 func (engine *Engine) Delete(bean interface{}) (int64, error) {
 	session := engine.NewSession()
@@ -1460,6 +1524,27 @@ func (engine *Engine) Delete(bean interface{}) (int64, error) {
 	return session.Delete(bean)
 }
 
+// ForceDelete deletes records even if the table has a deleted column
+func (engine *Engine) ForceDelete(bean interface{}) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.ForceDelete(bean)
+}
+
+// Restore restores the soft deleted records, bean's non-empty fields are conditions
+func (engine *Engine) Restore(bean interface{}) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.Restore(bean)
+}
+
+// PurgeDeleted removes the records which were soft deleted before olderThan ago
+func (engine *Engine) PurgeDeleted(bean interface{}, olderThan time.Duration) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.PurgeDeleted(bean, olderThan)
+}
+
 // Get retrieve one record from table, bean's non-empty fields
 // are conditions
 func (engine *Engine) Get(bean interface{}) (bool, error) {
@@ -1672,6 +1757,13 @@ func (engine *Engine) SetSchema(schema string) {
 	engine.dialect.URI().Schema = schema
 }
 
+// OnlyDeleted only queries the soft deleted records
+func (engine *Engine) OnlyDeleted() *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.OnlyDeleted()
+}
+
 // Unscoped always disable struct tag "deleted"
 func (engine *Engine) Unscoped() *Session {
 	session := engine.NewSession()
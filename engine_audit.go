@@ -0,0 +1,162 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// AuditOp represents the operation of an audit record
+type AuditOp string
+
+// audit operations
+const (
+	AuditInsert AuditOp = "insert"
+	AuditUpdate AuditOp = "update"
+	AuditDelete AuditOp = "delete"
+)
+
+// AuditChange represents the change of a column
+type AuditChange struct {
+	Column string      `json:"column"`
+	Old    interface{} `json:"old"`
+	New    interface{} `json:"new"`
+}
+
+// AuditRecord represents a change of a row
+type AuditRecord struct {
+	TableName string
+	PK        phoenixormcore.PK
+	Op        AuditOp
+	Changes   []AuditChange
+	Actor     interface{}
+	Time      time.Time
+}
+
+// AuditSink receives the audit records after the changes are committed
+type AuditSink interface {
+	WriteAudit(ctx context.Context, records []*AuditRecord) error
+}
+
+// AuditSinkFunc is an adapter to allow the use of ordinary functions as AuditSink
+type AuditSinkFunc func(ctx context.Context, records []*AuditRecord) error
+
+// WriteAudit calls f(ctx, records)
+func (f AuditSinkFunc) WriteAudit(ctx context.Context, records []*AuditRecord) error {
+	return f(ctx, records)
+}
+
+// DefaultAuditTable is the default table name of AuditTableSink
+const DefaultAuditTable = "audit_log"
+
+// AuditLog represents a row of the audit table, the primary key and the
+// changes are stored as JSON
+type AuditLog struct {
+	Id        int64     `xorm:"'id' pk autoincr"`
+	TableName string    `xorm:"'table_name' varchar(255) index"`
+	Pk        string    `xorm:"'pk' varchar(255)"`
+	Op        string    `xorm:"'op' varchar(20)"`
+	Changes   string    `xorm:"'changes' text"`
+	Actor     string    `xorm:"'actor' varchar(255)"`
+	CreatedAt time.Time `xorm:"'created_at' index"`
+}
+
+// AuditTableSink writes the audit records to a table
+type AuditTableSink struct {
+	Engine *Engine
+	// TableName is the audit table, default is DefaultAuditTable
+	TableName string
+}
+
+func (sink *AuditTableSink) tableName() string {
+	if sink.TableName == "" {
+		return DefaultAuditTable
+	}
+	return sink.TableName
+}
+
+// Sync creates or updates the audit table
+func (sink *AuditTableSink) Sync() error {
+	return sink.Engine.Table(sink.tableName()).Sync2(new(AuditLog))
+}
+
+// WriteAudit inserts the records to the audit table
+func (sink *AuditTableSink) WriteAudit(ctx context.Context, records []*AuditRecord) error {
+	var logs = make([]AuditLog, 0, len(records))
+	for _, record := range records {
+		pk, err := json.Marshal(record.PK)
+		if err != nil {
+			return err
+		}
+		changes, err := json.Marshal(record.Changes)
+		if err != nil {
+			return err
+		}
+		var actor string
+		if record.Actor != nil {
+			actor = fmt.Sprint(record.Actor)
+		}
+		logs = append(logs, AuditLog{
+			TableName: record.TableName,
+			Pk:        string(pk),
+			Op:        string(record.Op),
+			Changes:   string(changes),
+			Actor:     actor,
+			CreatedAt: record.Time,
+		})
+	}
+	_, err := sink.Engine.Context(ctx).Table(sink.tableName()).Insert(&logs)
+	return err
+}
+
+// EnableAudit enables auditing the inserts, updates and deletes of the tables
+// of beans, including InsertMulti, Restore and PurgeDeleted, it could also be
+// enabled by the audit tag. The tables should have primary keys.
+func (engine *Engine) EnableAudit(beans ...interface{}) error {
+	for _, bean := range beans {
+		table, err := engine.autoMapType(rValue(bean))
+		if err != nil {
+			return err
+		}
+		if len(table.PrimaryKeys) == 0 {
+			return fmt.Errorf("table %s has no primary key to be audited", table.Name)
+		}
+		engine.enableAudit(table.Type)
+	}
+	return nil
+}
+
+func (engine *Engine) enableAudit(t reflect.Type) {
+	engine.auditedTables.Store(t, true)
+	atomic.StoreInt32(&engine.auditEnabled, 1)
+}
+
+func (engine *Engine) isAudited(table *phoenixormcore.Table) bool {
+	if table == nil || atomic.LoadInt32(&engine.auditEnabled) == 0 {
+		return false
+	}
+	_, ok := engine.auditedTables.Load(table.Type)
+	return ok
+}
+
+// SetAuditSink sets the sink of the audit records, the records will be written
+// to the DefaultAuditTable of the engine if it's not set.
+func (engine *Engine) SetAuditSink(sink AuditSink) {
+	engine.auditSink = sink
+}
+
+func (engine *Engine) getAuditSink() AuditSink {
+	if engine.auditSink == nil {
+		return &AuditTableSink{Engine: engine}
+	}
+	return engine.auditSink
+}
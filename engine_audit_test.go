@@ -0,0 +1,215 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type auditCollector struct {
+	records []*AuditRecord
+	mutex   sync.Mutex
+}
+
+func (c *auditCollector) WriteAudit(ctx context.Context, records []*AuditRecord) error {
+	c.mutex.Lock()
+	c.records = append(c.records, records...)
+	c.mutex.Unlock()
+	return nil
+}
+
+func (c *auditCollector) take() []*AuditRecord {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	records := c.records
+	c.records = nil
+	return records
+}
+
+func TestAudit(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type AuditUser struct {
+		Id   int64 `xorm:"pk autoincr audit"`
+		Name string
+		Age  int
+	}
+
+	assertSync(t, new(AuditUser))
+
+	var collector auditCollector
+	testEngine.SetAuditSink(&collector)
+	defer testEngine.SetAuditSink(nil)
+
+	ctx := WithActor(context.Background(), "admin")
+	var user = AuditUser{Name: "lunny", Age: 18}
+	_, err := testEngine.Context(ctx).Insert(&user)
+	assert.NoError(t, err)
+
+	records := collector.take()
+	if assert.EqualValues(t, 1, len(records)) {
+		assert.EqualValues(t, AuditInsert, records[0].Op)
+		assert.EqualValues(t, "admin", records[0].Actor)
+		assert.EqualValues(t, testEngine.TableName(user), records[0].TableName)
+		assert.EqualValues(t, user.Id, records[0].PK[0])
+		assert.EqualValues(t, 3, len(records[0].Changes))
+	}
+
+	_, err = testEngine.ID(user.Id).Update(&AuditUser{Name: "xlw"})
+	assert.NoError(t, err)
+
+	records = collector.take()
+	if assert.EqualValues(t, 1, len(records)) {
+		assert.EqualValues(t, AuditUpdate, records[0].Op)
+		assert.Nil(t, records[0].Actor)
+		if assert.EqualValues(t, 1, len(records[0].Changes)) {
+			assert.EqualValues(t, testEngine.GetColumnMapper().Obj2Table("Name"), records[0].Changes[0].Column)
+			assert.EqualValues(t, "lunny", records[0].Changes[0].Old)
+			assert.EqualValues(t, "xlw", records[0].Changes[0].New)
+		}
+	}
+
+	// rolled back changes are not audited
+	session := testEngine.NewSession()
+	assert.NoError(t, session.Begin())
+	_, err = session.ID(user.Id).Update(&AuditUser{Age: 20})
+	assert.NoError(t, err)
+	assert.NoError(t, session.Rollback())
+	session.Close()
+	assert.EqualValues(t, 0, len(collector.take()))
+
+	// the changes are audited after committed
+	session = testEngine.NewSession()
+	assert.NoError(t, session.Begin())
+	_, err = session.Insert(&AuditUser{Name: "a"}, &AuditUser{Name: "b"})
+	assert.NoError(t, err)
+	_, err = session.ID(user.Id).Update(&AuditUser{Age: 20})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(collector.take()))
+	assert.NoError(t, session.Commit())
+	session.Close()
+	assert.EqualValues(t, 3, len(collector.take()))
+
+	// nothing is changed
+	_, err = testEngine.ID(user.Id).Update(&AuditUser{Age: 20})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(collector.take()))
+
+	affected, err := testEngine.Where("age = ?", 0).Delete(new(AuditUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, affected)
+
+	records = collector.take()
+	if assert.EqualValues(t, 2, len(records)) {
+		assert.EqualValues(t, AuditDelete, records[0].Op)
+		assert.EqualValues(t, 3, len(records[0].Changes))
+		for _, change := range records[0].Changes {
+			assert.Nil(t, change.New)
+		}
+	}
+}
+
+func TestAuditBulkWrites(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type AuditBulkUser struct {
+		Id        int64 `xorm:"pk autoincr audit"`
+		Name      string
+		DeletedAt time.Time `xorm:"deleted"`
+	}
+
+	assertSync(t, new(AuditBulkUser))
+
+	var collector auditCollector
+	testEngine.SetAuditSink(&collector)
+	defer testEngine.SetAuditSink(nil)
+
+	session := testEngine.NewSession()
+	defer session.Close()
+	var users = []AuditBulkUser{{Name: "a"}, {Name: "b"}}
+	affected, err := session.InsertMulti(&users)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, affected)
+	records := collector.take()
+	if assert.EqualValues(t, 2, len(records)) {
+		assert.EqualValues(t, AuditInsert, records[0].Op)
+		assert.EqualValues(t, users[0].Id, records[0].PK[0])
+		assert.EqualValues(t, users[1].Id, records[1].PK[0])
+	}
+
+	affected, err = testEngine.In("id", users[0].Id, users[1].Id).Delete(new(AuditBulkUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, affected)
+	assert.EqualValues(t, 2, len(collector.take()))
+
+	deletedName := testEngine.GetColumnMapper().Obj2Table("DeletedAt")
+	affected, err = testEngine.Restore(&AuditBulkUser{Id: users[0].Id})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	records = collector.take()
+	if assert.EqualValues(t, 1, len(records)) {
+		assert.EqualValues(t, AuditUpdate, records[0].Op)
+		assert.EqualValues(t, users[0].Id, records[0].PK[0])
+		if assert.EqualValues(t, 1, len(records[0].Changes)) {
+			assert.EqualValues(t, deletedName, records[0].Changes[0].Column)
+		}
+	}
+
+	affected, err = testEngine.PurgeDeleted(new(AuditBulkUser), -time.Hour)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	records = collector.take()
+	if assert.EqualValues(t, 1, len(records)) {
+		assert.EqualValues(t, AuditDelete, records[0].Op)
+		assert.EqualValues(t, users[1].Id, records[0].PK[0])
+	}
+}
+
+func TestAuditTableSink(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type AuditSinkUser struct {
+		Id   int64
+		Name string
+	}
+
+	assertSync(t, new(AuditSinkUser))
+	assert.NoError(t, testEngine.EnableAudit(new(AuditSinkUser)))
+
+	engine, ok := testEngine.(*Engine)
+	if !ok {
+		engine = testEngine.(*EngineGroup).Engine
+	}
+	sink := &AuditTableSink{Engine: engine}
+	assert.NoError(t, testEngine.DropTables(DefaultAuditTable))
+	assert.NoError(t, sink.Sync())
+
+	_, err := testEngine.Context(WithActor(context.Background(), 1)).Insert(&AuditSinkUser{Name: "lunny"})
+	assert.NoError(t, err)
+
+	var logs []AuditLog
+	assert.NoError(t, testEngine.Table(DefaultAuditTable).Find(&logs))
+	if assert.EqualValues(t, 1, len(logs)) {
+		assert.EqualValues(t, testEngine.TableName(new(AuditSinkUser)), logs[0].TableName)
+		assert.EqualValues(t, "insert", logs[0].Op)
+		assert.EqualValues(t, "1", logs[0].Actor)
+		assert.EqualValues(t, "[1]", logs[0].Pk)
+
+		var changes []AuditChange
+		assert.NoError(t, json.Unmarshal([]byte(logs[0].Changes), &changes))
+		assert.EqualValues(t, 2, len(changes))
+	}
+
+	type AuditNoPK struct {
+		Name string
+	}
+	assert.Error(t, testEngine.EnableAudit(new(AuditNoPK)))
+}
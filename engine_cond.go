@@ -37,6 +37,11 @@ func (engine *Engine) buildConds(table *phoenixormcore.Table, bean interface{},
 		if col.SQLType.IsJson() {
 			continue
 		}
+		// the ciphertexts are not comparable, the blind indexes are used
+		encryption := engine.columnEncryption(col)
+		if encryption != nil && encryption.encrypted == nil {
+			continue
+		}
 
 		var colName string
 		if addedTableName {
@@ -58,7 +63,7 @@ func (engine *Engine) buildConds(table *phoenixormcore.Table, bean interface{},
 		}
 
 		if col.IsDeleted && !unscoped { // tag "deleted" is enabled
-			conds = append(conds, engine.CondDeleted(colName))
+			conds = append(conds, engine.condNotDeleted(col, colName))
 		}
 
 		fieldValue := *fieldValuePtr
@@ -163,8 +168,7 @@ func (engine *Engine) buildConds(table *phoenixormcore.Table, bean interface{},
 						val = bytes
 					}
 				} else {
-					engine.autoMapType(fieldValue)
-					if table, ok := engine.Tables[fieldValue.Type()]; ok {
+					if table, err := engine.autoMapType(fieldValue); err == nil {
 						if len(table.PrimaryKeys) == 1 {
 							pkField := reflect.Indirect(fieldValue).FieldByName(table.PKColumns()[0].FieldName)
 							// fix non-int pk issues
@@ -225,6 +229,11 @@ func (engine *Engine) buildConds(table *phoenixormcore.Table, bean interface{},
 			val = fieldValue.Interface()
 		}
 
+		if encryption != nil {
+			if val, err = engine.blindIndex(encryption.encrypted.Name, val); err != nil {
+				return nil, err
+			}
+		}
 		conds = append(conds, phoenixormbuilder.Eq{colName: val})
 	}
 
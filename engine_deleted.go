@@ -0,0 +1,199 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"reflect"
+	"time"
+
+	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// DeletedMode represents how the deleted column marks a record as soft deleted
+type DeletedMode int
+
+// deleted modes
+const (
+	// DeletedTime marks the records with the deleting time, it's the default mode
+	DeletedTime DeletedMode = iota
+	// DeletedBool marks the records with true or 1
+	DeletedBool
+	// DeletedUnix marks the records with the unix timestamp of the deleting time
+	DeletedUnix
+)
+
+// CondDeleted returns the conditions whether a record is not soft deleted, the
+// mode of the deleted column is DeletedTime if it's not given.
+func (engine *Engine) CondDeleted(colName string, mode ...DeletedMode) phoenixormbuilder.Cond {
+	var m = DeletedTime
+	if len(mode) > 0 {
+		m = mode[0]
+	}
+	return condNotDeleted(colName, engine.defaultNotDeletedValue(m))
+}
+
+func condNotDeleted(colName string, notDeleted interface{}) phoenixormbuilder.Cond {
+	if notDeleted == nil {
+		return phoenixormbuilder.IsNull{colName}
+	}
+	return phoenixormbuilder.IsNull{colName}.Or(phoenixormbuilder.Eq{colName: notDeleted})
+}
+
+func (engine *Engine) defaultNotDeletedValue(mode DeletedMode) interface{} {
+	switch mode {
+	case DeletedBool:
+		return false
+	case DeletedUnix:
+		return 0
+	}
+	if engine.dialect.DBType() == phoenixormcore.MSSQL {
+		return nil
+	}
+	return zeroTime1
+}
+
+func (engine *Engine) deletedMode(col *phoenixormcore.Column) DeletedMode {
+	if meta := engine.columnMeta(col); meta != nil {
+		return meta.deletedMode
+	}
+	return DeletedTime
+}
+
+// notDeletedValue returns the value besides NULL of the records which are not deleted
+func (engine *Engine) notDeletedValue(col *phoenixormcore.Column) interface{} {
+	meta := engine.columnMeta(col)
+	if meta == nil {
+		return engine.defaultNotDeletedValue(DeletedTime)
+	}
+	if meta.deletedSentinel != nil {
+		return meta.deletedSentinel
+	}
+	if meta.deletedMode == DeletedBool {
+		return engine.deletedBoolValue(col, false)
+	}
+	return engine.defaultNotDeletedValue(meta.deletedMode)
+}
+
+func (engine *Engine) deletedBoolValue(col *phoenixormcore.Column, deleted bool) interface{} {
+	if col.SQLType.Name == phoenixormcore.Bool || col.SQLType.Name == phoenixormcore.Boolean {
+		return deleted
+	}
+	if deleted {
+		return 1
+	}
+	return 0
+}
+
+// condNotDeleted returns the conditions of the records which are not deleted
+func (engine *Engine) condNotDeleted(col *phoenixormcore.Column, colName string) phoenixormbuilder.Cond {
+	return condNotDeleted(colName, engine.notDeletedValue(col))
+}
+
+// condOnlyDeleted returns the conditions of the soft deleted records
+func (engine *Engine) condOnlyDeleted(col *phoenixormcore.Column, colName string) phoenixormbuilder.Cond {
+	notDeleted := engine.notDeletedValue(col)
+	if notDeleted == nil {
+		return phoenixormbuilder.NotNull{colName}
+	}
+	return phoenixormbuilder.NotNull{colName}.And(phoenixormbuilder.Neq{colName: notDeleted})
+}
+
+// condDeletedBefore returns the conditions of the records deleted before t
+func (engine *Engine) condDeletedBefore(col *phoenixormcore.Column, colName string, t time.Time) (phoenixormbuilder.Cond, error) {
+	switch engine.deletedMode(col) {
+	case DeletedBool:
+		// there is no deleting time
+		return nil, ErrNotImplemented
+	case DeletedUnix:
+		return phoenixormbuilder.Lt{colName: t.Unix()}, nil
+	}
+	return phoenixormbuilder.Lt{colName: engine.formatColTime(col, t)}, nil
+}
+
+// deletedValue returns the value of the deleted column when a record is soft deleted
+func (engine *Engine) deletedValue(col *phoenixormcore.Column) (interface{}, time.Time) {
+	switch engine.deletedMode(col) {
+	case DeletedBool:
+		return engine.deletedBoolValue(col, true), time.Now().In(engine.TZLocation)
+	case DeletedUnix:
+		t := time.Now().In(engine.TZLocation)
+		return t.Unix(), t
+	}
+	return engine.nowTime(col)
+}
+
+// restoredValue returns the value of the deleted column when a record is restored
+func (engine *Engine) restoredValue(col *phoenixormcore.Column) interface{} {
+	notDeleted := engine.notDeletedValue(col)
+	if engine.deletedMode(col) == DeletedTime && col.Nullable &&
+		(notDeleted == nil || notDeleted == zeroTime1) {
+		return nil
+	}
+	if notDeleted == nil {
+		return zeroTime1
+	}
+	return notDeleted
+}
+
+// deletedByColumn returns the column which records who deleted the record
+func (engine *Engine) deletedByColumn(table *phoenixormcore.Table) *phoenixormcore.Column {
+	for _, col := range table.Columns() {
+		if meta := engine.columnMeta(col); meta != nil && meta.isDeletedBy {
+			return col
+		}
+	}
+	return nil
+}
+
+// setColumnDeleted sets the deleted field of bean
+func (engine *Engine) setColumnDeleted(bean interface{}, col *phoenixormcore.Column, t time.Time) {
+	if engine.deletedMode(col) != DeletedBool {
+		setColumnTime(bean, col, t)
+		return
+	}
+
+	v, err := col.ValueOf(bean)
+	if err != nil || !v.CanSet() {
+		return
+	}
+	switch v.Type().Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	}
+}
+
+// setColumnValue sets the field of bean if value could be converted to the field type
+func setColumnValue(bean interface{}, col *phoenixormcore.Column, value interface{}) {
+	v, err := col.ValueOf(bean)
+	if err != nil || !v.CanSet() {
+		return
+	}
+	if value == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Type().AssignableTo(v.Type()) {
+		v.Set(rv)
+	} else if rv.Kind() == v.Kind() && rv.Type().ConvertibleTo(v.Type()) {
+		v.Set(rv.Convert(v.Type()))
+	}
+}
+
+// deletedByRestoredValue returns the value of the deleted_by column when a record is restored
+func deletedByRestoredValue(col *phoenixormcore.Column) interface{} {
+	if col.Nullable {
+		return nil
+	}
+	if col.SQLType.IsNumeric() {
+		return 0
+	}
+	return ""
+}
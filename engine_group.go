@@ -6,6 +6,7 @@ package xorm
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
@@ -14,8 +15,14 @@ import (
 // EngineGroup defines an engine group
 type EngineGroup struct {
 	*Engine
-	slaves []*Engine
-	policy GroupPolicy
+	slaves       []*Engine
+	policy       GroupPolicy
+	stickyWindow time.Duration
+	health       *groupHealth
+	healthLock   sync.RWMutex
+	// positions maps the indexes of the slaves of the group to the indexes of
+	// the active view, -1 means the slave is ejected. It's nil except the views.
+	positions []int
 }
 
 // NewEngineGroup creates a new engine group
@@ -61,6 +68,8 @@ func NewEngineGroup(args1 interface{}, args2 interface{}, policies ...GroupPolic
 
 // Close the engine
 func (eg *EngineGroup) Close() error {
+	eg.StopHealthCheck()
+
 	err := eg.Engine.Close()
 	if err != nil {
 		return err
@@ -116,6 +125,27 @@ func (eg *EngineGroup) SetColumnMapper(mapper phoenixormcore.IMapper) {
 	}
 }
 
+// RegisterModels maps the structs of the beans on the master and the slaves
+func (eg *EngineGroup) RegisterModels(beans ...interface{}) error {
+	if err := eg.Engine.RegisterModels(beans...); err != nil {
+		return err
+	}
+	for i := 0; i < len(eg.slaves); i++ {
+		if err := eg.slaves[i].RegisterModels(beans...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterTag registers a handler of the tag name on the master and the slaves
+func (eg *EngineGroup) RegisterTag(name string, handler TagHandler) {
+	eg.Engine.RegisterTag(name, handler)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].RegisterTag(name, handler)
+	}
+}
+
 // SetConnMaxLifetime sets the maximum amount of time a connection may be reused.
 func (eg *EngineGroup) SetConnMaxLifetime(d time.Duration) {
 	eg.Engine.SetConnMaxLifetime(d)
@@ -132,6 +162,14 @@ func (eg *EngineGroup) SetDefaultCacher(cacher phoenixormcore.Cacher) {
 	}
 }
 
+// SetKeyProvider sets the KeyProvider of the encrypted columns on the master and the slaves
+func (eg *EngineGroup) SetKeyProvider(provider KeyProvider) {
+	eg.Engine.SetKeyProvider(provider)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetKeyProvider(provider)
+	}
+}
+
 // SetLogger set the new logger
 func (eg *EngineGroup) SetLogger(logger phoenixormcore.ILogger) {
 	eg.Engine.SetLogger(logger)
@@ -178,6 +216,46 @@ func (eg *EngineGroup) SetPolicy(policy GroupPolicy) *EngineGroup {
 	return eg
 }
 
+// SetSafetyPolicy sets the safety policy of master and slaves
+func (eg *EngineGroup) SetSafetyPolicy(policy *SafetyPolicy) {
+	eg.Engine.SetSafetyPolicy(policy)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetSafetyPolicy(policy)
+	}
+}
+
+// AddGlobalScope adds the global scope to master and slaves
+func (eg *EngineGroup) AddGlobalScope(bean interface{}, name string, fn func(*Session) *Session) {
+	eg.Engine.AddGlobalScope(bean, name, fn)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].AddGlobalScope(bean, name, fn)
+	}
+}
+
+// SetRowPolicy sets the row level policy of the table on master and slaves
+func (eg *EngineGroup) SetRowPolicy(bean interface{}, policy RowPolicy) {
+	eg.Engine.SetRowPolicy(bean, policy)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetRowPolicy(bean, policy)
+	}
+}
+
+// SetTenantResolver sets the TenantResolver of master and slaves
+func (eg *EngineGroup) SetTenantResolver(resolver TenantResolver) {
+	eg.Engine.SetTenantResolver(resolver)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetTenantResolver(resolver)
+	}
+}
+
+// SetTenantSchema sets the TenantSchemaResolver of master and slaves
+func (eg *EngineGroup) SetTenantSchema(resolver TenantSchemaResolver) {
+	eg.Engine.SetTenantSchema(resolver)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetTenantSchema(resolver)
+	}
+}
+
 // SetTableMapper set the table name mapping rule
 func (eg *EngineGroup) SetTableMapper(mapper phoenixormcore.IMapper) {
 	eg.Engine.TableMapper = mapper
@@ -204,13 +282,14 @@ func (eg *EngineGroup) ShowSQL(show ...bool) {
 
 // Slave returns one of the physical databases which is a slave according the policy
 func (eg *EngineGroup) Slave() *Engine {
-	switch len(eg.slaves) {
+	var view = eg.activeView()
+	switch len(view.slaves) {
 	case 0:
 		return eg.Engine
 	case 1:
-		return eg.slaves[0]
+		return view.slaves[0]
 	}
-	return eg.policy.Slave(eg)
+	return eg.policy.Slave(view)
 }
 
 // Slaves returns all the slaves
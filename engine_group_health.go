@@ -0,0 +1,397 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// ErrReplicationLagTooLarge will be recorded when the replication lag of a slave is greater than MaxLag
+var ErrReplicationLagTooLarge = errors.New("Replication lag is too large")
+
+// SlaveHealth represents the health state of a slave
+type SlaveHealth struct {
+	Engine    *Engine
+	Healthy   bool
+	Lag       time.Duration
+	LastCheck time.Time
+	LastError error
+}
+
+// ReplicationLagFunc returns the replication lag of a slave
+type ReplicationLagFunc func(ctx context.Context, slave *Engine) (time.Duration, error)
+
+// HealthCheckOptions represents the options of the health checker of EngineGroup
+type HealthCheckOptions struct {
+	// Interval is the interval between checks, default is 10 seconds
+	Interval time.Duration
+	// Timeout is the timeout of one check of a slave, default is 3 seconds
+	Timeout time.Duration
+	// MaxLag ejects the slaves whose replication lag is greater than it, zero means don't check the lag
+	MaxLag time.Duration
+	// LagFunc returns the replication lag, default is DefaultReplicationLag
+	LagFunc ReplicationLagFunc
+	// FailureThreshold is the consecutive failures before a slave is ejected, default is 1
+	FailureThreshold int
+	// RecoveryThreshold is the consecutive successes before a slave is re-admitted, default is 1
+	RecoveryThreshold int
+	// OnChange will be invoked in order when a slave turns healthy or unhealthy,
+	// it's invoked outside the checker so that it could stop or restart the
+	// health check, and the pending changes may be invoked after stopped
+	OnChange func(SlaveHealth)
+}
+
+type slaveState struct {
+	health    SlaveHealth
+	failures  int
+	successes int
+}
+
+type groupHealth struct {
+	opts   HealthCheckOptions
+	states []*slaveState
+	mutex  sync.RWMutex
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// the changes waiting for OnChange
+	changeMutex sync.Mutex
+	changes     []SlaveHealth
+	notifying   bool
+}
+
+// StartHealthCheck starts a background checker which pings every slave and checks
+// the replication lag, the unhealthy slaves will be ejected from the rotation and
+// be re-admitted after recovery. When none of the slaves is healthy, the reading
+// queries will go to master.
+func (eg *EngineGroup) StartHealthCheck(opts HealthCheckOptions) {
+	eg.StopHealthCheck()
+
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 3 * time.Second
+	}
+	if opts.LagFunc == nil {
+		opts.LagFunc = DefaultReplicationLag
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 1
+	}
+	if opts.RecoveryThreshold <= 0 {
+		opts.RecoveryThreshold = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &groupHealth{
+		opts:   opts,
+		states: make([]*slaveState, len(eg.slaves)),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	for i, slave := range eg.slaves {
+		h.states[i] = &slaveState{health: SlaveHealth{Engine: slave, Healthy: true}}
+	}
+
+	eg.healthLock.Lock()
+	eg.health = h
+	eg.healthLock.Unlock()
+
+	go eg.runHealthCheck(ctx, h)
+}
+
+// StopHealthCheck stops the health checker, all the slaves will be in rotation again
+func (eg *EngineGroup) StopHealthCheck() {
+	eg.healthLock.Lock()
+	h := eg.health
+	eg.health = nil
+	eg.healthLock.Unlock()
+
+	if h != nil {
+		h.cancel()
+		<-h.done
+	}
+}
+
+// Health returns the health states of the slaves, all the slaves are treated as
+// healthy when the health checker is not started
+func (eg *EngineGroup) Health() []SlaveHealth {
+	h := eg.getHealth()
+	var states = make([]SlaveHealth, len(eg.slaves))
+	if h == nil {
+		for i, slave := range eg.slaves {
+			states[i] = SlaveHealth{Engine: slave, Healthy: true}
+		}
+		return states
+	}
+
+	h.mutex.RLock()
+	for i, state := range h.states {
+		states[i] = state.health
+	}
+	h.mutex.RUnlock()
+	return states
+}
+
+// ActiveSlaves returns the slaves which are in rotation
+func (eg *EngineGroup) ActiveSlaves() []*Engine {
+	h := eg.getHealth()
+	if h == nil {
+		return eg.slaves
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	var slaves = make([]*Engine, 0, len(h.states))
+	for _, state := range h.states {
+		if state.health.Healthy {
+			slaves = append(slaves, state.health.Engine)
+		}
+	}
+	return slaves
+}
+
+// activeView returns a view of the group whose slaves are the ones in rotation,
+// so that the policies, including the custom ones, never choose an ejected slave
+func (eg *EngineGroup) activeView() *EngineGroup {
+	h := eg.getHealth()
+	if h == nil {
+		return eg
+	}
+
+	var view = &EngineGroup{
+		Engine:       eg.Engine,
+		slaves:       make([]*Engine, 0, len(eg.slaves)),
+		policy:       eg.policy,
+		stickyWindow: eg.stickyWindow,
+		positions:    make([]int, len(eg.slaves)),
+	}
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	for i, state := range h.states {
+		view.positions[i] = -1
+		if state.health.Healthy {
+			view.positions[i] = len(view.slaves)
+			view.slaves = append(view.slaves, state.health.Engine)
+		}
+	}
+	return view
+}
+
+// isSlaveActive returns true if the idx slave is in rotation
+func (eg *EngineGroup) isSlaveActive(idx int) bool {
+	h := eg.getHealth()
+	if h == nil {
+		return true
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return idx < len(h.states) && h.states[idx].health.Healthy
+}
+
+func (eg *EngineGroup) getHealth() *groupHealth {
+	eg.healthLock.RLock()
+	defer eg.healthLock.RUnlock()
+	return eg.health
+}
+
+func (eg *EngineGroup) runHealthCheck(ctx context.Context, h *groupHealth) {
+	defer func() {
+		h.done <- struct{}{}
+	}()
+
+	for {
+		eg.checkSlaves(ctx, h)
+
+		timer := time.NewTimer(h.opts.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (eg *EngineGroup) checkSlaves(ctx context.Context, h *groupHealth) {
+	var wg sync.WaitGroup
+	for i := range h.states {
+		wg.Add(1)
+		go func(state *slaveState) {
+			defer wg.Done()
+			lag, err := h.check(ctx, state.health.Engine)
+			if ctx.Err() != nil {
+				return
+			}
+			if changed, health := h.update(state, lag, err); changed {
+				eg.onHealthChange(h, health)
+			}
+		}(h.states[i])
+	}
+	wg.Wait()
+}
+
+func (h *groupHealth) check(ctx context.Context, slave *Engine) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.opts.Timeout)
+	defer cancel()
+
+	if err := slave.DB().PingContext(ctx); err != nil {
+		return 0, err
+	}
+	if h.opts.MaxLag <= 0 {
+		return 0, nil
+	}
+
+	lag, err := h.opts.LagFunc(ctx, slave)
+	if err == ErrNotImplemented {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if lag > h.opts.MaxLag {
+		return lag, ErrReplicationLagTooLarge
+	}
+	return lag, nil
+}
+
+func (h *groupHealth) update(state *slaveState, lag time.Duration, err error) (bool, SlaveHealth) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	state.health.Lag = lag
+	state.health.LastCheck = time.Now()
+	state.health.LastError = err
+
+	var changed bool
+	if err != nil {
+		state.successes = 0
+		state.failures++
+		if state.health.Healthy && state.failures >= h.opts.FailureThreshold {
+			state.health.Healthy = false
+			changed = true
+		}
+	} else {
+		state.failures = 0
+		state.successes++
+		if !state.health.Healthy && state.successes >= h.opts.RecoveryThreshold {
+			state.health.Healthy = true
+			changed = true
+		}
+	}
+	return changed, state.health
+}
+
+func (eg *EngineGroup) onHealthChange(h *groupHealth, health SlaveHealth) {
+	if health.Healthy {
+		eg.Engine.logger.Infof("[health] slave %s is recovered", health.Engine.DataSourceName())
+	} else {
+		eg.Engine.logger.Warnf("[health] slave %s is ejected: %v", health.Engine.DataSourceName(), health.LastError)
+	}
+	if h.opts.OnChange != nil {
+		h.notify(health)
+	}
+}
+
+// notify queues the change and invokes OnChange in another goroutine, so that
+// the checker never waits for OnChange which may wait for the checker
+func (h *groupHealth) notify(health SlaveHealth) {
+	h.changeMutex.Lock()
+	h.changes = append(h.changes, health)
+	if h.notifying {
+		h.changeMutex.Unlock()
+		return
+	}
+	h.notifying = true
+	h.changeMutex.Unlock()
+
+	go func() {
+		for {
+			h.changeMutex.Lock()
+			if len(h.changes) == 0 {
+				h.notifying = false
+				h.changeMutex.Unlock()
+				return
+			}
+			health := h.changes[0]
+			h.changes = h.changes[1:]
+			h.changeMutex.Unlock()
+
+			h.opts.OnChange(health)
+		}
+	}()
+}
+
+// DefaultReplicationLag returns the replication lag of a slave by a dialect
+// specific query, ErrNotImplemented will be returned if the dialect is not supported.
+func DefaultReplicationLag(ctx context.Context, slave *Engine) (time.Duration, error) {
+	switch slave.Dialect().DBType() {
+	case phoenixormcore.MYSQL:
+		return mysqlReplicationLag(ctx, slave)
+	case phoenixormcore.POSTGRES:
+		var seconds sql.NullFloat64
+		err := slave.DB().QueryRowContext(ctx,
+			"SELECT EXTRACT(EPOCH FROM (now() - pg_last_xact_replay_timestamp()))").Scan(&seconds)
+		if err != nil {
+			return 0, err
+		}
+		// it's not a standby server if replay timestamp is null
+		if !seconds.Valid {
+			return 0, nil
+		}
+		return time.Duration(seconds.Float64 * float64(time.Second)), nil
+	}
+	return 0, ErrNotImplemented
+}
+
+func mysqlReplicationLag(ctx context.Context, slave *Engine) (time.Duration, error) {
+	rows, err := slave.DB().QueryContext(ctx, "SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	// it's not a slave if there is no status
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+
+	var values = make([]sql.RawBytes, len(cols))
+	var dest = make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+
+	for i, col := range cols {
+		if col != "Seconds_Behind_Master" {
+			continue
+		}
+		// NULL means the replication is broken
+		if values[i] == nil {
+			return 0, errors.New("Replication is not running")
+		}
+		var seconds int64
+		if _, err = fmt.Sscanf(string(values[i]), "%d", &seconds); err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, ErrNotImplemented
+}
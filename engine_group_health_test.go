@@ -0,0 +1,193 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineGroupHealthCheck(t *testing.T) {
+	var engines = make([]*Engine, 3)
+	for i := range engines {
+		engine, err := NewEngine("sqlite3", "file::memory:")
+		assert.NoError(t, err)
+		engines[i] = engine
+	}
+	master, slave1, slave2 := engines[0], engines[1], engines[2]
+
+	eg, err := NewEngineGroup(master, []*Engine{slave1, slave2})
+	assert.NoError(t, err)
+	defer eg.Close()
+
+	// all the slaves are in rotation before the checker starts
+	assert.EqualValues(t, 2, len(eg.ActiveSlaves()))
+	for _, h := range eg.Health() {
+		assert.True(t, h.Healthy)
+	}
+
+	var lagging int32 = 1
+	var lock sync.Mutex
+	var changes []SlaveHealth
+	eg.StartHealthCheck(HealthCheckOptions{
+		Interval: 10 * time.Millisecond,
+		MaxLag:   time.Second,
+		LagFunc: func(ctx context.Context, slave *Engine) (time.Duration, error) {
+			if slave == slave1 && atomic.LoadInt32(&lagging) == 1 {
+				return time.Minute, nil
+			}
+			return 0, nil
+		},
+		OnChange: func(h SlaveHealth) {
+			lock.Lock()
+			changes = append(changes, h)
+			lock.Unlock()
+		},
+	})
+
+	assert.Eventually(t, func() bool {
+		return len(eg.ActiveSlaves()) == 1
+	}, time.Second, 5*time.Millisecond)
+	for i := 0; i < 10; i++ {
+		assert.True(t, eg.Slave() == slave2)
+	}
+	health := eg.Health()
+	assert.False(t, health[0].Healthy)
+	assert.EqualValues(t, time.Minute, health[0].Lag)
+	assert.EqualValues(t, ErrReplicationLagTooLarge, health[0].LastError)
+	assert.True(t, health[1].Healthy)
+
+	// the slave will be re-admitted after recovery
+	atomic.StoreInt32(&lagging, 0)
+	assert.Eventually(t, func() bool {
+		return len(eg.ActiveSlaves()) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	// OnChange is invoked outside the checker
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(changes) == 2
+	}, time.Second, 5*time.Millisecond)
+	lock.Lock()
+	assert.True(t, changes[0].Engine == slave1)
+	assert.False(t, changes[0].Healthy)
+	assert.True(t, changes[1].Engine == slave1)
+	assert.True(t, changes[1].Healthy)
+	lock.Unlock()
+
+	// reading falls back to master when none of the slaves is healthy
+	assert.NoError(t, slave1.DB().Close())
+	assert.NoError(t, slave2.DB().Close())
+	assert.Eventually(t, func() bool {
+		return len(eg.ActiveSlaves()) == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.True(t, eg.Slave() == master)
+	assert.True(t, eg.readEngine(context.Background()) == master)
+
+	eg.StopHealthCheck()
+	assert.EqualValues(t, 2, len(eg.ActiveSlaves()))
+}
+
+func TestGroupPolicyWithInactiveSlaves(t *testing.T) {
+	master := &Engine{}
+	slaves := []*Engine{{}, {}, {}}
+	eg := &EngineGroup{Engine: master, slaves: slaves}
+	eg.health = &groupHealth{states: []*slaveState{
+		{health: SlaveHealth{Engine: slaves[0], Healthy: false}},
+		{health: SlaveHealth{Engine: slaves[1], Healthy: true}},
+		{health: SlaveHealth{Engine: slaves[2], Healthy: false}},
+	}}
+
+	for _, policy := range []GroupPolicy{
+		RandomPolicy(),
+		WeightRandomPolicy([]int{2, 1, 3}),
+		RoundRobinPolicy(),
+		WeightRoundRobinPolicy([]int{2, 1, 3}),
+	} {
+		for i := 0; i < 10; i++ {
+			assert.True(t, policy.Slave(eg) == slaves[1])
+		}
+	}
+
+	eg.health.states[1].health.Healthy = false
+	assert.True(t, RandomPolicy().Slave(eg) == master)
+	assert.True(t, WeightRoundRobinPolicy([]int{2, 1, 3}).Slave(eg) == master)
+}
+
+func TestEngineGroupHealthCheckStopOnChange(t *testing.T) {
+	master, err := NewEngine("sqlite3", "file::memory:")
+	assert.NoError(t, err)
+	slave, err := NewEngine("sqlite3", "file::memory:")
+	assert.NoError(t, err)
+
+	eg, err := NewEngineGroup(master, []*Engine{slave})
+	assert.NoError(t, err)
+	defer eg.Close()
+
+	// stopping the checker in OnChange should not wait for itself
+	var stopped = make(chan struct{}, 1)
+	eg.StartHealthCheck(HealthCheckOptions{
+		Interval: 10 * time.Millisecond,
+		MaxLag:   time.Second,
+		LagFunc: func(ctx context.Context, slave *Engine) (time.Duration, error) {
+			return time.Minute, nil
+		},
+		OnChange: func(h SlaveHealth) {
+			eg.StopHealthCheck()
+			stopped <- struct{}{}
+		},
+	})
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("StopHealthCheck in OnChange is deadlocked")
+	}
+	assert.EqualValues(t, 1, len(eg.ActiveSlaves()))
+}
+
+func TestCustomGroupPolicyWithInactiveSlaves(t *testing.T) {
+	master := &Engine{}
+	slaves := []*Engine{{}, {}, {}}
+	var chosen [][]*Engine
+	eg := &EngineGroup{Engine: master, slaves: slaves, policy: GroupPolicyHandler(func(g *EngineGroup) *Engine {
+		chosen = append(chosen, g.Slaves())
+		return g.Slaves()[0]
+	})}
+	eg.health = &groupHealth{states: []*slaveState{
+		{health: SlaveHealth{Engine: slaves[0], Healthy: false}},
+		{health: SlaveHealth{Engine: slaves[1], Healthy: true}},
+		{health: SlaveHealth{Engine: slaves[2], Healthy: true}},
+	}}
+
+	// the custom policy never sees the ejected slave
+	assert.True(t, eg.Slave() == slaves[1])
+	assert.EqualValues(t, 1, len(chosen))
+	assert.EqualValues(t, []*Engine{slaves[1], slaves[2]}, chosen[0])
+
+	// the weights are kept on the slaves in rotation
+	eg.policy = WeightRoundRobinPolicy([]int{5, 1, 2})
+	var counts = make(map[*Engine]int)
+	for i := 0; i < 9; i++ {
+		counts[eg.Slave()]++
+	}
+	assert.EqualValues(t, 0, counts[slaves[0]])
+	assert.EqualValues(t, 3, counts[slaves[1]])
+	assert.EqualValues(t, 6, counts[slaves[2]])
+
+	// the policy is not invoked when only one slave is in rotation
+	eg.health.states[2].health.Healthy = false
+	eg.policy = GroupPolicyHandler(func(g *EngineGroup) *Engine {
+		t.Fatal("the policy should not be invoked")
+		return nil
+	})
+	assert.True(t, eg.Slave() == slaves[1])
+}
@@ -10,7 +10,9 @@ import (
 	"time"
 )
 
-// GroupPolicy is be used by chosing the current slave from slaves
+// GroupPolicy is be used by chosing the current slave from slaves. When the
+// health checker is started, the slaves of the group passed to Slave are only
+// the ones in rotation.
 type GroupPolicy interface {
 	Slave(*EngineGroup) *Engine
 }
@@ -23,11 +25,40 @@ func (h GroupPolicyHandler) Slave(eg *EngineGroup) *Engine {
 	return h(eg)
 }
 
+// activeWeights returns the weighted indexes of the slaves which are in rotation,
+// the weights of an active view are mapped to the indexes of its slaves
+func activeWeights(g *EngineGroup, rands []int) []int {
+	var slaves = g.Slaves()
+	var actives = make([]int, 0, len(rands))
+	for _, idx := range rands {
+		if g.positions != nil {
+			if idx >= len(g.positions) {
+				idx = len(g.positions) - 1
+			}
+			if idx = g.positions[idx]; idx >= 0 {
+				actives = append(actives, idx)
+			}
+			continue
+		}
+		if idx >= len(slaves) {
+			idx = len(slaves) - 1
+		}
+		if g.isSlaveActive(idx) {
+			actives = append(actives, idx)
+		}
+	}
+	return actives
+}
+
 // RandomPolicy implmentes randomly chose the slave of slaves
 func RandomPolicy() GroupPolicyHandler {
 	var r = rand.New(rand.NewSource(time.Now().UnixNano()))
 	return func(g *EngineGroup) *Engine {
-		return g.Slaves()[r.Intn(len(g.Slaves()))]
+		var slaves = g.ActiveSlaves()
+		if len(slaves) == 0 {
+			return g.Master()
+		}
+		return slaves[r.Intn(len(slaves))]
 	}
 }
 
@@ -42,12 +73,11 @@ func WeightRandomPolicy(weights []int) GroupPolicyHandler {
 	var r = rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	return func(g *EngineGroup) *Engine {
-		var slaves = g.Slaves()
-		idx := rands[r.Intn(len(rands))]
-		if idx >= len(slaves) {
-			idx = len(slaves) - 1
+		var actives = activeWeights(g, rands)
+		if len(actives) == 0 {
+			return g.Master()
 		}
-		return slaves[idx]
+		return g.Slaves()[actives[r.Intn(len(actives))]]
 	}
 }
 
@@ -55,7 +85,10 @@ func RoundRobinPolicy() GroupPolicyHandler {
 	var pos = -1
 	var lock sync.Mutex
 	return func(g *EngineGroup) *Engine {
-		var slaves = g.Slaves()
+		var slaves = g.ActiveSlaves()
+		if len(slaves) == 0 {
+			return g.Master()
+		}
 
 		lock.Lock()
 		defer lock.Unlock()
@@ -79,26 +112,28 @@ func WeightRoundRobinPolicy(weights []int) GroupPolicyHandler {
 	var lock sync.Mutex
 
 	return func(g *EngineGroup) *Engine {
-		var slaves = g.Slaves()
+		var actives = activeWeights(g, rands)
+		if len(actives) == 0 {
+			return g.Master()
+		}
+
 		lock.Lock()
 		defer lock.Unlock()
 		pos++
-		if pos >= len(rands) {
+		if pos >= len(actives) {
 			pos = 0
 		}
-
-		idx := rands[pos]
-		if idx >= len(slaves) {
-			idx = len(slaves) - 1
-		}
-		return slaves[idx]
+		return g.Slaves()[actives[pos]]
 	}
 }
 
 // LeastConnPolicy implements GroupPolicy, every time will get the least connections slave
 func LeastConnPolicy() GroupPolicyHandler {
 	return func(g *EngineGroup) *Engine {
-		var slaves = g.Slaves()
+		var slaves = g.ActiveSlaves()
+		if len(slaves) == 0 {
+			return g.Master()
+		}
 		connections := 0
 		idx := 0
 		for i := 0; i < len(slaves); i++ {
@@ -0,0 +1,101 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+type groupRoute int
+
+const (
+	groupRouteDefault groupRoute = iota
+	groupRouteMaster
+	groupRouteSlave
+)
+
+type groupRouteKey struct{}
+
+type groupWriteTrackerKey struct{}
+
+// groupWriteTracker records the last write time of a context
+type groupWriteTracker struct {
+	lastWrite int64
+}
+
+// UseMaster returns a context which makes all the queries with it go to master
+func (eg *EngineGroup) UseMaster(ctx context.Context) context.Context {
+	return context.WithValue(ctx, groupRouteKey{}, groupRouteMaster)
+}
+
+// UseSlave returns a context which makes the reading queries with it go to slaves
+// even if there are writes on it. Queries in transactions always go to master.
+func (eg *EngineGroup) UseSlave(ctx context.Context) context.Context {
+	return context.WithValue(ctx, groupRouteKey{}, groupRouteSlave)
+}
+
+// ReadYourWrites returns a context which records the writes on it, the reading
+// queries with the context will go to master after a write so that they will
+// not miss the written rows because of replication lag. The sticky duration
+// could be changed via SetStickyWindow.
+func (eg *EngineGroup) ReadYourWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(groupWriteTrackerKey{}).(*groupWriteTracker); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, groupWriteTrackerKey{}, &groupWriteTracker{})
+}
+
+// SetStickyWindow sets how long the reading queries stay on master after a write
+// on a context created by ReadYourWrites, zero means for the whole life of the context.
+func (eg *EngineGroup) SetStickyWindow(d time.Duration) *EngineGroup {
+	eg.stickyWindow = d
+	return eg
+}
+
+// markGroupWrite records a write on the context
+func markGroupWrite(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	if tracker, ok := ctx.Value(groupWriteTrackerKey{}).(*groupWriteTracker); ok {
+		atomic.StoreInt64(&tracker.lastWrite, time.Now().UnixNano())
+	}
+}
+
+// readEngine returns the engine which the reading queries with ctx should go to
+func (eg *EngineGroup) readEngine(ctx context.Context) *Engine {
+	if ctx == nil {
+		return eg.Slave()
+	}
+
+	switch route, _ := ctx.Value(groupRouteKey{}).(groupRoute); route {
+	case groupRouteMaster:
+		return eg.Engine
+	case groupRouteSlave:
+		return eg.Slave()
+	}
+
+	if tracker, ok := ctx.Value(groupWriteTrackerKey{}).(*groupWriteTracker); ok {
+		lastWrite := atomic.LoadInt64(&tracker.lastWrite)
+		if lastWrite > 0 && (eg.stickyWindow <= 0 ||
+			time.Since(time.Unix(0, lastWrite)) < eg.stickyWindow) {
+			return eg.Engine
+		}
+	}
+	return eg.Slave()
+}
+
+// readDB returns the database which the reading queries should go to, the
+// sessions in transactions always use master
+func (session *Session) readDB() *phoenixormcore.DB {
+	if session.sessionType == groupSession && session.isAutoCommit && session.engine.engineGroup != nil {
+		return session.engine.engineGroup.readEngine(session.ctx).DB()
+	}
+	return session.DB()
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineGroupReadRoute(t *testing.T) {
+	master, slave := &Engine{}, &Engine{}
+	eg := &EngineGroup{Engine: master, slaves: []*Engine{slave}, policy: RoundRobinPolicy()}
+
+	ctx := context.Background()
+	assert.True(t, eg.readEngine(ctx) == slave)
+	assert.True(t, eg.readEngine(eg.UseMaster(ctx)) == master)
+	assert.True(t, eg.readEngine(eg.UseSlave(eg.UseMaster(ctx))) == slave)
+
+	// writes without tracker will not change the route
+	markGroupWrite(ctx)
+	assert.True(t, eg.readEngine(ctx) == slave)
+
+	ctx = eg.ReadYourWrites(ctx)
+	assert.True(t, eg.ReadYourWrites(ctx) == ctx)
+	assert.True(t, eg.readEngine(ctx) == slave)
+	markGroupWrite(ctx)
+	assert.True(t, eg.readEngine(ctx) == master)
+	assert.True(t, eg.readEngine(eg.UseSlave(ctx)) == slave)
+
+	eg.SetStickyWindow(20 * time.Millisecond)
+	assert.True(t, eg.readEngine(ctx) == master)
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, eg.readEngine(ctx) == slave)
+}
+
+func TestEngineGroupReadYourWrites(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	eg, ok := testEngine.(*EngineGroup)
+	if !ok {
+		t.Skip("only for engine group")
+		return
+	}
+
+	type ReadYourWrites struct {
+		Id   int64
+		Name string
+	}
+
+	assert.NoError(t, eg.Sync2(new(ReadYourWrites)))
+
+	ctx := eg.ReadYourWrites(context.Background())
+	sess := eg.Context(ctx)
+	_, err := sess.Insert(&ReadYourWrites{Name: "xorm"})
+	assert.NoError(t, err)
+
+	var r ReadYourWrites
+	has, err := eg.Context(ctx).Where("name = ?", "xorm").Get(&r)
+	assert.NoError(t, err)
+	assert.True(t, has)
+}
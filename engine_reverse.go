@@ -0,0 +1,246 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// ReverseOptions are the options of Engine.Reverse
+type ReverseOptions struct {
+	// Package is the package name of the generated code
+	Package string
+	// Mapper maps the table and column names to the Go names, SnakeMapper will
+	// be used if it's nil
+	Mapper phoenixormcore.IMapper
+	// Include are the patterns of path.Match, only the matched tables will be
+	// generated if it's not empty
+	Include []string
+	// Exclude are the patterns of the tables which will not be generated
+	Exclude []string
+	// TypeOverrides are the Go types of the columns, the keys could be
+	// "table.column", "column" or a SQL type name like "DECIMAL"
+	TypeOverrides map[string]string
+	// Imports are the imports could be used by TypeOverrides, an import is a
+	// path or a name followed by a path. Only the used ones will be written.
+	Imports []string
+	// Generator is the name of the generator written to the header
+	Generator string
+}
+
+var (
+	createdColumnNames = []string{"created", "created_at", "create_at", "create_time", "created_time"}
+	updatedColumnNames = []string{"updated", "updated_at", "update_at", "update_time", "updated_time"}
+)
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (opts *ReverseOptions) match(tableName string) (bool, error) {
+	for _, pattern := range opts.Exclude {
+		matched, err := path.Match(pattern, tableName)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return false, nil
+		}
+	}
+	if len(opts.Include) == 0 {
+		return true, nil
+	}
+	for _, pattern := range opts.Include {
+		matched, err := path.Match(pattern, tableName)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// goName returns a valid Go identifier of the name mapped by mapper
+func goName(mapper phoenixormcore.IMapper, name string) string {
+	s := []rune(mapper.Table2Obj(name))
+	for i, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			s[i] = '_'
+		}
+	}
+	if len(s) == 0 || !unicode.IsLetter(s[0]) {
+		s = append([]rune("X"), s...)
+	}
+	s[0] = unicode.ToUpper(s[0])
+	return string(s)
+}
+
+// sqlTypeTag returns the SQL type of the column in tag, e.g. VARCHAR(255)
+func sqlTypeTag(col *phoenixormcore.Column) string {
+	var name = strings.ToUpper(col.SQLType.Name)
+	if col.Length > 0 && col.Length2 > 0 {
+		return fmt.Sprintf("%s(%d,%d)", name, col.Length, col.Length2)
+	}
+	if col.Length > 0 {
+		return fmt.Sprintf("%s(%d)", name, col.Length)
+	}
+	return name
+}
+
+// reverseType returns the Go type of the column
+func (opts *ReverseOptions) reverseType(imports *columnsImports, table *phoenixormcore.Table, col *phoenixormcore.Column) string {
+	for _, key := range []string{
+		table.Name + "." + col.Name,
+		col.Name,
+		strings.ToUpper(col.SQLType.Name),
+	} {
+		if typ, ok := opts.TypeOverrides[key]; ok {
+			imports.use(typ)
+			return typ
+		}
+	}
+	return imports.typeExpr(phoenixormcore.SQLType2Type(col.SQLType))
+}
+
+// reverseTag returns the xorm tag of the column
+func reverseTag(mapper phoenixormcore.IMapper, table *phoenixormcore.Table, col *phoenixormcore.Column, fieldName, typ string) string {
+	var tags []string
+	if mapper.Obj2Table(fieldName) != col.Name {
+		tags = append(tags, "'"+col.Name+"'")
+	}
+	if col.IsPrimaryKey {
+		tags = append(tags, "pk")
+	}
+	if col.IsAutoIncrement {
+		tags = append(tags, "autoincr")
+	}
+	if !col.Nullable && !col.IsPrimaryKey {
+		tags = append(tags, "not null")
+	}
+	tags = append(tags, sqlTypeTag(col))
+	if col.Default != "" && !strings.EqualFold(col.Default, "NULL") {
+		tags = append(tags, "default "+col.Default)
+	}
+	if typ == "time.Time" {
+		if containsName(createdColumnNames, col.Name) {
+			tags = append(tags, "created")
+		} else if containsName(updatedColumnNames, col.Name) {
+			tags = append(tags, "updated")
+		}
+	}
+
+	var names = make([]string, 0, len(col.Indexes))
+	for name := range col.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		index, ok := table.Indexes[name]
+		if !ok {
+			continue
+		}
+		var tag = "index"
+		if index.Type == phoenixormcore.UniqueType {
+			tag = "unique"
+		}
+		if len(index.Cols) > 1 || name != col.Name {
+			tag = fmt.Sprintf("%s(%s)", tag, name)
+		}
+		tags = append(tags, tag)
+	}
+	return strings.Join(tags, " ")
+}
+
+// ReverseTables writes the Go structs of the tables with xorm tags and
+// TableName methods
+func ReverseTables(w io.Writer, tables []*phoenixormcore.Table, opts ReverseOptions) error {
+	if opts.Package == "" {
+		return errors.New("package name is required")
+	}
+	if opts.Mapper == nil {
+		opts.Mapper = phoenixormcore.SnakeMapper{}
+	}
+	if opts.Generator == "" {
+		opts.Generator = "xorm"
+	}
+	imports := newColumnsImports(&ColumnsOptions{Imports: opts.Imports})
+
+	var selected = make([]*phoenixormcore.Table, 0, len(tables))
+	for _, table := range tables {
+		matched, err := opts.match(table.Name)
+		if err != nil {
+			return err
+		}
+		if matched {
+			selected = append(selected, table)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Name < selected[j].Name
+	})
+
+	var body bytes.Buffer
+	for _, table := range selected {
+		structName := goName(opts.Mapper, table.Name)
+		fmt.Fprintf(&body, "\n// %s is the struct of table %s\n", structName, table.Name)
+		fmt.Fprintf(&body, "type %s struct {\n", structName)
+		for _, col := range table.Columns() {
+			fieldName := goName(opts.Mapper, col.Name)
+			typ := opts.reverseType(imports, table, col)
+			tag := reverseTag(opts.Mapper, table, col, fieldName, typ)
+			fmt.Fprintf(&body, "\t%s %s `xorm:%q`\n", fieldName, typ, tag)
+		}
+		body.WriteString("}\n")
+
+		fmt.Fprintf(&body, "\n// TableName returns the name of table %s\n", table.Name)
+		fmt.Fprintf(&body, "func (%s) TableName() string {\n\treturn %q\n}\n", structName, table.Name)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by %s.\n\n", opts.Generator)
+	fmt.Fprintf(&buf, "package %s\n\n", opts.Package)
+	imports.write(&buf)
+	buf.Write(body.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("format generated code: %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// Reverse reads the tables, columns and indexes from database by DBMetas and
+// writes the Go structs of them, for example:
+//
+//	engine.Reverse(os.Stdout, xorm.ReverseOptions{
+//		Package: "models",
+//		Include: []string{"user*"},
+//		TypeOverrides: map[string]string{"DECIMAL": "float64"},
+//	})
+func (engine *Engine) Reverse(w io.Writer, opts ReverseOptions) error {
+	tables, err := engine.DBMetas()
+	if err != nil {
+		return err
+	}
+	return ReverseTables(w, tables, opts)
+}
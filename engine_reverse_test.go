@@ -0,0 +1,102 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+type ReverseUser struct {
+	Id        int64
+	Name      string    `xorm:"varchar(50) not null unique"`
+	Age       int       `xorm:"index default 18"`
+	Price     string    `xorm:"decimal(10,2)"`
+	CreatedAt time.Time `xorm:"created"`
+}
+
+func TestReverse(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(ReverseUser))
+
+	tableName := testEngine.TableName(new(ReverseUser))
+	colName := testEngine.GetColumnMapper().Obj2Table
+
+	var buf bytes.Buffer
+	err := testEngine.Reverse(&buf, ReverseOptions{
+		Package: "models",
+		Include: []string{tableName},
+		TypeOverrides: map[string]string{
+			tableName + "." + colName("Price"): "decimal.Decimal",
+		},
+		Imports: []string{`"github.com/shopspring/decimal"`, `"os"`},
+	})
+	assert.NoError(t, err)
+
+	src := buf.String()
+	_, err = parser.ParseFile(token.NewFileSet(), "models.go", src, 0)
+	assert.NoError(t, err)
+
+	assert.Contains(t, src, "package models")
+	assert.Contains(t, src, `"github.com/shopspring/decimal"`)
+	assert.Contains(t, src, `"time"`)
+	assert.NotContains(t, src, `"os"`)
+	assert.Contains(t, src, "TableName() string {\n\treturn \""+tableName+"\"")
+	assert.Contains(t, src, "pk autoincr")
+	assert.Contains(t, src, "decimal.Decimal")
+	assert.Contains(t, src, "time.Time")
+	assert.Contains(t, src, "created")
+	assert.Contains(t, src, "unique")
+	assert.Contains(t, src, "index")
+	assert.Contains(t, src, "default 18")
+
+	buf.Reset()
+	err = testEngine.Reverse(&buf, ReverseOptions{
+		Package: "models",
+		Include: []string{tableName},
+		Exclude: []string{tableName},
+	})
+	assert.NoError(t, err)
+	assert.NotContains(t, buf.String(), "type ")
+}
+
+func TestReverseTables(t *testing.T) {
+	table := phoenixormcore.NewEmptyTable()
+	table.Name = "user_order"
+	id := phoenixormcore.NewColumn("id", "", phoenixormcore.SQLType{Name: phoenixormcore.BigInt}, 0, 0, false)
+	id.IsPrimaryKey = true
+	id.IsAutoIncrement = true
+	table.AddColumn(id)
+	userID := phoenixormcore.NewColumn("user_id", "", phoenixormcore.SQLType{Name: phoenixormcore.Int}, 0, 0, false)
+	userID.Indexes["s"] = phoenixormcore.IndexType
+	table.AddColumn(userID)
+	title := phoenixormcore.NewColumn("2title", "", phoenixormcore.SQLType{Name: phoenixormcore.Varchar}, 100, 0, true)
+	title.Indexes["s"] = phoenixormcore.IndexType
+	table.AddColumn(title)
+	updated := phoenixormcore.NewColumn("updated_at", "", phoenixormcore.SQLType{Name: phoenixormcore.DateTime}, 0, 0, true)
+	table.AddColumn(updated)
+	table.Indexes["s"] = &phoenixormcore.Index{Name: "s", Type: phoenixormcore.IndexType, Cols: []string{"user_id", "2title"}}
+
+	var buf bytes.Buffer
+	err := ReverseTables(&buf, []*phoenixormcore.Table{table}, ReverseOptions{
+		Package: "models",
+		Mapper:  phoenixormcore.LintGonicMapper,
+	})
+	assert.NoError(t, err)
+	src := buf.String()
+	assert.Contains(t, src, "type UserOrder struct {")
+	assert.Contains(t, src, "ID        int64     `xorm:\"pk autoincr BIGINT\"`")
+	assert.Contains(t, src, "UserID    int       `xorm:\"not null INT index(s)\"`")
+	assert.Contains(t, src, "X2title   string    `xorm:\"'2title' VARCHAR(100) index(s)\"`")
+	assert.Contains(t, src, "UpdatedAt time.Time `xorm:\"DATETIME updated\"`")
+
+	assert.Error(t, ReverseTables(&buf, nil, ReverseOptions{}))
+}
@@ -0,0 +1,290 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// SafetyPolicy protects the database from dangerous statements
+type SafetyPolicy struct {
+	// BlockFullTable blocks UPDATE and DELETE without any condition unless
+	// AllowFullTable is invoked on the session
+	BlockFullTable bool
+	// MaxAffectedRows aborts and rolls back the UPDATE and DELETE which affect
+	// more rows than it, zero means no limit. In a transaction only the
+	// offending statement is rolled back.
+	MaxAffectedRows int64
+	// ForbidDDL forbids DDL unless the context of the session is created by
+	// WithMigration
+	ForbidDDL bool
+	// LargeTables are the tables which could not be found without limit
+	LargeTables []string
+}
+
+func (policy *SafetyPolicy) isLargeTable(tableName string) bool {
+	for _, name := range policy.LargeTables {
+		if strings.EqualFold(name, tableName) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSafetyPolicy sets the safety policy, nil means no protection
+func (engine *Engine) SetSafetyPolicy(policy *SafetyPolicy) {
+	engine.safetyPolicy = policy
+}
+
+// GetSafetyPolicy returns the safety policy
+func (engine *Engine) GetSafetyPolicy() *SafetyPolicy {
+	return engine.safetyPolicy
+}
+
+type migrationContextKey struct{}
+
+// WithMigration returns a context which permits the sessions with it to execute
+// DDL even if it's forbidden by the safety policy
+func WithMigration(ctx context.Context) context.Context {
+	return context.WithValue(ctx, migrationContextKey{}, true)
+}
+
+// inMigration returns true if the context permits DDL
+func inMigration(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	migrating, _ := ctx.Value(migrationContextKey{}).(bool)
+	return migrating
+}
+
+// InMigration runs fn as a migration with a session whose context is created by
+// WithMigration, DDL is allowed on the session but not on the other sessions.
+func (engine *Engine) InMigration(fn func(session *Session) error) error {
+	session := engine.NewSession()
+	defer session.Close()
+
+	var ctx = session.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return fn(session.Context(WithMigration(ctx)))
+}
+
+// AllowFullTable allows updating or deleting without any condition
+func (engine *Engine) AllowFullTable() *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.AllowFullTable()
+}
+
+// AllowFullTable allows updating or deleting without any condition
+func (session *Session) AllowFullTable() *Session {
+	session.statement.allowFullTable = true
+	return session
+}
+
+// checkFullTable checks the UPDATE or DELETE without condition
+func (session *Session) checkFullTable(op, tableName string) error {
+	policy := session.engine.safetyPolicy
+	if policy == nil || !policy.BlockFullTable || session.statement.allowFullTable {
+		return nil
+	}
+	return ErrFullTableOperation{Op: op, TableName: tableName}
+}
+
+// checkUnlimitedFind checks the finding without limit on large tables
+func (session *Session) checkUnlimitedFind(tableName string) error {
+	policy := session.engine.safetyPolicy
+	if policy == nil || session.statement.noLimitCheck || !policy.isLargeTable(tableName) {
+		return nil
+	}
+	if session.statement.LimitN != nil && *session.statement.LimitN > 0 {
+		return nil
+	}
+	return ErrUnlimitedFind{TableName: tableName}
+}
+
+// checkRawSQL checks the raw UPDATE or DELETE without condition
+func (session *Session) checkRawSQL(sqlStr string) error {
+	if policy := session.engine.safetyPolicy; policy == nil || !policy.BlockFullTable {
+		return nil
+	}
+
+	words := sqlWords(sqlStr, 0)
+	if len(words) == 0 {
+		return nil
+	}
+	var op = strings.ToUpper(words[0])
+	if op != "UPDATE" && op != "DELETE" {
+		return nil
+	}
+	// only the conditions of the statement itself count, not the ones in subqueries
+	var limited bool
+	sqlScan(sqlStr, func(word string, depth int) bool {
+		if depth == 0 && (strings.EqualFold(word, "WHERE") || strings.EqualFold(word, "LIMIT")) {
+			limited = true
+		}
+		return !limited
+	})
+	if limited {
+		return nil
+	}
+
+	var tableName string
+	if op == "DELETE" && len(words) > 2 && strings.EqualFold(words[1], "FROM") {
+		tableName = words[2]
+	} else if op == "UPDATE" && len(words) > 1 {
+		tableName = words[1]
+	}
+	return session.checkFullTable(op, strings.Trim(tableName, "`\"[]"))
+}
+
+// checkExec checks DDL outside migrations and returns true if the affected rows
+// of the statement should be limited
+func (session *Session) checkExec(sqlStr string) (bool, error) {
+	policy := session.engine.safetyPolicy
+	if policy == nil {
+		return false, nil
+	}
+
+	words := sqlWords(sqlStr, 1)
+	if len(words) == 0 {
+		return false, nil
+	}
+	switch strings.ToUpper(words[0]) {
+	case "CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME":
+		if policy.ForbidDDL && !inMigration(session.ctx) {
+			return false, ErrDDLNotAllowed{SQL: sqlStr}
+		}
+	case "UPDATE", "DELETE":
+		return policy.MaxAffectedRows > 0, nil
+	}
+	return false, nil
+}
+
+// execLimited executes the UPDATE or DELETE in a transaction and rolls back it
+// when too many rows are affected. In an explicit transaction the statement is
+// executed after a savepoint and only the statement is rolled back, the earlier
+// statements of the transaction are kept.
+func (session *Session) execLimited(limit int64, sqlStr string, args ...interface{}) (sql.Result, error) {
+	if !session.isAutoCommit {
+		savepoint, err := session.savepoint()
+		if err != nil {
+			return nil, err
+		}
+		res, err := session.tx.ExecContext(session.ctx, sqlStr, args...)
+		if err != nil {
+			session.rollbackToSavepoint(savepoint)
+			return nil, err
+		}
+		if affected, err := res.RowsAffected(); err == nil && affected > limit {
+			if err := session.rollbackToSavepoint(savepoint); err != nil {
+				return nil, err
+			}
+			return nil, ErrTooManyAffectedRows{SQL: sqlStr, Limit: limit, Affected: affected}
+		}
+		if err := session.releaseSavepoint(savepoint); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	tx, err := session.DB().BeginTx(session.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tx.ExecContext(session.ctx, sqlStr, args...)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected > limit {
+		tx.Rollback()
+		return nil, ErrTooManyAffectedRows{SQL: sqlStr, Limit: limit, Affected: affected}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// sqlWords returns at most n words of sqlStr except the quoted
+// strings and comments, n <= 0 means all the words
+func sqlWords(sqlStr string, n int) []string {
+	var words []string
+	sqlScan(sqlStr, func(word string, depth int) bool {
+		words = append(words, word)
+		return n <= 0 || len(words) < n
+	})
+	return words
+}
+
+// sqlScan calls fn with the words of sqlStr except the quoted strings and
+// comments, depth is the nesting level of the parentheses around the word.
+// Quoted identifiers are kept as single words. The scanning stops when fn
+// returns false.
+func sqlScan(sqlStr string, fn func(word string, depth int) bool) {
+	var word strings.Builder
+	var depth int
+	var stopped bool
+	var flush = func() {
+		if word.Len() > 0 && !stopped {
+			stopped = !fn(word.String(), depth)
+		}
+		word.Reset()
+	}
+
+	for i := 0; i < len(sqlStr) && !stopped; i++ {
+		c := sqlStr[i]
+		switch {
+		case c == '\'':
+			flush()
+			for i++; i < len(sqlStr) && sqlStr[i] != '\''; i++ {
+			}
+		case c == '"' || c == '`' || c == '[':
+			var end = c
+			if c == '[' {
+				end = ']'
+			}
+			word.WriteByte(c)
+			for i++; i < len(sqlStr); i++ {
+				word.WriteByte(sqlStr[i])
+				if sqlStr[i] == end {
+					break
+				}
+			}
+		case c == '-' && i+1 < len(sqlStr) && sqlStr[i+1] == '-':
+			flush()
+			for ; i < len(sqlStr) && sqlStr[i] != '\n'; i++ {
+			}
+		case c == '/' && i+1 < len(sqlStr) && sqlStr[i+1] == '*':
+			flush()
+			if end := strings.Index(sqlStr[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(sqlStr)
+			}
+		case c == '(':
+			flush()
+			depth++
+		case c == ')':
+			flush()
+			if depth > 0 {
+				depth--
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' || c == ';' || c == '=':
+			flush()
+		default:
+			word.WriteByte(c)
+		}
+	}
+	flush()
+}
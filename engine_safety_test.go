@@ -0,0 +1,151 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLWords(t *testing.T) {
+	assert.EqualValues(t, []string{"update", "`user`", "set", "name", "?", "where", "id", "?"},
+		sqlWords("update `user` set name=? where id=?", 0))
+	assert.EqualValues(t, []string{"delete", "from", "user"},
+		sqlWords("-- where\ndelete /* where */ from user", 0))
+	assert.EqualValues(t, []string{"UPDATE", "user", "SET", "name", "LIMIT"},
+		sqlWords("UPDATE user SET name='where' LIMIT", 0))
+	assert.EqualValues(t, []string{"DROP"}, sqlWords("  DROP TABLE user", 1))
+	assert.EqualValues(t, []string{"select", "`my table`", "from", "[a b]"},
+		sqlWords("select `my table` from [a b]", 0))
+}
+
+func TestSQLScanDepth(t *testing.T) {
+	var depths []int
+	sqlScan("delete from a where id in (select id from (select id from b))", func(word string, depth int) bool {
+		depths = append(depths, depth)
+		return true
+	})
+	assert.EqualValues(t, []int{0, 0, 0, 0, 0, 0, 1, 1, 1, 2, 2, 2, 2}, depths)
+}
+
+type SafetyPolicyStruct struct {
+	Id   int64
+	Name string
+}
+
+type SafetyPolicyDeleted struct {
+	Id        int64
+	Name      string
+	DeletedAt time.Time `xorm:"deleted"`
+}
+
+func TestSafetyPolicy(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(SafetyPolicyStruct), new(SafetyPolicyDeleted))
+
+	var engine *Engine
+	switch e := testEngine.(type) {
+	case *Engine:
+		engine = e
+	case *EngineGroup:
+		engine = e.Engine
+	}
+	defer engine.SetSafetyPolicy(nil)
+
+	tableName := engine.TableName(new(SafetyPolicyStruct))
+	_, err := engine.Insert([]SafetyPolicyStruct{{Name: "a"}, {Name: "b"}, {Name: "c"}})
+	assert.NoError(t, err)
+
+	engine.SetSafetyPolicy(&SafetyPolicy{
+		BlockFullTable:  true,
+		MaxAffectedRows: 2,
+		ForbidDDL:       true,
+		LargeTables:     []string{tableName},
+	})
+
+	// full table operations
+	_, err = engine.Update(&SafetyPolicyStruct{Name: "d"})
+	assert.EqualValues(t, ErrFullTableOperation{Op: "UPDATE", TableName: tableName}, err)
+	_, err = engine.Exec("UPDATE " + engine.Quote(tableName) + " SET name = 'where'")
+	assert.EqualValues(t, ErrFullTableOperation{Op: "UPDATE", TableName: tableName}, err)
+	_, err = engine.Exec("DELETE FROM " + engine.Quote(tableName))
+	assert.EqualValues(t, ErrFullTableOperation{Op: "DELETE", TableName: tableName}, err)
+	_, err = engine.Exec("UPDATE " + engine.Quote(tableName) + " SET name = (SELECT name FROM " +
+		engine.Quote(tableName) + " WHERE id = 1)")
+	assert.EqualValues(t, ErrFullTableOperation{Op: "UPDATE", TableName: tableName}, err)
+
+	// the deleted column is not a condition of the soft deletes and the restores
+	deletedTableName := engine.TableName(new(SafetyPolicyDeleted))
+	_, err = engine.Insert(&SafetyPolicyDeleted{Name: "a"})
+	assert.NoError(t, err)
+	_, err = engine.Delete(new(SafetyPolicyDeleted))
+	assert.EqualValues(t, ErrFullTableOperation{Op: "DELETE", TableName: deletedTableName}, err)
+	cnt, err := engine.Count(new(SafetyPolicyDeleted))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	cnt, err = engine.Where("name = ?", "a").Delete(new(SafetyPolicyDeleted))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	_, err = engine.Restore(new(SafetyPolicyDeleted))
+	assert.EqualValues(t, ErrFullTableOperation{Op: "UPDATE", TableName: deletedTableName}, err)
+	_, err = engine.PurgeDeleted(new(SafetyPolicyDeleted), -time.Hour)
+	assert.EqualValues(t, ErrFullTableOperation{Op: "DELETE", TableName: deletedTableName}, err)
+	cnt, err = engine.Restore(&SafetyPolicyDeleted{Name: "a"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	cnt, err = engine.Where("name = ?", "a").Delete(new(SafetyPolicyDeleted))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	cnt, err = engine.AllowFullTable().PurgeDeleted(new(SafetyPolicyDeleted), -time.Hour)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// too many affected rows will be rolled back
+	_, err = engine.AllowFullTable().Update(&SafetyPolicyStruct{Name: "d"})
+	_, ok := err.(ErrTooManyAffectedRows)
+	assert.True(t, ok)
+	cnt, err = engine.Where("name = ?", "d").Count(new(SafetyPolicyStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	sess := engine.NewSession()
+	defer sess.Close()
+	assert.NoError(t, sess.Begin())
+	_, err = sess.Insert(&SafetyPolicyStruct{Name: "e"})
+	assert.NoError(t, err)
+	_, err = sess.Where("name <> ?", "e").Delete(new(SafetyPolicyStruct))
+	_, ok = err.(ErrTooManyAffectedRows)
+	assert.True(t, ok)
+	// only the offending statement is rolled back
+	assert.NoError(t, sess.Commit())
+	cnt, err = engine.Count(new(SafetyPolicyStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, cnt)
+
+	cnt, err = engine.Where("name = ?", "a").Update(&SafetyPolicyStruct{Name: "d"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// DDL is only allowed in migrations
+	err = engine.DropTables(new(SafetyPolicyStruct))
+	_, ok = err.(ErrDDLNotAllowed)
+	assert.True(t, ok)
+
+	// find on large table needs limit
+	var beans []SafetyPolicyStruct
+	err = engine.Find(&beans)
+	assert.EqualValues(t, ErrUnlimitedFind{TableName: tableName}, err)
+	assert.NoError(t, engine.Limit(2).Find(&beans))
+	assert.EqualValues(t, 2, len(beans))
+
+	assert.NoError(t, engine.InMigration(func(session *Session) error {
+		// the other sessions are not permitted
+		_, ok := engine.DropTables(new(SafetyPolicyStruct)).(ErrDDLNotAllowed)
+		assert.True(t, ok)
+		return session.DropTable(new(SafetyPolicyStruct))
+	}))
+}
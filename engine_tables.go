@@ -0,0 +1,129 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// tableMapping is an in-flight mapping of a type, the goroutines which need the
+// same type wait for it but not map the type again
+type tableMapping struct {
+	wg    sync.WaitGroup
+	table *phoenixormcore.Table
+	err   error
+}
+
+// Tables returns a copy of the mapped tables by their types, changing the
+// returned map doesn't change the mappings of the engine.
+//
+// Deprecated: Tables was an exported field which is replaced by this method as
+// the mapped tables are read without locks, use TableInfo to get the table of
+// a bean and UnMapType to remove a mapping.
+func (engine *Engine) Tables() map[reflect.Type]*phoenixormcore.Table {
+	var tables = make(map[reflect.Type]*phoenixormcore.Table)
+	engine.tables.Range(func(k, v interface{}) bool {
+		tables[k.(reflect.Type)] = v.(*phoenixormcore.Table)
+		return true
+	})
+	return tables
+}
+
+// UnMapType removes the datbase mapper of a type
+func (engine *Engine) UnMapType(t reflect.Type) {
+	engine.tables.Delete(t)
+}
+
+// autoMapType returns the mapped table of the type of v, the type is mapped at
+// the first time. The mapped tables are read without locks.
+func (engine *Engine) autoMapType(v reflect.Value) (*phoenixormcore.Table, error) {
+	t := v.Type()
+	if table, ok := engine.tables.Load(t); ok {
+		return table.(*phoenixormcore.Table), nil
+	}
+
+	engine.mappingsMutex.Lock()
+	if table, ok := engine.tables.Load(t); ok {
+		engine.mappingsMutex.Unlock()
+		return table.(*phoenixormcore.Table), nil
+	}
+	if mapping, ok := engine.mappings[t]; ok {
+		engine.mappingsMutex.Unlock()
+		mapping.wg.Wait()
+		return mapping.table, mapping.err
+	}
+	if engine.mappings == nil {
+		engine.mappings = make(map[reflect.Type]*tableMapping)
+	}
+	mapping := new(tableMapping)
+	mapping.wg.Add(1)
+	engine.mappings[t] = mapping
+	engine.mappingsMutex.Unlock()
+
+	defer func() {
+		if mapping.table == nil && mapping.err == nil {
+			// mapType panicked, the waiting goroutines should not get a nil table
+			mapping.err = fmt.Errorf("mapping type %v failed", t)
+		}
+		engine.mappingsMutex.Lock()
+		delete(engine.mappings, t)
+		engine.mappingsMutex.Unlock()
+		mapping.wg.Done()
+	}()
+
+	table, err := engine.mapType(v)
+	if err != nil {
+		mapping.err = err
+		return nil, err
+	}
+	if engine.getCacher(table.Name) != nil {
+		if v.CanAddr() {
+			engine.GobRegister(v.Addr().Interface())
+		} else {
+			engine.GobRegister(v.Interface())
+		}
+	}
+	engine.tables.Store(t, table)
+	mapping.table = table
+	return table, nil
+}
+
+// tableByName returns the mapped table of the name, nil will be returned if no
+// mapped struct has the name
+func (engine *Engine) tableByName(name string) *phoenixormcore.Table {
+	if pos := strings.LastIndex(name, "."); pos >= 0 {
+		name = name[pos+1:]
+	}
+	var found *phoenixormcore.Table
+	engine.tables.Range(func(_, v interface{}) bool {
+		if table := v.(*phoenixormcore.Table); strings.EqualFold(table.Name, name) {
+			found = table
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// RegisterModels maps the structs of the beans, it's usually called at startup
+// so that the tags of all the models are validated before they are used and the
+// operations will not map them at the first time.
+func (engine *Engine) RegisterModels(beans ...interface{}) error {
+	for _, bean := range beans {
+		v := rValue(bean)
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("%T: %v", bean, ErrTableNotFound)
+		}
+		if _, err := engine.autoMapType(v); err != nil {
+			return fmt.Errorf("%v: %v", v.Type(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+func TestRegisterModels(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type RegisterModelsBad struct {
+		Id   int64
+		Name string `xorm:"(varchar"`
+	}
+
+	assert.NoError(t, testEngine.RegisterModels(new(Userinfo), Userdetail{}))
+	assert.EqualValues(t, testEngine.TableName(new(Userinfo)), testEngine.TableInfo(new(Userinfo)).Name)
+
+	assert.Error(t, testEngine.RegisterModels(1))
+	err := testEngine.RegisterModels(new(Userinfo), new(RegisterModelsBad))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "RegisterModelsBad")
+	}
+}
+
+type AutoMapTypeStruct struct {
+	Id      int64
+	Name    string `xorm:"varchar(50) index"`
+	Age     int
+	Created int64 `xorm:"created"`
+}
+
+func TestAutoMapTypeConcurrent(t *testing.T) {
+	engine, err := newMappingEngine(&ColumnsOptions{})
+	assert.NoError(t, err)
+
+	v := reflect.ValueOf(AutoMapTypeStruct{})
+	var tables = make([]*phoenixormcore.Table, 32)
+	var wg sync.WaitGroup
+	for i := range tables {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			table, err := engine.autoMapType(v)
+			assert.NoError(t, err)
+			tables[i] = table
+		}(i)
+	}
+	wg.Wait()
+
+	// the type is mapped once
+	for _, table := range tables {
+		assert.True(t, table == tables[0])
+	}
+	assert.EqualValues(t, "auto_map_type_struct", tables[0].Name)
+
+	assert.True(t, engine.Tables()[v.Type()] == tables[0])
+
+	engine.UnMapType(v.Type())
+	assert.EqualValues(t, 0, len(engine.Tables()))
+	table, err := engine.autoMapType(v)
+	assert.NoError(t, err)
+	assert.False(t, table == tables[0])
+}
+
+func BenchmarkAutoMapTypeParallel(b *testing.B) {
+	engine, err := newMappingEngine(&ColumnsOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	v := reflect.ValueOf(AutoMapTypeStruct{})
+	if _, err := engine.autoMapType(v); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := engine.autoMapType(v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -7,6 +7,8 @@ package xorm
 import (
 	"errors"
 	"fmt"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
 )
 
 var (
@@ -22,12 +24,32 @@ var (
 	ErrCacheFailed = errors.New("Cache failed")
 	// ErrNeedDeletedCond delete needs less one condition error
 	ErrNeedDeletedCond = errors.New("Delete action needs at least one condition")
+	// ErrNoDeletedColumn the table has no deleted column to be restored or purged
+	ErrNoDeletedColumn = errors.New("Table has no deleted column")
+	// ErrNeedPrimaryKey the primary keys are needed to reload the row by UpdateWithRetry
+	ErrNeedPrimaryKey = errors.New("Primary keys are needed to reload the record")
+	// ErrNoSnapshot the bean has no snapshot to find the changed columns
+	ErrNoSnapshot = errors.New("Bean has no snapshot")
 	// ErrNotImplemented not implemented
 	ErrNotImplemented = errors.New("Not implemented")
 	// ErrConditionType condition type unsupported
 	ErrConditionType = errors.New("Unsupported condition type")
 	// ErrUnSupportedSQLType parameter of SQL is not supported
 	ErrUnSupportedSQLType = errors.New("unsupported sql type")
+	// ErrNoKeyProvider the engine has no KeyProvider for the encrypted columns
+	ErrNoKeyProvider = errors.New("No key provider for the encrypted columns")
+	// ErrNoTenantResolver the engine has no TenantResolver for the tenant scoped tables
+	ErrNoTenantResolver = errors.New("No tenant resolver for the tenant scoped tables")
+	// ErrNoTenant the TenantResolver resolves no tenant from the context
+	ErrNoTenant = errors.New("No tenant is resolved from the context")
+	// ErrTenantBypassDenied the context is not permitted to operate all the tenants
+	ErrTenantBypassDenied = errors.New("Bypassing the tenants is not permitted by the context")
+	// ErrRowPolicyDenied the inserted record is denied by the row policy of the table
+	ErrRowPolicyDenied = errors.New("Record is denied by the row policy")
+	// ErrOffsetWithoutTable MSSQL could not page the derived tables or the unions by offset
+	ErrOffsetWithoutTable = errors.New("Offset needs a mapped table on MSSQL")
+	// ErrNoAggregate the aggregate query selects nothing
+	ErrNoAggregate = errors.New("No column or aggregate is selected")
 )
 
 // ErrFieldIsNotExist columns does not exist
@@ -49,3 +71,84 @@ type ErrFieldIsNotValid struct {
 func (e ErrFieldIsNotValid) Error() string {
 	return fmt.Sprintf("field %s is not valid on table %s", e.FieldName, e.TableName)
 }
+
+// ErrVersionConflict will be returned when updating or deleting a bean with a
+// version column but the version of the row is not the expected one
+type ErrVersionConflict struct {
+	TableName string
+	PK        phoenixormcore.PK
+	Version   interface{}
+}
+
+func (e ErrVersionConflict) Error() string {
+	return fmt.Sprintf("version conflict on table %s with pk %v, expected version %v", e.TableName, e.PK, e.Version)
+}
+
+// ErrAfterCommit will be returned by Commit, or by the operation without an explicit
+// transaction, when a context aware after processor returns an error, the changes
+// have been committed and are NOT rolled back
+type ErrAfterCommit struct {
+	Err error
+}
+
+func (e ErrAfterCommit) Error() string {
+	return fmt.Sprintf("after processor failed after committed: %v", e.Err)
+}
+
+// Unwrap returns the error of the processor
+func (e ErrAfterCommit) Unwrap() error {
+	return e.Err
+}
+
+// ErrFullTableOperation will be returned when updating or deleting without any
+// condition is blocked by the safety policy, use AllowFullTable to run it
+type ErrFullTableOperation struct {
+	Op        string
+	TableName string
+}
+
+func (e ErrFullTableOperation) Error() string {
+	return fmt.Sprintf("%s on table %s without condition is not allowed", e.Op, e.TableName)
+}
+
+// ErrTooManyAffectedRows will be returned and the statement will be rolled back
+// when the affected rows is greater than the limit of the safety policy
+type ErrTooManyAffectedRows struct {
+	SQL      string
+	Limit    int64
+	Affected int64
+}
+
+func (e ErrTooManyAffectedRows) Error() string {
+	return fmt.Sprintf("%d rows affected by %s is greater than the limit %d", e.Affected, e.SQL, e.Limit)
+}
+
+// ErrDDLNotAllowed will be returned when running DDL outside migrations is forbidden
+type ErrDDLNotAllowed struct {
+	SQL string
+}
+
+func (e ErrDDLNotAllowed) Error() string {
+	return fmt.Sprintf("DDL %s is not allowed outside migrations", e.SQL)
+}
+
+// ErrUnlimitedFind will be returned when finding on a large table without limit
+type ErrUnlimitedFind struct {
+	TableName string
+}
+
+func (e ErrUnlimitedFind) Error() string {
+	return fmt.Sprintf("find on large table %s without limit is not allowed", e.TableName)
+}
+
+// ErrTenantMismatch will be returned when inserting or updating a bean of
+// another tenant
+type ErrTenantMismatch struct {
+	TableName string
+	Tenant    interface{}
+	Value     interface{}
+}
+
+func (e ErrTenantMismatch) Error() string {
+	return fmt.Sprintf("tenant %v of the bean mismatches the tenant %v on table %s", e.Value, e.Tenant, e.TableName)
+}
@@ -0,0 +1,235 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+
+package xorm
+
+import (
+	"context"
+)
+
+// TypedQuery is a query whose results are T, T should be a struct
+type TypedQuery[T any] struct {
+	session *Session
+}
+
+// typedSession returns the session of db, a new auto closed session will be
+// created if db is an engine or an engine group
+func typedSession(db Interface) *Session {
+	if session, ok := db.(*Session); ok {
+		return session
+	}
+	session := db.(EngineInterface).NewSession()
+	session.isAutoClose = true
+	return session
+}
+
+func withContext(session *Session, ctx context.Context) *Session {
+	if ctx != nil {
+		session.Context(ctx)
+	}
+	return session
+}
+
+// Query returns a typed query, db could be *Engine, *EngineGroup or *Session.
+// For example:
+//
+//	users, err := xorm.Query[User](engine).Where("age > ?", 18).Find(ctx)
+func Query[T any](db Interface) *TypedQuery[T] {
+	return &TypedQuery[T]{session: typedSession(db)}
+}
+
+// Session returns the session of the query
+func (q *TypedQuery[T]) Session() *Session {
+	return q.session
+}
+
+// Where provides custom query condition
+func (q *TypedQuery[T]) Where(query interface{}, args ...interface{}) *TypedQuery[T] {
+	q.session.Where(query, args...)
+	return q
+}
+
+// And provides custom query condition
+func (q *TypedQuery[T]) And(query interface{}, args ...interface{}) *TypedQuery[T] {
+	q.session.And(query, args...)
+	return q
+}
+
+// Or provides custom query condition
+func (q *TypedQuery[T]) Or(query interface{}, args ...interface{}) *TypedQuery[T] {
+	q.session.Or(query, args...)
+	return q
+}
+
+// ID provides converting id as a query condition
+func (q *TypedQuery[T]) ID(id interface{}) *TypedQuery[T] {
+	q.session.ID(id)
+	return q
+}
+
+// In provides a query string like "id in (1, 2, 3)"
+func (q *TypedQuery[T]) In(column string, args ...interface{}) *TypedQuery[T] {
+	q.session.In(column, args...)
+	return q
+}
+
+// NotIn provides a query string like "id not in (1, 2, 3)"
+func (q *TypedQuery[T]) NotIn(column string, args ...interface{}) *TypedQuery[T] {
+	q.session.NotIn(column, args...)
+	return q
+}
+
+// Join join_operator should be one of INNER, LEFT OUTER, CROSS etc - this will be prepended to JOIN
+func (q *TypedQuery[T]) Join(joinOperator string, tablename interface{}, condition string, args ...interface{}) *TypedQuery[T] {
+	q.session.Join(joinOperator, tablename, condition, args...)
+	return q
+}
+
+// Table can input a string or pointer to struct for special a table to operate.
+func (q *TypedQuery[T]) Table(tableNameOrBean interface{}) *TypedQuery[T] {
+	q.session.Table(tableNameOrBean)
+	return q
+}
+
+// Cols provides some columns to special
+func (q *TypedQuery[T]) Cols(columns ...string) *TypedQuery[T] {
+	q.session.Cols(columns...)
+	return q
+}
+
+// Omit Only not use the parameters as select or update columns
+func (q *TypedQuery[T]) Omit(columns ...string) *TypedQuery[T] {
+	q.session.Omit(columns...)
+	return q
+}
+
+// Columns is the same as Cols but accepts column references
+func (q *TypedQuery[T]) Columns(cols ...ColumnRef) *TypedQuery[T] {
+	q.session.Columns(cols...)
+	return q
+}
+
+// OmitColumns is the same as Omit but accepts column references
+func (q *TypedQuery[T]) OmitColumns(cols ...ColumnRef) *TypedQuery[T] {
+	q.session.OmitColumns(cols...)
+	return q
+}
+
+// OrderBy provide order by query condition, the input parameter is the content
+// after order by on a sql statement.
+func (q *TypedQuery[T]) OrderBy(order string) *TypedQuery[T] {
+	q.session.OrderBy(order)
+	return q
+}
+
+// Asc provide asc order by query condition, the input parameters are columns.
+func (q *TypedQuery[T]) Asc(colNames ...string) *TypedQuery[T] {
+	q.session.Asc(colNames...)
+	return q
+}
+
+// Desc provide desc order by query condition, the input parameters are columns.
+func (q *TypedQuery[T]) Desc(colNames ...string) *TypedQuery[T] {
+	q.session.Desc(colNames...)
+	return q
+}
+
+// AscColumns is the same as Asc but accepts column references
+func (q *TypedQuery[T]) AscColumns(cols ...ColumnRef) *TypedQuery[T] {
+	q.session.AscColumns(cols...)
+	return q
+}
+
+// DescColumns is the same as Desc but accepts column references
+func (q *TypedQuery[T]) DescColumns(cols ...ColumnRef) *TypedQuery[T] {
+	q.session.DescColumns(cols...)
+	return q
+}
+
+// Limit provide limit and offset query condition
+func (q *TypedQuery[T]) Limit(limit int, start ...int) *TypedQuery[T] {
+	q.session.Limit(limit, start...)
+	return q
+}
+
+// Unscoped always disable struct tag "deleted"
+func (q *TypedQuery[T]) Unscoped() *TypedQuery[T] {
+	q.session.Unscoped()
+	return q
+}
+
+// AllTenants operates the records of all the tenants, the context of the
+// session should be permitted by WithTenantBypass
+func (q *TypedQuery[T]) AllTenants() *TypedQuery[T] {
+	q.session.AllTenants()
+	return q
+}
+
+// WithoutScope don't apply the global scopes of the names, all the global scopes
+// will not be applied if no name is given
+func (q *TypedQuery[T]) WithoutScope(names ...string) *TypedQuery[T] {
+	q.session.WithoutScope(names...)
+	return q
+}
+
+// NoCache ask this session do not retrieve data from cache system and
+// get data from database directly.
+func (q *TypedQuery[T]) NoCache() *TypedQuery[T] {
+	q.session.NoCache()
+	return q
+}
+
+// Find retrieves the records
+func (q *TypedQuery[T]) Find(ctx context.Context) ([]T, error) {
+	var beans []T
+	if err := withContext(q.session, ctx).Find(&beans); err != nil {
+		return nil, err
+	}
+	return beans, nil
+}
+
+// Get retrieves one record, false will be returned if there is no record
+func (q *TypedQuery[T]) Get(ctx context.Context) (T, bool, error) {
+	var bean T
+	has, err := withContext(q.session, ctx).Get(&bean)
+	return bean, has, err
+}
+
+// Count counts the records
+func (q *TypedQuery[T]) Count(ctx context.Context) (int64, error) {
+	return withContext(q.session, ctx).Count(new(T))
+}
+
+// Exist returns true if the record exist otherwise return false
+func (q *TypedQuery[T]) Exist(ctx context.Context) (bool, error) {
+	return withContext(q.session, ctx).Exist(new(T))
+}
+
+// Get retrieves one record with the conditions of db, db could be *Engine,
+// *EngineGroup or *Session. For example:
+//
+//	user, has, err := xorm.Get[User](ctx, engine.ID(1))
+func Get[T any](ctx context.Context, db Interface) (T, bool, error) {
+	return Query[T](db).Get(ctx)
+}
+
+// Find retrieves the records with the conditions of db
+func Find[T any](ctx context.Context, db Interface) ([]T, error) {
+	return Query[T](db).Find(ctx)
+}
+
+// Insert inserts the beans, the processors with pointer receivers of T will be
+// invoked on the elements of beans.
+func Insert[T any](ctx context.Context, db Interface, beans []T) (int64, error) {
+	if len(beans) == 0 {
+		return 0, nil
+	}
+	var ptrs = make([]*T, len(beans))
+	for i := range beans {
+		ptrs[i] = &beans[i]
+	}
+	return withContext(typedSession(db), ctx).Insert(&ptrs)
+}
@@ -0,0 +1,38 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package xorm
+
+import (
+	"context"
+	"iter"
+)
+
+// Iterate returns an iterator of the records, the rows will be closed when the
+// iteration is finished or broken. The iterator could only be used once.
+// For example:
+//
+//	for user, err := range xorm.Query[User](engine).Where("age > ?", 18).Iterate(ctx) {
+//	}
+func (q *TypedQuery[T]) Iterate(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for bean, err := range withContext(q.session, ctx).All(new(T)) {
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if !yield(*bean.(*T), nil) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate returns an iterator of the records with the conditions of db
+func Iterate[T any](ctx context.Context, db Interface) iter.Seq2[T, error] {
+	return Query[T](db).Iterate(ctx)
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package xorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type GenericUser struct {
+	Id   int64
+	Name string
+	Age  int
+}
+
+func (u *GenericUser) BeforeInsert() {
+	u.Name = "+" + u.Name
+}
+
+func TestGeneric(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(GenericUser))
+
+	ctx := context.Background()
+	affected, err := Insert(ctx, testEngine, []GenericUser{
+		{Name: "a", Age: 10},
+		{Name: "b", Age: 20},
+		{Name: "c", Age: 30},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, affected)
+
+	users, err := Query[GenericUser](testEngine).Where("age > ?", 10).Asc("age").Find(ctx)
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 2, len(users)) {
+		assert.EqualValues(t, "+b", users[0].Name)
+		assert.EqualValues(t, "+c", users[1].Name)
+	}
+
+	user, has, err := Get[GenericUser](ctx, testEngine.Where("name = ?", "+a"))
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 10, user.Age)
+
+	_, has, err = Query[GenericUser](testEngine).ID(user.Id + 100).Get(ctx)
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	cnt, err := Query[GenericUser](testEngine).Where("age >= ?", 20).Count(ctx)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	session := testEngine.NewSession()
+	defer session.Close()
+	all, err := Find[GenericUser](ctx, session)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, len(all))
+
+	var names []string
+	for user, err := range Iterate[GenericUser](ctx, testEngine.Asc("id")) {
+		assert.NoError(t, err)
+		names = append(names, user.Name)
+	}
+	assert.EqualValues(t, []string{"+a", "+b", "+c"}, names)
+
+	// break the iteration
+	var n int
+	for _, err := range Query[GenericUser](testEngine).Iterate(ctx) {
+		assert.NoError(t, err)
+		n++
+		break
+	}
+	assert.EqualValues(t, 1, n)
+
+	for _, err := range Query[GenericUser](testEngine).Table("not_exist_table").Iterate(ctx) {
+		assert.Error(t, err)
+	}
+}
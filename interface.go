@@ -7,6 +7,7 @@ package xorm
 import (
 	"context"
 	"database/sql"
+	"io"
 	"reflect"
 	"time"
 
@@ -15,16 +16,22 @@ import (
 
 // Interface defines the interface which Engine, EngineGroup and Session will implementate.
 type Interface interface {
+	Aggregate(tableNameOrBean interface{}) *AggregateQuery
 	AllCols() *Session
+	AllTenants() *Session
 	Alias(alias string) *Session
+	AllowFullTable() *Session
 	Asc(colNames ...string) *Session
+	AscColumns(cols ...ColumnRef) *Session
 	BufferSize(size int) *Session
 	Cols(columns ...string) *Session
+	Columns(cols ...ColumnRef) *Session
 	Count(...interface{}) (int64, error)
 	CreateIndexes(bean interface{}) error
 	CreateUniques(bean interface{}) error
 	Decr(column string, arg ...interface{}) *Session
 	Desc(...string) *Session
+	DescColumns(cols ...ColumnRef) *Session
 	Delete(interface{}) (int64, error)
 	Distinct(columns ...string) *Session
 	DropIndexes(bean interface{}) error
@@ -32,6 +39,7 @@ type Interface interface {
 	Exist(bean ...interface{}) (bool, error)
 	Find(interface{}, ...interface{}) error
 	FindAndCount(interface{}, ...interface{}) (int64, error)
+	ForceDelete(interface{}) (int64, error)
 	Get(interface{}) (bool, error)
 	GroupBy(keys string) *Session
 	ID(interface{}) *Session
@@ -48,12 +56,17 @@ type Interface interface {
 	NotIn(string, ...interface{}) *Session
 	Join(joinOperator string, tablename interface{}, condition string, args ...interface{}) *Session
 	Omit(columns ...string) *Session
+	OmitColumns(cols ...ColumnRef) *Session
+	OnlyDeleted() *Session
 	OrderBy(order string) *Session
 	Ping() error
+	PurgeDeleted(bean interface{}, olderThan time.Duration) (int64, error)
 	Query(sqlOrArgs ...interface{}) (resultsSlice []map[string][]byte, err error)
 	QueryInterface(sqlOrArgs ...interface{}) ([]map[string]interface{}, error)
 	QueryString(sqlOrArgs ...interface{}) ([]map[string]string, error)
+	Restore(interface{}) (int64, error)
 	Rows(bean interface{}) (*Rows, error)
+	Scopes(fns ...func(*Session) *Session) *Session
 	SetExpr(string, interface{}) *Session
 	SQL(interface{}, ...interface{}) *Session
 	Sum(bean interface{}, colName string) (float64, error)
@@ -63,23 +76,31 @@ type Interface interface {
 	Table(tableNameOrBean interface{}) *Session
 	Unscoped() *Session
 	Update(bean interface{}, condiBeans ...interface{}) (int64, error)
+	UpdateChanged(bean interface{}) (int64, error)
 	UseBool(...string) *Session
 	Where(interface{}, ...interface{}) *Session
+	With(name string, subquery interface{}) *Session
+	WithRecursive(name string, subquery interface{}) *Session
+	WithoutScope(names ...string) *Session
 }
 
 // EngineInterface defines the interface which Engine, EngineGroup will implementate.
 type EngineInterface interface {
 	Interface
 
+	AddGlobalScope(bean interface{}, name string, fn func(*Session) *Session)
 	Before(func(interface{})) *Session
+	BlindIndex(colName string, value interface{}) (string, error)
 	Charset(charset string) *Session
 	ClearCache(...interface{}) error
+	ColumnAttr(col *phoenixormcore.Column, key string) (interface{}, bool)
 	Context(context.Context) *Session
 	CreateTables(...interface{}) error
 	DBMetas() ([]*phoenixormcore.Table, error)
 	Dialect() phoenixormcore.Dialect
 	DropTables(...interface{}) error
 	DumpAllToFile(fp string, tp ...phoenixormcore.DbType) error
+	EnableAudit(...interface{}) error
 	GetCacher(string) phoenixormcore.Cacher
 	GetColumnMapper() phoenixormcore.IMapper
 	GetDefaultCacher() phoenixormcore.Cacher
@@ -90,17 +111,26 @@ type EngineInterface interface {
 	NewSession() *Session
 	NoAutoTime() *Session
 	Quote(string) string
+	RegisterModels(beans ...interface{}) error
+	RegisterTag(name string, handler TagHandler)
+	Reverse(w io.Writer, opts ReverseOptions) error
+	SetAuditSink(AuditSink)
 	SetCacher(string, phoenixormcore.Cacher)
 	SetConnMaxLifetime(time.Duration)
 	SetColumnMapper(phoenixormcore.IMapper)
 	SetDefaultCacher(phoenixormcore.Cacher)
+	SetKeyProvider(KeyProvider)
 	SetLogger(logger phoenixormcore.ILogger)
+	SetRowPolicy(bean interface{}, policy RowPolicy)
 	SetLogLevel(phoenixormcore.LogLevel)
 	SetMapper(phoenixormcore.IMapper)
 	SetMaxOpenConns(int)
 	SetMaxIdleConns(int)
+	SetSafetyPolicy(*SafetyPolicy)
 	SetSchema(string)
 	SetTableMapper(phoenixormcore.IMapper)
+	SetTenantResolver(TenantResolver)
+	SetTenantSchema(TenantSchemaResolver)
 	SetTZDatabase(tz *time.Location)
 	SetTZLocation(tz *time.Location)
 	ShowExecTime(...bool)
@@ -1,13 +1,16 @@
 package migrate
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
 	"xorm.io/xorm"
 )
 
-// MigrateFunc is the func signature for migrating.
+// MigrateFunc is the func signature for migrating. When DDL is forbidden by the
+// safety policy of the engine, it should be executed on a session with a context
+// created by xorm.WithMigration, e.g. tx.Context(xorm.WithMigration(ctx)).Sync2(bean)
 type MigrateFunc func(*xorm.Engine) error
 
 // RollbackFunc is the func signature for rollbacking.
@@ -192,7 +195,7 @@ func (m *Migrate) createMigrationTableIfNotExists() error {
 	}
 
 	sql := fmt.Sprintf("CREATE TABLE %s (%s VARCHAR(255) PRIMARY KEY)", m.options.TableName, m.options.IDColumnName)
-	if _, err := m.db.Exec(sql); err != nil {
+	if _, err := m.db.Context(xorm.WithMigration(context.Background())).Exec(sql); err != nil {
 		return err
 	}
 	return nil
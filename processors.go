@@ -4,6 +4,11 @@
 
 package xorm
 
+import (
+	"context"
+	"reflect"
+)
+
 // BeforeInsertProcessor executed before an object is initially persisted to the database
 type BeforeInsertProcessor interface {
 	BeforeInsert()
@@ -54,6 +59,125 @@ type AfterLoadSessionProcessor interface {
 	AfterLoad(*Session)
 }
 
+// BeforeInsertContextProcessor executed before an object is initially persisted to the database,
+// an error will abort the insert
+type BeforeInsertContextProcessor interface {
+	BeforeInsertContext(context.Context, *Session) error
+}
+
+// BeforeUpdateContextProcessor executed before an object is updated, an error will
+// abort the update
+type BeforeUpdateContextProcessor interface {
+	BeforeUpdateContext(context.Context, *Session) error
+}
+
+// BeforeDeleteContextProcessor executed before an object is deleted, an error will
+// abort the delete
+type BeforeDeleteContextProcessor interface {
+	BeforeDeleteContext(context.Context, *Session) error
+}
+
+// AfterInsertContextProcessor executed after an object is persisted to the database
+// and the transaction is committed, without an explicit transaction the insert runs
+// in a transaction too. The error is returned by Commit or by the insert without an
+// explicit transaction wrapped in ErrAfterCommit, the changes are not rolled back.
+type AfterInsertContextProcessor interface {
+	AfterInsertContext(context.Context, *Session) error
+}
+
+// AfterUpdateContextProcessor executed after an object has been updated, the error
+// is handled as AfterInsertContextProcessor
+type AfterUpdateContextProcessor interface {
+	AfterUpdateContext(context.Context, *Session) error
+}
+
+// AfterDeleteContextProcessor executed after an object has been deleted, the error
+// is handled as AfterInsertContextProcessor
+type AfterDeleteContextProcessor interface {
+	AfterDeleteContext(context.Context, *Session) error
+}
+
+// AfterLoadContextProcessor executed after an ojbect has been loaded from database,
+// the error will be returned by Get, Find or Iterate
+type AfterLoadContextProcessor interface {
+	AfterLoadContext(context.Context, *Session) error
+}
+
+func isAfterInsertProcessor(bean interface{}) bool {
+	_, ok := bean.(AfterInsertProcessor)
+	_, okContext := bean.(AfterInsertContextProcessor)
+	return ok || okContext
+}
+
+func isAfterUpdateProcessor(bean interface{}) bool {
+	_, ok := bean.(AfterUpdateProcessor)
+	_, okContext := bean.(AfterUpdateContextProcessor)
+	return ok || okContext
+}
+
+func isAfterDeleteProcessor(bean interface{}) bool {
+	_, ok := bean.(AfterDeleteProcessor)
+	_, okContext := bean.(AfterDeleteContextProcessor)
+	return ok || okContext
+}
+
+var (
+	tpAfterInsertContext = reflect.TypeOf((*AfterInsertContextProcessor)(nil)).Elem()
+	tpAfterUpdateContext = reflect.TypeOf((*AfterUpdateContextProcessor)(nil)).Elem()
+	tpAfterDeleteContext = reflect.TypeOf((*AfterDeleteContextProcessor)(nil)).Elem()
+)
+
+// hasAfterContextProcessor returns true if bean or the elements of a slice bean
+// implement the after processor interface tp
+func hasAfterContextProcessor(bean interface{}, tp reflect.Type) bool {
+	if bean == nil {
+		return false
+	}
+	t := reflect.TypeOf(bean)
+	if t.Implements(tp) {
+		return true
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Slice {
+		return false
+	}
+	// the processors are executed on the addresses of the elements
+	t = t.Elem()
+	if t.Kind() == reflect.Ptr {
+		return t.Implements(tp)
+	}
+	return reflect.PtrTo(t).Implements(tp)
+}
+
+// executeAfterContextProcessors executes the after processors of the beans
+// changed in the transaction after it's committed
+func (session *Session) executeAfterContextProcessors() error {
+	for bean := range session.afterInsertBeans {
+		if processor, ok := interface{}(bean).(AfterInsertContextProcessor); ok {
+			if err := processor.AfterInsertContext(session.ctx, session); err != nil {
+				return err
+			}
+		}
+	}
+	for bean := range session.afterUpdateBeans {
+		if processor, ok := interface{}(bean).(AfterUpdateContextProcessor); ok {
+			if err := processor.AfterUpdateContext(session.ctx, session); err != nil {
+				return err
+			}
+		}
+	}
+	for bean := range session.afterDeleteBeans {
+		if processor, ok := interface{}(bean).(AfterDeleteContextProcessor); ok {
+			if err := processor.AfterDeleteContext(session.ctx, session); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 type executedProcessorFunc func(*Session, interface{}) error
 
 type executedProcessor struct {
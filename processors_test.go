@@ -5,9 +5,11 @@
 package xorm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -932,3 +934,195 @@ func TestAfterInsert(t *testing.T) {
 	_, err := testEngine.Insert(&AfterInsertStruct{})
 	assert.NoError(t, err)
 }
+
+type contextProcessorKey struct{}
+
+type ContextProcessorStruct struct {
+	Id      int64
+	Name    string
+	Counter int
+	Old     bool `xorm:"-"`
+	Ctx     bool `xorm:"-"`
+}
+
+type ContextProcessorLog struct {
+	Id   int64
+	Name string
+}
+
+func (p *ContextProcessorStruct) BeforeInsert() {
+	p.Old = true
+}
+
+func (p *ContextProcessorStruct) BeforeInsertContext(ctx context.Context, session *Session) error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	p.Ctx = ctx.Value(contextProcessorKey{}) != nil
+	return nil
+}
+
+func (p *ContextProcessorStruct) AfterInsertContext(ctx context.Context, session *Session) error {
+	if _, err := session.Insert(&ContextProcessorLog{Name: p.Name}); err != nil {
+		return err
+	}
+	if p.Name == "fail" {
+		return errors.New("after insert failed")
+	}
+	return nil
+}
+
+func (p *ContextProcessorStruct) BeforeUpdateContext(ctx context.Context, session *Session) error {
+	if p.Name == "invalid" {
+		return errors.New("invalid name")
+	}
+	return nil
+}
+
+func (p *ContextProcessorStruct) AfterUpdateContext(ctx context.Context, session *Session) error {
+	if p.Name == "fail" {
+		return errors.New("after update failed")
+	}
+	return nil
+}
+
+func (p *ContextProcessorStruct) BeforeDeleteContext(ctx context.Context, session *Session) error {
+	if p.Name == "keep" {
+		return errors.New("could not be deleted")
+	}
+	return nil
+}
+
+func (p *ContextProcessorStruct) AfterLoadContext(ctx context.Context, session *Session) error {
+	p.Counter++
+	if p.Name == "broken" {
+		return errors.New("broken record")
+	}
+	return nil
+}
+
+func TestContextProcessors(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(ContextProcessorStruct), new(ContextProcessorLog))
+
+	ctx := context.WithValue(context.Background(), contextProcessorKey{}, true)
+
+	// the before processor aborts the insert
+	_, err := testEngine.Context(ctx).Insert(&ContextProcessorStruct{})
+	assert.Error(t, err)
+
+	var p = ContextProcessorStruct{Name: "a"}
+	cnt, err := testEngine.Context(ctx).Insert(&p)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.True(t, p.Old)
+	assert.True(t, p.Ctx)
+
+	// the after processor is executed after the insert committed as in an explicit
+	// transaction, the insert and the changes of the processor are kept
+	cnt, err = testEngine.InsertOne(&ContextProcessorStruct{Name: "fail"})
+	_, ok := err.(ErrAfterCommit)
+	assert.True(t, ok)
+	assert.EqualValues(t, 1, cnt)
+	total, err := testEngine.Count(new(ContextProcessorStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+	total, err = testEngine.Count(new(ContextProcessorLog))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+
+	_, err = testEngine.Insert([]*ContextProcessorStruct{{Name: "b"}, {Name: "fail"}})
+	_, ok = err.(ErrAfterCommit)
+	assert.True(t, ok)
+	total, err = testEngine.Count(new(ContextProcessorStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, total)
+
+	_, err = testEngine.ID(p.Id).Update(&ContextProcessorStruct{Name: "invalid"})
+	assert.Error(t, err)
+	_, ok = err.(ErrAfterCommit)
+	assert.False(t, ok)
+	_, err = testEngine.ID(p.Id).Update(&ContextProcessorStruct{Name: "fail"})
+	_, ok = err.(ErrAfterCommit)
+	assert.True(t, ok)
+	cnt, err = testEngine.ID(p.Id).Update(&ContextProcessorStruct{Name: "keep"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	var loaded ContextProcessorStruct
+	has, err := testEngine.ID(p.Id).Get(&loaded)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "keep", loaded.Name)
+	assert.EqualValues(t, 1, loaded.Counter)
+
+	_, err = testEngine.ID(p.Id).Delete(&ContextProcessorStruct{Name: "keep"})
+	assert.Error(t, err)
+
+	// in an explicit transaction the after processors are executed after committed
+	session := testEngine.NewSession()
+	defer session.Close()
+	assert.NoError(t, session.Begin())
+	_, err = session.Insert(&ContextProcessorStruct{Name: "fail"})
+	assert.NoError(t, err)
+	err = session.Commit()
+	_, ok = err.(ErrAfterCommit)
+	assert.True(t, ok)
+	total, err = testEngine.Count(new(ContextProcessorStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, total)
+
+	_, err = testEngine.Insert(&ContextProcessorStruct{Name: "broken"})
+	assert.NoError(t, err)
+	var ps []ContextProcessorStruct
+	err = testEngine.Asc("id").Find(&ps)
+	assert.Error(t, err)
+}
+
+type ContextProcessorFilled struct {
+	Id      int64
+	Name    string
+	Created time.Time `xorm:"created"`
+	Updated time.Time `xorm:"updated"`
+	Version int       `xorm:"version"`
+
+	SeenCreated bool `xorm:"-"`
+	SeenVersion int  `xorm:"-"`
+}
+
+func (p *ContextProcessorFilled) AfterInsertContext(ctx context.Context, session *Session) error {
+	p.SeenCreated = !p.Created.IsZero()
+	p.SeenVersion = p.Version
+	return nil
+}
+
+func (p *ContextProcessorFilled) AfterUpdateContext(ctx context.Context, session *Session) error {
+	p.SeenVersion = p.Version
+	return nil
+}
+
+func TestContextProcessorsFilledBeans(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(ContextProcessorFilled))
+
+	var p = ContextProcessorFilled{Name: "a"}
+	_, err := testEngine.Insert(&p)
+	assert.NoError(t, err)
+	assert.True(t, p.SeenCreated)
+	assert.EqualValues(t, 1, p.SeenVersion)
+
+	p.Name = "b"
+	_, err = testEngine.ID(p.Id).Update(&p)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, p.SeenVersion)
+
+	session := testEngine.NewSession()
+	defer session.Close()
+	assert.NoError(t, session.Begin())
+	var p2 = ContextProcessorFilled{Name: "c"}
+	_, err = session.Insert(&p2)
+	assert.NoError(t, err)
+	assert.NoError(t, session.Commit())
+	assert.True(t, p2.SeenCreated)
+	assert.EqualValues(t, 1, p2.SeenVersion)
+}
@@ -0,0 +1,208 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+
+	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// PolicyOp represents the operation checked by a RowPolicy
+type PolicyOp string
+
+// policy operations
+const (
+	PolicyRead   PolicyOp = "read"
+	PolicyInsert PolicyOp = "insert"
+	PolicyUpdate PolicyOp = "update"
+	PolicyDelete PolicyOp = "delete"
+)
+
+// RowPolicy returns the condition of the records which could be operated by
+// the caller of the context, or an error to deny the operation. A nil or an
+// empty condition permits all the records.
+type RowPolicy func(ctx context.Context, op PolicyOp) (phoenixormbuilder.Cond, error)
+
+// SetRowPolicy sets the row level policy of the table of bean, the condition of
+// the policy is added to the reads, the updates and the deletes of the table,
+// and the inserted records are checked by the condition before committed. The
+// policy of the table will be removed if policy is nil.
+func (engine *Engine) SetRowPolicy(bean interface{}, policy RowPolicy) {
+	t := rValue(bean).Type()
+	if policy == nil {
+		engine.rowPolicies.Delete(t)
+		return
+	}
+	engine.rowPolicies.Store(t, policy)
+}
+
+// rowPolicy returns the row level policy of the table
+func (engine *Engine) rowPolicy(table *phoenixormcore.Table) RowPolicy {
+	if table == nil {
+		return nil
+	}
+	if policy, ok := engine.rowPolicies.Load(table.Type); ok {
+		return policy.(RowPolicy)
+	}
+	return nil
+}
+
+// rowPolicyTable returns the table of bean if it has a row level policy, bean
+// could be a struct, a slice of structs or the maps inserted into the table of
+// the statement
+func (session *Session) rowPolicyTable(bean interface{}) *phoenixormcore.Table {
+	switch bean.(type) {
+	case map[string]interface{}, []map[string]interface{}, map[string]string, []map[string]string:
+		if table := session.statement.mapTable(); session.engine.rowPolicy(table) != nil {
+			return table
+		}
+		return nil
+	}
+
+	v := rValue(bean)
+	if v.Kind() == reflect.Slice {
+		t := v.Type().Elem()
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		v = reflect.New(t).Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	table, err := session.engine.autoMapType(v)
+	if err != nil || session.engine.rowPolicy(table) == nil {
+		return nil
+	}
+	return table
+}
+
+// applyRowPolicy resolves the condition of the row level policy of the table
+func (statement *Statement) applyRowPolicy() error {
+	policy := statement.Engine.rowPolicy(statement.RefTable)
+	if policy == nil {
+		return nil
+	}
+	cond, err := policy(statement.session.ctx, statement.policyOp)
+	if err != nil {
+		return err
+	}
+	if cond == nil {
+		cond = phoenixormbuilder.NewCond()
+	}
+	statement.policyCond = cond
+	return nil
+}
+
+// insertWithPolicy runs insert and checks the inserted records by check. The
+// transaction of autoTx is rolled back when the records are denied, while in an
+// explicit transaction the insert is undone by a savepoint so that the earlier
+// statements of the transaction are kept.
+func (session *Session) insertWithPolicy(insert func() (int64, error), check func() error) (int64, error) {
+	if session.isAutoCommit || session.isAutoTx {
+		affected, err := insert()
+		if err != nil {
+			return affected, err
+		}
+		return affected, check()
+	}
+
+	savepoint, err := session.savepoint()
+	if err != nil {
+		return 0, err
+	}
+	affected, err := insert()
+	if err == nil {
+		err = check()
+	}
+	if err != nil {
+		if rollbackErr := session.rollbackToSavepoint(savepoint); rollbackErr != nil {
+			return 0, rollbackErr
+		}
+		return 0, err
+	}
+	return affected, session.releaseSavepoint(savepoint)
+}
+
+// checkInsertPolicy checks the inserted record by the condition of the row
+// level policy, the insert should be rolled back if an error is returned
+func (session *Session) checkInsertPolicy(table *phoenixormcore.Table, bean interface{}) error {
+	return session.checkInsertPolicyByPK(table, func() (phoenixormcore.PK, error) {
+		return session.engine.idOfV(reflect.ValueOf(bean))
+	})
+}
+
+// checkMapInsertPolicy checks the record inserted by a map, the primary keys
+// are read from the map or the last insert id of the auto increment column
+func (session *Session) checkMapInsertPolicy(table *phoenixormcore.Table, columns []string, args []interface{}, res sql.Result) error {
+	return session.checkInsertPolicyByPK(table, func() (phoenixormcore.PK, error) {
+		var pk = make(phoenixormcore.PK, len(table.PrimaryKeys))
+		for i, name := range table.PrimaryKeys {
+			var found bool
+			for j, colName := range columns {
+				if strings.EqualFold(colName, name) {
+					pk[i], found = args[j], true
+					break
+				}
+			}
+			if found {
+				continue
+			}
+			if !strings.EqualFold(name, table.AutoIncrement) {
+				return nil, fmt.Errorf("the map inserted into table %s has no primary key %s to check the row policy", table.Name, name)
+			}
+			id, err := res.LastInsertId()
+			if err != nil {
+				return nil, fmt.Errorf("the map inserted into table %s has no primary key %s to check the row policy: %v", table.Name, name, err)
+			}
+			pk[i] = id
+		}
+		return pk, nil
+	})
+}
+
+// checkInsertPolicyByPK checks the inserted record of the primary keys returned
+// by pkOf by the condition of the row level policy
+func (session *Session) checkInsertPolicyByPK(table *phoenixormcore.Table, pkOf func() (phoenixormcore.PK, error)) error {
+	cond, err := session.engine.rowPolicy(table)(session.ctx, PolicyInsert)
+	if err != nil {
+		return err
+	}
+	if cond == nil || !cond.IsValid() {
+		return nil
+	}
+	if len(table.PrimaryKeys) == 0 {
+		return fmt.Errorf("table %s has no primary keys to check the row policy", table.Name)
+	}
+
+	pk, err := pkOf()
+	if err != nil {
+		return err
+	}
+	for i, name := range table.PrimaryKeys {
+		cond = cond.And(phoenixormbuilder.Eq{session.engine.Quote(name): pk[i]})
+	}
+	condSQL, condArgs, err := phoenixormbuilder.ToSQL(cond)
+	if err != nil {
+		return err
+	}
+
+	// the table is the one inserted into, which has been routed to the schema of the tenant
+	var total int64
+	sqlStr := fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", session.statement.quotedTableName(), condSQL)
+	if err := session.queryRow(sqlStr, condArgs...).Scan(&total); err != nil {
+		return err
+	}
+	if total == 0 {
+		return ErrRowPolicyDenied
+	}
+	return nil
+}
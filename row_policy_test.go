@@ -0,0 +1,168 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+type PolicyDoc struct {
+	Id    int64
+	Owner string
+	Title string
+}
+
+func TestRowPolicy(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(PolicyDoc))
+
+	ownerCol := testEngine.Quote(testEngine.GetColumnMapper().Obj2Table("Owner"))
+	errNoActor := errors.New("no actor")
+	errNotAdmin := errors.New("only admin could delete the docs")
+
+	testEngine.SetRowPolicy(new(PolicyDoc), func(ctx context.Context, op PolicyOp) (phoenixormbuilder.Cond, error) {
+		actor, ok := ActorFromContext(ctx)
+		if !ok {
+			return nil, errNoActor
+		}
+		if actor == "admin" {
+			return nil, nil
+		}
+		if op == PolicyDelete {
+			return nil, errNotAdmin
+		}
+		return phoenixormbuilder.Eq{ownerCol: actor}, nil
+	})
+	defer testEngine.SetRowPolicy(new(PolicyDoc), nil)
+
+	var admin = WithActor(context.Background(), "admin")
+	var alice = WithActor(context.Background(), "alice")
+
+	cnt, err := testEngine.Context(admin).Insert([]PolicyDoc{
+		{Owner: "alice", Title: "a1"},
+		{Owner: "bob", Title: "b1"},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	// the inserted records are checked by the policy
+	_, err = testEngine.Context(alice).Insert(&PolicyDoc{Owner: "bob", Title: "b2"})
+	assert.EqualValues(t, ErrRowPolicyDenied, err)
+	_, err = testEngine.Context(alice).Insert([]*PolicyDoc{
+		{Owner: "alice", Title: "a2"},
+		{Owner: "bob", Title: "b3"},
+	})
+	assert.EqualValues(t, ErrRowPolicyDenied, err)
+
+	_, err = testEngine.Context(alice).InsertMulti(&[]PolicyDoc{{Owner: "bob", Title: "b3"}})
+	assert.EqualValues(t, ErrRowPolicyDenied, err)
+
+	doc := PolicyDoc{Owner: "alice", Title: "a2"}
+	cnt, err = testEngine.Context(alice).InsertOne(&doc)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// the maps are checked by the policy too
+	colMapper := testEngine.GetColumnMapper()
+	ownerName, titleName := colMapper.Obj2Table("Owner"), colMapper.Obj2Table("Title")
+	_, err = testEngine.Context(alice).Table(new(PolicyDoc)).Insert(map[string]interface{}{
+		ownerName: "bob",
+		titleName: "b4",
+	})
+	assert.EqualValues(t, ErrRowPolicyDenied, err)
+
+	// in a transaction only the denied insert is undone
+	sess := testEngine.NewSession()
+	defer sess.Close()
+	sess.Context(alice)
+	assert.NoError(t, sess.Begin())
+	_, err = sess.Insert(&PolicyDoc{Owner: "alice", Title: "a3"})
+	assert.NoError(t, err)
+	_, err = sess.Insert(&PolicyDoc{Owner: "bob", Title: "b5"})
+	assert.EqualValues(t, ErrRowPolicyDenied, err)
+	_, err = sess.Table(new(PolicyDoc)).Insert(map[string]string{ownerName: "bob", titleName: "b6"})
+	assert.EqualValues(t, ErrRowPolicyDenied, err)
+	assert.NoError(t, sess.Commit())
+
+	cnt, err = testEngine.Context(admin).Count(new(PolicyDoc))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, cnt)
+	cnt, err = testEngine.Context(admin).Where(ownerCol+" = ?", "bob").Count(new(PolicyDoc))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// the reads are filtered by the policy
+	var docs []PolicyDoc
+	assert.NoError(t, testEngine.Context(alice).Asc("id").Find(&docs))
+	assert.EqualValues(t, 3, len(docs))
+	assert.EqualValues(t, "a1", docs[0].Title)
+	assert.EqualValues(t, "a2", docs[1].Title)
+	assert.EqualValues(t, "a3", docs[2].Title)
+
+	var bobDoc PolicyDoc
+	has, err := testEngine.Context(alice).Where(ownerCol+" = ?", "bob").Get(&bobDoc)
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	has, err = testEngine.Context(admin).Where(ownerCol+" = ?", "bob").Get(&bobDoc)
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	err = testEngine.Find(&docs)
+	assert.EqualValues(t, errNoActor, err)
+
+	// the updates and the deletes are checked by the policy
+	cnt, err = testEngine.Context(alice).ID(bobDoc.Id).Update(&PolicyDoc{Title: "changed"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	cnt, err = testEngine.Context(alice).ID(doc.Id).Update(&PolicyDoc{Title: "changed"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	_, err = testEngine.Context(alice).ID(doc.Id).Delete(new(PolicyDoc))
+	assert.EqualValues(t, errNotAdmin, err)
+
+	cnt, err = testEngine.Context(admin).ID(doc.Id).Delete(new(PolicyDoc))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+}
+
+func TestRowPolicyTenantSchema(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	if testEngine.Dialect().DBType() != phoenixormcore.SQLITE {
+		t.Skip("the schemas of the tenants are attached databases on sqlite")
+	}
+	assertSync(t, new(PolicyDoc))
+
+	ownerCol := testEngine.Quote(testEngine.GetColumnMapper().Obj2Table("Owner"))
+	testEngine.SetRowPolicy(new(PolicyDoc), func(ctx context.Context, op PolicyOp) (phoenixormbuilder.Cond, error) {
+		actor, _ := ActorFromContext(ctx)
+		return phoenixormbuilder.Eq{ownerCol: actor}, nil
+	})
+	testEngine.SetTenantResolver(resolveTestTenant)
+	testEngine.SetTenantSchema(func(tenant interface{}) (string, error) {
+		return "main", nil
+	})
+	defer func() {
+		testEngine.SetRowPolicy(new(PolicyDoc), nil)
+		testEngine.SetTenantResolver(nil)
+		testEngine.SetTenantSchema(nil)
+	}()
+
+	// the inserted record is checked in the schema of the tenant
+	var ctx = WithActor(withTestTenant(1), "alice")
+	cnt, err := testEngine.Context(ctx).Insert(&PolicyDoc{Owner: "alice", Title: "a1"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	_, err = testEngine.Context(ctx).Insert(&PolicyDoc{Owner: "bob", Title: "b1"})
+	assert.EqualValues(t, ErrRowPolicyDenied, err)
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"reflect"
+
+	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
+)
+
+// globalScope is a scope which is applied to all the queries of a table
+type globalScope struct {
+	name string
+	fn   func(*Session) *Session
+}
+
+// AddGlobalScope adds a named scope to the table of bean, the conditions added
+// by the scope are applied to the Find, Get, Count, Exist, Update and Delete of
+// the table like the deleted condition, for example:
+//
+//	engine.AddGlobalScope(new(User), "active", func(session *Session) *Session {
+//		return session.Where("status = ?", "active")
+//	})
+//
+// A scope with the same name of the table will be replaced.
+func (engine *Engine) AddGlobalScope(bean interface{}, name string, fn func(*Session) *Session) {
+	t := rValue(bean).Type()
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	var scopes []globalScope
+	if v, ok := engine.globalScopes.Load(t); ok {
+		scopes = v.([]globalScope)
+	}
+	// the scopes are copied since they are read without locks
+	var newScopes = make([]globalScope, 0, len(scopes)+1)
+	for _, scope := range scopes {
+		if scope.name != name {
+			newScopes = append(newScopes, scope)
+		}
+	}
+	engine.globalScopes.Store(t, append(newScopes, globalScope{name: name, fn: fn}))
+}
+
+// globalScopesOf returns the global scopes of the type
+func (engine *Engine) globalScopesOf(t reflect.Type) []globalScope {
+	if v, ok := engine.globalScopes.Load(t); ok {
+		return v.([]globalScope)
+	}
+	return nil
+}
+
+// WithoutScope don't apply the global scopes of the names, all the global scopes
+// will not be applied if no name is given
+func (engine *Engine) WithoutScope(names ...string) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.WithoutScope(names...)
+}
+
+// Scopes applies the scopes to a new session
+func (engine *Engine) Scopes(fns ...func(*Session) *Session) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Scopes(fns...)
+}
+
+// WithoutScope don't apply the global scopes of the names, all the global scopes
+// will not be applied if no name is given
+func (session *Session) WithoutScope(names ...string) *Session {
+	session.statement.WithoutScope(names...)
+	return session
+}
+
+// Scopes applies the scopes to the session, for example:
+//
+//	func Active(session *Session) *Session {
+//		return session.Where("status = ?", "active")
+//	}
+//
+//	err := engine.Scopes(Active, Paginate(1, 20)).Find(&users)
+func (session *Session) Scopes(fns ...func(*Session) *Session) *Session {
+	for _, fn := range fns {
+		session = fn(session)
+	}
+	return session
+}
+
+// WithoutScope don't apply the global scopes of the names, all the global scopes
+// will not be applied if no name is given
+func (statement *Statement) WithoutScope(names ...string) *Statement {
+	if len(names) == 0 {
+		statement.withoutScopes = map[string]bool{"": true}
+		return statement
+	}
+	if statement.withoutScopes == nil {
+		statement.withoutScopes = make(map[string]bool, len(names))
+	}
+	for _, name := range names {
+		statement.withoutScopes[name] = true
+	}
+	return statement
+}
+
+// applyGlobalScopes applies the global scopes of the table once, the conditions
+// of the scopes are kept as statement.scopeCond so that they will not be taken
+// as the conditions given by the caller
+func (statement *Statement) applyGlobalScopes() {
+	if statement.scopesApplied || statement.RefTable == nil || statement.withoutScopes[""] {
+		return
+	}
+	statement.scopesApplied = true
+
+	scopes := statement.Engine.globalScopesOf(statement.RefTable.Type)
+	if len(scopes) == 0 {
+		return
+	}
+
+	var cond = statement.cond
+	statement.cond = phoenixormbuilder.NewCond()
+	for _, scope := range scopes {
+		if !statement.withoutScopes[scope.name] {
+			scope.fn(statement.session)
+		}
+	}
+	statement.scopeCond = statement.cond
+	statement.cond = cond
+}
+
+// applyScopes applies the global scopes, the row level policy and the tenant
+// scope of the statement before the conditions are generated
+func (statement *Statement) applyScopes(addedTableName bool) (interface{}, error) {
+	statement.applyGlobalScopes()
+	if err := statement.applyRowPolicy(); err != nil {
+		return nil, err
+	}
+	return statement.scopeTenant(addedTableName)
+}
+
+// condWithScopes returns the conditions of the statement with the scopes
+func (statement *Statement) condWithScopes() phoenixormbuilder.Cond {
+	return statement.cond.And(statement.scopeCond, statement.policyCond, statement.tenantCond)
+}
+
+// bypassesScopes returns true if some of the scopes are not applied, the
+// cached records could not be used since the cache is filled by the scopes
+func (statement *Statement) bypassesScopes() bool {
+	return statement.allTenants || len(statement.withoutScopes) > 0
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ScopeArticle struct {
+	Id        int64
+	Title     string
+	Status    string
+	Published int
+}
+
+func TestGlobalScopes(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(ScopeArticle))
+
+	colMapper := testEngine.GetColumnMapper()
+	statusCol := testEngine.Quote(colMapper.Obj2Table("Status"))
+	publishedCol := testEngine.Quote(colMapper.Obj2Table("Published"))
+	titleCol := testEngine.Quote(colMapper.Obj2Table("Title"))
+
+	var articles = []ScopeArticle{
+		{Title: "a", Status: "active", Published: 1},
+		{Title: "b", Status: "active"},
+		{Title: "c", Status: "draft", Published: 1},
+		{Title: "d", Status: "draft"},
+	}
+	cnt, err := testEngine.Insert(&articles)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, cnt)
+
+	testEngine.AddGlobalScope(new(ScopeArticle), "active", func(session *Session) *Session {
+		return session.Where(statusCol+" = ?", "active")
+	})
+	testEngine.AddGlobalScope(ScopeArticle{}, "published", func(session *Session) *Session {
+		return session.And(publishedCol+" > ?", 0)
+	})
+
+	var results []ScopeArticle
+	assert.NoError(t, testEngine.Find(&results))
+	assert.EqualValues(t, 1, len(results))
+	assert.EqualValues(t, "a", results[0].Title)
+
+	cnt, err = testEngine.Count(new(ScopeArticle))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	var article ScopeArticle
+	has, err := testEngine.Where(titleCol+" = ?", "c").Get(&article)
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	has, err = testEngine.Exist(&ScopeArticle{Title: "b"})
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	// the scopes could be dropped by names
+	results = nil
+	assert.NoError(t, testEngine.WithoutScope("published").Asc("id").Find(&results))
+	assert.EqualValues(t, 2, len(results))
+	assert.EqualValues(t, "b", results[1].Title)
+
+	cnt, err = testEngine.WithoutScope().Count(new(ScopeArticle))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, cnt)
+
+	// the scopes are applied to the updates and the deletes but are not the
+	// conditions given by the callers
+	cnt, err = testEngine.Where("id > ?", 0).Update(&ScopeArticle{Title: "x"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	_, err = testEngine.Delete(new(ScopeArticle))
+	assert.EqualValues(t, ErrNeedDeletedCond, err)
+
+	cnt, err = testEngine.Where("id > ?", 0).Delete(new(ScopeArticle))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// the named scopes are reusable
+	draft := func(session *Session) *Session {
+		return session.Where(statusCol+" = ?", "draft")
+	}
+	titled := func(title string) func(*Session) *Session {
+		return func(session *Session) *Session {
+			return session.And(titleCol+" = ?", title)
+		}
+	}
+	results = nil
+	assert.NoError(t, testEngine.WithoutScope().Scopes(draft).Find(&results))
+	assert.EqualValues(t, 2, len(results))
+
+	results = nil
+	assert.NoError(t, testEngine.WithoutScope("active").Scopes(draft, titled("c")).Find(&results))
+	assert.EqualValues(t, 1, len(results))
+	assert.EqualValues(t, "c", results[0].Title)
+
+	// the scope of the same name is replaced
+	testEngine.AddGlobalScope(new(ScopeArticle), "active", func(session *Session) *Session {
+		return session
+	})
+	cnt, err = testEngine.Count(new(ScopeArticle))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+}
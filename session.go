@@ -34,6 +34,10 @@ type Session struct {
 	isAutoCommit           bool
 	isCommitedOrRollbacked bool
 	isAutoClose            bool
+	// the transaction is started by autoTx
+	isAutoTx bool
+	// the sequence of the savepoints created in the transaction
+	savepointSeq int
 
 	// Automatically reset the statement after operations that execute a SQL
 	// query such as Count(), Find(), Get(), ...
@@ -50,6 +54,9 @@ type Session struct {
 
 	afterProcessors []executedProcessor
 
+	// the audit records which will be written after committed
+	auditRecords []*AuditRecord
+
 	prepareStmt bool
 	stmtCache   map[uint32]*phoenixormcore.Stmt //key: hash.Hash32 of (queryStr, len(queryStr))
 
@@ -69,6 +76,7 @@ type Session struct {
 // Clone copy all the session's content and return a new session
 func (session *Session) Clone() *Session {
 	var sess = *session
+	sess.statement.session = &sess
 	return &sess
 }
 
@@ -76,6 +84,7 @@ func (session *Session) Clone() *Session {
 func (session *Session) Init() {
 	session.statement.Init()
 	session.statement.Engine = session.engine
+	session.statement.session = session
 	session.showSQL = session.engine.showSQL
 	session.isAutoCommit = true
 	session.isCommitedOrRollbacked = false
@@ -92,6 +101,7 @@ func (session *Session) Init() {
 	session.stmtCache = make(map[uint32]*phoenixormcore.Stmt)
 
 	session.afterProcessors = make([]executedProcessor, 0)
+	session.auditRecords = nil
 
 	session.lastSQL = ""
 	session.lastSQLArgs = []interface{}{}
@@ -261,8 +271,8 @@ func (session *Session) GroupBy(keys string) *Session {
 }
 
 // Having Generate Having statement
-func (session *Session) Having(conditions string) *Session {
-	session.statement.Having(conditions)
+func (session *Session) Having(conditions string, args ...interface{}) *Session {
+	session.statement.Having(conditions, args...)
 	return session
 }
 
@@ -285,6 +295,7 @@ func (session *Session) canCache() bool {
 	if session.statement.RefTable == nil ||
 		session.statement.JoinStr != "" ||
 		session.statement.RawSQL != "" ||
+		session.statement.isComposed() ||
 		!session.statement.UseCache ||
 		session.statement.IsForUpdate ||
 		session.tx != nil ||
@@ -333,19 +344,37 @@ type Cell *interface{}
 func (session *Session) rows2Beans(rows *phoenixormcore.Rows, fields []string,
 	table *phoenixormcore.Table, newElemFunc func([]string) reflect.Value,
 	sliceValueSetFunc func(*reflect.Value, phoenixormcore.PK) error) error {
+	var scanner *planScanner
 	for rows.Next() {
 		var newValue = newElemFunc(fields)
 		bean := newValue.Interface()
 		dataStruct := newValue.Elem()
 
-		// handle beforeClosures
-		scanResults, err := session.row2Slice(rows, fields, bean)
-		if err != nil {
-			return err
+		var pk phoenixormcore.PK
+		var err error
+		if scanner == nil && canScanByPlan(bean) {
+			scanner = session.engine.scanPlan(table, dataStruct.Type(), fields).newScanner()
 		}
-		pk, err := session.slice2Bean(scanResults, fields, bean, &dataStruct, table)
-		if err != nil {
-			return err
+		if scanner != nil {
+			for _, closure := range session.beforeClosures {
+				closure(bean)
+			}
+			pk, err = scanner.scan(session, rows, &dataStruct)
+			if err != nil {
+				return err
+			}
+			session.queueAfterLoadProcessors(bean)
+			session.takeSnapshot(bean, table)
+		} else {
+			// handle beforeClosures
+			scanResults, err := session.row2Slice(rows, fields, bean)
+			if err != nil {
+				return err
+			}
+			pk, err = session.slice2Bean(scanResults, fields, bean, &dataStruct, table)
+			if err != nil {
+				return err
+			}
 		}
 		session.afterProcessors = append(session.afterProcessors, executedProcessor{
 			fun: func(*Session, interface{}) error {
@@ -389,6 +418,48 @@ func (session *Session) slice2Bean(scanResults []interface{}, fields []string, b
 		}
 	}()
 
+	session.queueAfterLoadProcessors(bean)
+
+	var tempMap = make(map[string]int)
+	var pk phoenixormcore.PK
+	for ii, key := range fields {
+		var idx int
+		var ok bool
+		var lKey = strings.ToLower(key)
+		if idx, ok = tempMap[lKey]; !ok {
+			idx = 0
+		} else {
+			idx = idx + 1
+		}
+		tempMap[lKey] = idx
+
+		fieldValue, err := session.getField(dataStruct, key, table, idx)
+		if err != nil {
+			if !strings.Contains(err.Error(), "is not valid") {
+				session.engine.logger.Warn(err)
+			}
+			continue
+		}
+		if fieldValue == nil {
+			continue
+		}
+		rawValue := reflect.Indirect(reflect.ValueOf(scanResults[ii]))
+
+		// if row is null then ignore
+		if rawValue.Interface() == nil {
+			continue
+		}
+
+		if err := session.assignField(table.GetColumnIdx(key, idx), key, fieldValue, rawValue, &pk); err != nil {
+			return nil, err
+		}
+	}
+	session.takeSnapshot(bean, table)
+	return pk, nil
+}
+
+// queueAfterLoadProcessors queues the after closures and the AfterLoad processors of the loaded bean
+func (session *Session) queueAfterLoadProcessors(bean interface{}) {
 	// handle afterClosures
 	for _, closure := range session.afterClosures {
 		session.afterProcessors = append(session.afterProcessors, executedProcessor{
@@ -423,434 +494,428 @@ func (session *Session) slice2Bean(scanResults []interface{}, fields []string, b
 		})
 	}
 
-	var tempMap = make(map[string]int)
-	var pk phoenixormcore.PK
-	for ii, key := range fields {
-		var idx int
-		var ok bool
-		var lKey = strings.ToLower(key)
-		if idx, ok = tempMap[lKey]; !ok {
-			idx = 0
-		} else {
-			idx = idx + 1
-		}
-		tempMap[lKey] = idx
-
-		fieldValue, err := session.getField(dataStruct, key, table, idx)
-		if err != nil {
-			if !strings.Contains(err.Error(), "is not valid") {
-				session.engine.logger.Warn(err)
-			}
-			continue
-		}
-		if fieldValue == nil {
-			continue
-		}
-		rawValue := reflect.Indirect(reflect.ValueOf(scanResults[ii]))
+	if a, has := bean.(AfterLoadContextProcessor); has {
+		session.afterProcessors = append(session.afterProcessors, executedProcessor{
+			fun: func(sess *Session, bean interface{}) error {
+				return a.AfterLoadContext(sess.ctx, sess)
+			},
+			session: session,
+			bean:    bean,
+		})
+	}
+}
 
-		// if row is null then ignore
-		if rawValue.Interface() == nil {
-			continue
+// assignField converts the value scanned from database and assigns it to the field of the column,
+// the value of a primary key column is appended to pk
+func (session *Session) assignField(col *phoenixormcore.Column, key string, fieldValue *reflect.Value, rawValue reflect.Value, pk *phoenixormcore.PK) error {
+	if encryption := session.engine.columnEncryption(col); encryption != nil {
+		// the blind indexes are not read back
+		if encryption.encrypted != nil {
+			return nil
 		}
-
-		if fieldValue.CanAddr() {
-			if structConvert, ok := fieldValue.Addr().Interface().(phoenixormcore.Conversion); ok {
-				if data, err := value2Bytes(&rawValue); err == nil {
-					if err := structConvert.FromDB(data); err != nil {
-						return nil, err
-					}
-				} else {
-					return nil, err
-				}
-				continue
-			}
+		plaintext, err := session.engine.decryptColumnValue(col, rawValue.Interface())
+		if err != nil {
+			return err
 		}
+		rawValue = reflect.ValueOf(plaintext)
+	}
 
-		if _, ok := fieldValue.Interface().(phoenixormcore.Conversion); ok {
+	if fieldValue.CanAddr() {
+		if structConvert, ok := fieldValue.Addr().Interface().(phoenixormcore.Conversion); ok {
 			if data, err := value2Bytes(&rawValue); err == nil {
-				if fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil() {
-					fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+				if err := structConvert.FromDB(data); err != nil {
+					return err
 				}
-				fieldValue.Interface().(phoenixormcore.Conversion).FromDB(data)
 			} else {
-				return nil, err
+				return err
 			}
-			continue
+			return nil
 		}
+	}
 
-		rawValueType := reflect.TypeOf(rawValue.Interface())
-		vv := reflect.ValueOf(rawValue.Interface())
-		col := table.GetColumnIdx(key, idx)
-		if col.IsPrimaryKey {
-			pk = append(pk, rawValue.Interface())
+	if _, ok := fieldValue.Interface().(phoenixormcore.Conversion); ok {
+		if data, err := value2Bytes(&rawValue); err == nil {
+			if fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil() {
+				fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+			}
+			fieldValue.Interface().(phoenixormcore.Conversion).FromDB(data)
+		} else {
+			return err
 		}
-		fieldType := fieldValue.Type()
-		hasAssigned := false
+		return nil
+	}
 
-		if col.SQLType.IsJson() {
-			var bs []byte
-			if rawValueType.Kind() == reflect.String {
-				bs = []byte(vv.String())
-			} else if rawValueType.ConvertibleTo(phoenixormcore.BytesType) {
-				bs = vv.Bytes()
-			} else {
-				return nil, fmt.Errorf("unsupported database data type: %s %v", key, rawValueType.Kind())
-			}
+	rawValueType := reflect.TypeOf(rawValue.Interface())
+	vv := reflect.ValueOf(rawValue.Interface())
+	if col.IsPrimaryKey {
+		*pk = append(*pk, rawValue.Interface())
+	}
+	fieldType := fieldValue.Type()
+	hasAssigned := false
+
+	if col.SQLType.IsJson() {
+		var bs []byte
+		if rawValueType.Kind() == reflect.String {
+			bs = []byte(vv.String())
+		} else if rawValueType.ConvertibleTo(phoenixormcore.BytesType) {
+			bs = vv.Bytes()
+		} else {
+			return fmt.Errorf("unsupported database data type: %s %v", key, rawValueType.Kind())
+		}
 
-			hasAssigned = true
+		hasAssigned = true
 
-			if len(bs) > 0 {
-				if fieldType.Kind() == reflect.String {
-					fieldValue.SetString(string(bs))
-					continue
+		if len(bs) > 0 {
+			if fieldType.Kind() == reflect.String {
+				fieldValue.SetString(string(bs))
+				return nil
+			}
+			if fieldValue.CanAddr() {
+				err := DefaultJSONHandler.Unmarshal(bs, fieldValue.Addr().Interface())
+				if err != nil {
+					return err
 				}
-				if fieldValue.CanAddr() {
-					err := DefaultJSONHandler.Unmarshal(bs, fieldValue.Addr().Interface())
-					if err != nil {
-						return nil, err
-					}
-				} else {
-					x := reflect.New(fieldType)
-					err := DefaultJSONHandler.Unmarshal(bs, x.Interface())
-					if err != nil {
-						return nil, err
-					}
-					fieldValue.Set(x.Elem())
+			} else {
+				x := reflect.New(fieldType)
+				err := DefaultJSONHandler.Unmarshal(bs, x.Interface())
+				if err != nil {
+					return err
 				}
+				fieldValue.Set(x.Elem())
 			}
-
-			continue
 		}
 
-		switch fieldType.Kind() {
-		case reflect.Complex64, reflect.Complex128:
-			// TODO: reimplement this
-			var bs []byte
-			if rawValueType.Kind() == reflect.String {
-				bs = []byte(vv.String())
-			} else if rawValueType.ConvertibleTo(phoenixormcore.BytesType) {
-				bs = vv.Bytes()
-			}
+		return nil
+	}
 
-			hasAssigned = true
-			if len(bs) > 0 {
-				if fieldValue.CanAddr() {
-					err := DefaultJSONHandler.Unmarshal(bs, fieldValue.Addr().Interface())
-					if err != nil {
-						return nil, err
-					}
-				} else {
-					x := reflect.New(fieldType)
-					err := DefaultJSONHandler.Unmarshal(bs, x.Interface())
-					if err != nil {
-						return nil, err
-					}
-					fieldValue.Set(x.Elem())
+	switch fieldType.Kind() {
+	case reflect.Complex64, reflect.Complex128:
+		// TODO: reimplement this
+		var bs []byte
+		if rawValueType.Kind() == reflect.String {
+			bs = []byte(vv.String())
+		} else if rawValueType.ConvertibleTo(phoenixormcore.BytesType) {
+			bs = vv.Bytes()
+		}
+
+		hasAssigned = true
+		if len(bs) > 0 {
+			if fieldValue.CanAddr() {
+				err := DefaultJSONHandler.Unmarshal(bs, fieldValue.Addr().Interface())
+				if err != nil {
+					return err
 				}
+			} else {
+				x := reflect.New(fieldType)
+				err := DefaultJSONHandler.Unmarshal(bs, x.Interface())
+				if err != nil {
+					return err
+				}
+				fieldValue.Set(x.Elem())
 			}
+		}
+	case reflect.Slice, reflect.Array:
+		switch rawValueType.Kind() {
 		case reflect.Slice, reflect.Array:
-			switch rawValueType.Kind() {
-			case reflect.Slice, reflect.Array:
-				switch rawValueType.Elem().Kind() {
-				case reflect.Uint8:
-					if fieldType.Elem().Kind() == reflect.Uint8 {
-						hasAssigned = true
-						if col.SQLType.IsText() {
-							x := reflect.New(fieldType)
-							err := DefaultJSONHandler.Unmarshal(vv.Bytes(), x.Interface())
-							if err != nil {
-								return nil, err
+			switch rawValueType.Elem().Kind() {
+			case reflect.Uint8:
+				if fieldType.Elem().Kind() == reflect.Uint8 {
+					hasAssigned = true
+					if col.SQLType.IsText() {
+						x := reflect.New(fieldType)
+						err := DefaultJSONHandler.Unmarshal(vv.Bytes(), x.Interface())
+						if err != nil {
+							return err
+						}
+						fieldValue.Set(x.Elem())
+					} else {
+						if fieldValue.Len() > 0 {
+							for i := 0; i < fieldValue.Len(); i++ {
+								if i < vv.Len() {
+									fieldValue.Index(i).Set(vv.Index(i))
+								}
 							}
-							fieldValue.Set(x.Elem())
 						} else {
-							if fieldValue.Len() > 0 {
-								for i := 0; i < fieldValue.Len(); i++ {
-									if i < vv.Len() {
-										fieldValue.Index(i).Set(vv.Index(i))
-									}
-								}
-							} else {
-								for i := 0; i < vv.Len(); i++ {
-									fieldValue.Set(reflect.Append(*fieldValue, vv.Index(i)))
-								}
+							for i := 0; i < vv.Len(); i++ {
+								fieldValue.Set(reflect.Append(*fieldValue, vv.Index(i)))
 							}
 						}
 					}
 				}
 			}
-		case reflect.String:
-			if rawValueType.Kind() == reflect.String {
-				hasAssigned = true
-				fieldValue.SetString(vv.String())
-			}
-		case reflect.Bool:
-			if rawValueType.Kind() == reflect.Bool {
-				hasAssigned = true
-				fieldValue.SetBool(vv.Bool())
-			}
+		}
+	case reflect.String:
+		if rawValueType.Kind() == reflect.String {
+			hasAssigned = true
+			fieldValue.SetString(vv.String())
+		}
+	case reflect.Bool:
+		if rawValueType.Kind() == reflect.Bool {
+			hasAssigned = true
+			fieldValue.SetBool(vv.Bool())
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch rawValueType.Kind() {
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			switch rawValueType.Kind() {
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				hasAssigned = true
-				fieldValue.SetInt(vv.Int())
-			}
+			hasAssigned = true
+			fieldValue.SetInt(vv.Int())
+		}
+	case reflect.Float32, reflect.Float64:
+		switch rawValueType.Kind() {
 		case reflect.Float32, reflect.Float64:
-			switch rawValueType.Kind() {
-			case reflect.Float32, reflect.Float64:
-				hasAssigned = true
-				fieldValue.SetFloat(vv.Float())
-			}
+			hasAssigned = true
+			fieldValue.SetFloat(vv.Float())
+		}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		switch rawValueType.Kind() {
 		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
-			switch rawValueType.Kind() {
-			case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
-				hasAssigned = true
-				fieldValue.SetUint(vv.Uint())
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				hasAssigned = true
-				fieldValue.SetUint(uint64(vv.Int()))
+			hasAssigned = true
+			fieldValue.SetUint(vv.Uint())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			hasAssigned = true
+			fieldValue.SetUint(uint64(vv.Int()))
+		}
+	case reflect.Struct:
+		if fieldType.ConvertibleTo(phoenixormcore.TimeType) {
+			dbTZ := session.engine.DatabaseTZ
+			if col.TimeZone != nil {
+				dbTZ = col.TimeZone
 			}
-		case reflect.Struct:
-			if fieldType.ConvertibleTo(phoenixormcore.TimeType) {
-				dbTZ := session.engine.DatabaseTZ
-				if col.TimeZone != nil {
-					dbTZ = col.TimeZone
-				}
-
-				if rawValueType == phoenixormcore.TimeType {
-					hasAssigned = true
-
-					t := vv.Convert(phoenixormcore.TimeType).Interface().(time.Time)
 
-					z, _ := t.Zone()
-					// set new location if database don't save timezone or give an incorrect timezone
-					if len(z) == 0 || t.Year() == 0 || t.Location().String() != dbTZ.String() { // !nashtsai! HACK tmp work around for lib/pq doesn't properly time with location
-						session.engine.logger.Debugf("empty zone key[%v] : %v | zone: %v | location: %+v\n", key, t, z, *t.Location())
-						t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(),
-							t.Minute(), t.Second(), t.Nanosecond(), dbTZ)
-					}
+			if rawValueType == phoenixormcore.TimeType {
+				hasAssigned = true
 
-					t = t.In(session.engine.TZLocation)
-					fieldValue.Set(reflect.ValueOf(t).Convert(fieldType))
-				} else if rawValueType == phoenixormcore.IntType || rawValueType == phoenixormcore.Int64Type ||
-					rawValueType == phoenixormcore.Int32Type {
-					hasAssigned = true
+				t := vv.Convert(phoenixormcore.TimeType).Interface().(time.Time)
 
-					t := time.Unix(vv.Int(), 0).In(session.engine.TZLocation)
-					fieldValue.Set(reflect.ValueOf(t).Convert(fieldType))
-				} else {
-					if d, ok := vv.Interface().([]uint8); ok {
-						hasAssigned = true
-						t, err := session.byte2Time(col, d)
-						if err != nil {
-							session.engine.logger.Error("byte2Time error:", err.Error())
-							hasAssigned = false
-						} else {
-							fieldValue.Set(reflect.ValueOf(t).Convert(fieldType))
-						}
-					} else if d, ok := vv.Interface().(string); ok {
-						hasAssigned = true
-						t, err := session.str2Time(col, d)
-						if err != nil {
-							session.engine.logger.Error("byte2Time error:", err.Error())
-							hasAssigned = false
-						} else {
-							fieldValue.Set(reflect.ValueOf(t).Convert(fieldType))
-						}
-					} else {
-						return nil, fmt.Errorf("rawValueType is %v, value is %v", rawValueType, vv.Interface())
-					}
-				}
-			} else if nulVal, ok := fieldValue.Addr().Interface().(sql.Scanner); ok {
-				// !<winxxp>! 增加支持sql.Scanner接口的结构，如sql.NullString
-				hasAssigned = true
-				if err := nulVal.Scan(vv.Interface()); err != nil {
-					session.engine.logger.Error("sql.Sanner error:", err.Error())
-					hasAssigned = false
-				}
-			} else if col.SQLType.IsJson() {
-				if rawValueType.Kind() == reflect.String {
-					hasAssigned = true
-					x := reflect.New(fieldType)
-					if len([]byte(vv.String())) > 0 {
-						err := DefaultJSONHandler.Unmarshal([]byte(vv.String()), x.Interface())
-						if err != nil {
-							return nil, err
-						}
-						fieldValue.Set(x.Elem())
-					}
-				} else if rawValueType.Kind() == reflect.Slice {
-					hasAssigned = true
-					x := reflect.New(fieldType)
-					if len(vv.Bytes()) > 0 {
-						err := DefaultJSONHandler.Unmarshal(vv.Bytes(), x.Interface())
-						if err != nil {
-							return nil, err
-						}
-						fieldValue.Set(x.Elem())
-					}
-				}
-			} else if session.statement.UseCascade {
-				table, err := session.engine.autoMapType(*fieldValue)
-				if err != nil {
-					return nil, err
+				z, _ := t.Zone()
+				// set new location if database don't save timezone or give an incorrect timezone
+				if len(z) == 0 || t.Year() == 0 || t.Location().String() != dbTZ.String() { // !nashtsai! HACK tmp work around for lib/pq doesn't properly time with location
+					session.engine.logger.Debugf("empty zone key[%v] : %v | zone: %v | location: %+v\n", key, t, z, *t.Location())
+					t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(),
+						t.Minute(), t.Second(), t.Nanosecond(), dbTZ)
 				}
 
+				t = t.In(session.engine.TZLocation)
+				fieldValue.Set(reflect.ValueOf(t).Convert(fieldType))
+			} else if rawValueType == phoenixormcore.IntType || rawValueType == phoenixormcore.Int64Type ||
+				rawValueType == phoenixormcore.Int32Type {
 				hasAssigned = true
-				if len(table.PrimaryKeys) != 1 {
-					return nil, errors.New("unsupported non or composited primary key cascade")
-				}
-				var pk = make(phoenixormcore.PK, len(table.PrimaryKeys))
-				pk[0], err = asKind(vv, rawValueType)
-				if err != nil {
-					return nil, err
-				}
 
-				if !isPKZero(pk) {
-					// !nashtsai! TODO for hasOne relationship, it's preferred to use join query for eager fetch
-					// however, also need to consider adding a 'lazy' attribute to xorm tag which allow hasOne
-					// property to be fetched lazily
-					structInter := reflect.New(fieldValue.Type())
-					has, err := session.ID(pk).NoCascade().get(structInter.Interface())
+				t := time.Unix(vv.Int(), 0).In(session.engine.TZLocation)
+				fieldValue.Set(reflect.ValueOf(t).Convert(fieldType))
+			} else {
+				if d, ok := vv.Interface().([]uint8); ok {
+					hasAssigned = true
+					t, err := session.byte2Time(col, d)
 					if err != nil {
-						return nil, err
+						session.engine.logger.Error("byte2Time error:", err.Error())
+						hasAssigned = false
+					} else {
+						fieldValue.Set(reflect.ValueOf(t).Convert(fieldType))
 					}
-					if has {
-						fieldValue.Set(structInter.Elem())
+				} else if d, ok := vv.Interface().(string); ok {
+					hasAssigned = true
+					t, err := session.str2Time(col, d)
+					if err != nil {
+						session.engine.logger.Error("byte2Time error:", err.Error())
+						hasAssigned = false
 					} else {
-						return nil, errors.New("cascade obj is not exist")
+						fieldValue.Set(reflect.ValueOf(t).Convert(fieldType))
 					}
+				} else {
+					return fmt.Errorf("rawValueType is %v, value is %v", rawValueType, vv.Interface())
 				}
 			}
-		case reflect.Ptr:
-			// !nashtsai! TODO merge duplicated codes above
-			switch fieldType {
-			// following types case matching ptr's native type, therefore assign ptr directly
-			case phoenixormcore.PtrStringType:
-				if rawValueType.Kind() == reflect.String {
-					x := vv.String()
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.PtrBoolType:
-				if rawValueType.Kind() == reflect.Bool {
-					x := vv.Bool()
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.PtrTimeType:
-				if rawValueType == phoenixormcore.PtrTimeType {
-					hasAssigned = true
-					var x = rawValue.Interface().(time.Time)
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.PtrFloat64Type:
-				if rawValueType.Kind() == reflect.Float64 {
-					x := vv.Float()
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.PtrUint64Type:
-				if rawValueType.Kind() == reflect.Int64 {
-					var x = uint64(vv.Int())
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.PtrInt64Type:
-				if rawValueType.Kind() == reflect.Int64 {
-					x := vv.Int()
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.PtrFloat32Type:
-				if rawValueType.Kind() == reflect.Float64 {
-					var x = float32(vv.Float())
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.PtrIntType:
-				if rawValueType.Kind() == reflect.Int64 {
-					var x = int(vv.Int())
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.PtrInt32Type:
-				if rawValueType.Kind() == reflect.Int64 {
-					var x = int32(vv.Int())
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.PtrInt8Type:
-				if rawValueType.Kind() == reflect.Int64 {
-					var x = int8(vv.Int())
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.PtrInt16Type:
-				if rawValueType.Kind() == reflect.Int64 {
-					var x = int16(vv.Int())
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.PtrUintType:
-				if rawValueType.Kind() == reflect.Int64 {
-					var x = uint(vv.Int())
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.PtrUint32Type:
-				if rawValueType.Kind() == reflect.Int64 {
-					var x = uint32(vv.Int())
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.Uint8Type:
-				if rawValueType.Kind() == reflect.Int64 {
-					var x = uint8(vv.Int())
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.Uint16Type:
-				if rawValueType.Kind() == reflect.Int64 {
-					var x = uint16(vv.Int())
-					hasAssigned = true
-					fieldValue.Set(reflect.ValueOf(&x))
-				}
-			case phoenixormcore.Complex64Type:
-				var x complex64
+		} else if nulVal, ok := fieldValue.Addr().Interface().(sql.Scanner); ok {
+			// !<winxxp>! 增加支持sql.Scanner接口的结构，如sql.NullString
+			hasAssigned = true
+			if err := nulVal.Scan(vv.Interface()); err != nil {
+				session.engine.logger.Error("sql.Sanner error:", err.Error())
+				hasAssigned = false
+			}
+		} else if col.SQLType.IsJson() {
+			if rawValueType.Kind() == reflect.String {
+				hasAssigned = true
+				x := reflect.New(fieldType)
 				if len([]byte(vv.String())) > 0 {
-					err := DefaultJSONHandler.Unmarshal([]byte(vv.String()), &x)
+					err := DefaultJSONHandler.Unmarshal([]byte(vv.String()), x.Interface())
 					if err != nil {
-						return nil, err
+						return err
 					}
-					fieldValue.Set(reflect.ValueOf(&x))
+					fieldValue.Set(x.Elem())
 				}
+			} else if rawValueType.Kind() == reflect.Slice {
 				hasAssigned = true
-			case phoenixormcore.Complex128Type:
-				var x complex128
-				if len([]byte(vv.String())) > 0 {
-					err := DefaultJSONHandler.Unmarshal([]byte(vv.String()), &x)
+				x := reflect.New(fieldType)
+				if len(vv.Bytes()) > 0 {
+					err := DefaultJSONHandler.Unmarshal(vv.Bytes(), x.Interface())
 					if err != nil {
-						return nil, err
+						return err
 					}
-					fieldValue.Set(reflect.ValueOf(&x))
+					fieldValue.Set(x.Elem())
 				}
-				hasAssigned = true
-			} // switch fieldType
-		} // switch fieldType.Kind()
+			}
+		} else if session.statement.UseCascade {
+			table, err := session.engine.autoMapType(*fieldValue)
+			if err != nil {
+				return err
+			}
 
-		// !nashtsai! for value can't be assigned directly fallback to convert to []byte then back to value
-		if !hasAssigned {
-			data, err := value2Bytes(&rawValue)
+			hasAssigned = true
+			if len(table.PrimaryKeys) != 1 {
+				return errors.New("unsupported non or composited primary key cascade")
+			}
+			var pk = make(phoenixormcore.PK, len(table.PrimaryKeys))
+			pk[0], err = asKind(vv, rawValueType)
 			if err != nil {
-				return nil, err
+				return err
 			}
 
-			if err = session.bytes2Value(col, fieldValue, data); err != nil {
-				return nil, err
+			if !isPKZero(pk) {
+				// !nashtsai! TODO for hasOne relationship, it's preferred to use join query for eager fetch
+				// however, also need to consider adding a 'lazy' attribute to xorm tag which allow hasOne
+				// property to be fetched lazily
+				structInter := reflect.New(fieldValue.Type())
+				has, err := session.ID(pk).NoCascade().get(structInter.Interface())
+				if err != nil {
+					return err
+				}
+				if has {
+					fieldValue.Set(structInter.Elem())
+				} else {
+					return errors.New("cascade obj is not exist")
+				}
+			}
+		}
+	case reflect.Ptr:
+		// !nashtsai! TODO merge duplicated codes above
+		switch fieldType {
+		// following types case matching ptr's native type, therefore assign ptr directly
+		case phoenixormcore.PtrStringType:
+			if rawValueType.Kind() == reflect.String {
+				x := vv.String()
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.PtrBoolType:
+			if rawValueType.Kind() == reflect.Bool {
+				x := vv.Bool()
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.PtrTimeType:
+			if rawValueType == phoenixormcore.PtrTimeType {
+				hasAssigned = true
+				var x = rawValue.Interface().(time.Time)
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.PtrFloat64Type:
+			if rawValueType.Kind() == reflect.Float64 {
+				x := vv.Float()
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.PtrUint64Type:
+			if rawValueType.Kind() == reflect.Int64 {
+				var x = uint64(vv.Int())
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
 			}
+		case phoenixormcore.PtrInt64Type:
+			if rawValueType.Kind() == reflect.Int64 {
+				x := vv.Int()
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.PtrFloat32Type:
+			if rawValueType.Kind() == reflect.Float64 {
+				var x = float32(vv.Float())
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.PtrIntType:
+			if rawValueType.Kind() == reflect.Int64 {
+				var x = int(vv.Int())
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.PtrInt32Type:
+			if rawValueType.Kind() == reflect.Int64 {
+				var x = int32(vv.Int())
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.PtrInt8Type:
+			if rawValueType.Kind() == reflect.Int64 {
+				var x = int8(vv.Int())
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.PtrInt16Type:
+			if rawValueType.Kind() == reflect.Int64 {
+				var x = int16(vv.Int())
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.PtrUintType:
+			if rawValueType.Kind() == reflect.Int64 {
+				var x = uint(vv.Int())
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.PtrUint32Type:
+			if rawValueType.Kind() == reflect.Int64 {
+				var x = uint32(vv.Int())
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.Uint8Type:
+			if rawValueType.Kind() == reflect.Int64 {
+				var x = uint8(vv.Int())
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.Uint16Type:
+			if rawValueType.Kind() == reflect.Int64 {
+				var x = uint16(vv.Int())
+				hasAssigned = true
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+		case phoenixormcore.Complex64Type:
+			var x complex64
+			if len([]byte(vv.String())) > 0 {
+				err := DefaultJSONHandler.Unmarshal([]byte(vv.String()), &x)
+				if err != nil {
+					return err
+				}
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+			hasAssigned = true
+		case phoenixormcore.Complex128Type:
+			var x complex128
+			if len([]byte(vv.String())) > 0 {
+				err := DefaultJSONHandler.Unmarshal([]byte(vv.String()), &x)
+				if err != nil {
+					return err
+				}
+				fieldValue.Set(reflect.ValueOf(&x))
+			}
+			hasAssigned = true
+		} // switch fieldType
+	} // switch fieldType.Kind()
+
+	// !nashtsai! for value can't be assigned directly fallback to convert to []byte then back to value
+	if !hasAssigned {
+		data, err := value2Bytes(&rawValue)
+		if err != nil {
+			return err
+		}
+
+		if err = session.bytes2Value(col, fieldValue, data); err != nil {
+			return err
 		}
 	}
-	return pk, nil
+	return nil
 }
 
 // saveLastSQL stores executed query information
@@ -875,6 +940,12 @@ func (session *Session) LastSQL() (string, []interface{}) {
 	return session.lastSQL, session.lastSQLArgs
 }
 
+// OnlyDeleted only queries, updates or deletes the soft deleted records
+func (session *Session) OnlyDeleted() *Session {
+	session.statement.OnlyDeleted()
+	return session
+}
+
 // Unscoped always disable struct tag "deleted"
 func (session *Session) Unscoped() *Session {
 	session.statement.Unscoped()
@@ -0,0 +1,203 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"strings"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// Over is the window of a window function
+type Over struct {
+	PartitionBy []string
+	OrderBy     string
+}
+
+// AggregateQuery is a query of the aggregates and the window functions of a
+// table, the results could be scanned into the structs whose fields are mapped
+// to the aliases or into []map[string]interface{}. For example:
+//
+//	var stats []struct {
+//		Dept     string
+//		Total    int64
+//		AvgScore float64
+//	}
+//	err := engine.Aggregate(new(Employee)).GroupBy("dept").
+//		Count("*", "total").Avg("score", "avg_score").
+//		Having("count(*) > ?", 1).Find(&stats)
+type AggregateQuery struct {
+	session *Session
+	groupBy []string
+	exprs   []string
+}
+
+// Aggregate returns an aggregate query of the table on a new session
+func (engine *Engine) Aggregate(tableNameOrBean interface{}) *AggregateQuery {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Aggregate(tableNameOrBean)
+}
+
+// Aggregate returns an aggregate query of the table on the session, the
+// conditions of the session are applied to the query
+func (session *Session) Aggregate(tableNameOrBean interface{}) *AggregateQuery {
+	session.Table(tableNameOrBean)
+	return &AggregateQuery{session: session}
+}
+
+// Session returns the session of the query
+func (q *AggregateQuery) Session() *Session {
+	return q.session
+}
+
+// Where provides custom query condition
+func (q *AggregateQuery) Where(query interface{}, args ...interface{}) *AggregateQuery {
+	q.session.Where(query, args...)
+	return q
+}
+
+// And provides custom query condition
+func (q *AggregateQuery) And(query interface{}, args ...interface{}) *AggregateQuery {
+	q.session.And(query, args...)
+	return q
+}
+
+// GroupBy groups the records by the columns which are also selected
+func (q *AggregateQuery) GroupBy(columns ...string) *AggregateQuery {
+	for _, column := range columns {
+		q.groupBy = append(q.groupBy, q.quoteExpr(column))
+	}
+	return q
+}
+
+// Cols selects the columns without grouping, e.g. the columns of the records
+// selected with window functions
+func (q *AggregateQuery) Cols(columns ...string) *AggregateQuery {
+	for _, column := range columns {
+		q.exprs = append(q.exprs, q.quoteExpr(column))
+	}
+	return q
+}
+
+// Count selects the count of the column as alias, all the records are counted
+// if the column is * or empty
+func (q *AggregateQuery) Count(column, alias string) *AggregateQuery {
+	if column == "" {
+		column = "*"
+	}
+	return q.Expr(fmt.Sprintf("count(%s)", q.quoteExpr(column)), alias)
+}
+
+// CountDistinct selects the count of the distinct values of the column as alias
+func (q *AggregateQuery) CountDistinct(column, alias string) *AggregateQuery {
+	return q.Expr(fmt.Sprintf("count(DISTINCT %s)", q.quoteExpr(column)), alias)
+}
+
+// Sum selects the sum of the column as alias
+func (q *AggregateQuery) Sum(column, alias string) *AggregateQuery {
+	return q.Expr(fmt.Sprintf("sum(%s)", q.quoteExpr(column)), alias)
+}
+
+// Avg selects the average of the column as alias
+func (q *AggregateQuery) Avg(column, alias string) *AggregateQuery {
+	column = q.quoteExpr(column)
+	// MSSQL returns the average of the integers as an integer
+	if q.session.engine.dialect.DBType() == phoenixormcore.MSSQL {
+		column = fmt.Sprintf("CAST(%s AS FLOAT)", column)
+	}
+	return q.Expr(fmt.Sprintf("avg(%s)", column), alias)
+}
+
+// Min selects the minimum of the column as alias
+func (q *AggregateQuery) Min(column, alias string) *AggregateQuery {
+	return q.Expr(fmt.Sprintf("min(%s)", q.quoteExpr(column)), alias)
+}
+
+// Max selects the maximum of the column as alias
+func (q *AggregateQuery) Max(column, alias string) *AggregateQuery {
+	return q.Expr(fmt.Sprintf("max(%s)", q.quoteExpr(column)), alias)
+}
+
+// Expr selects the expression as alias, the expression is not quoted
+func (q *AggregateQuery) Expr(expr, alias string) *AggregateQuery {
+	if alias != "" {
+		expr += " AS " + q.session.engine.Quote(alias)
+	}
+	q.exprs = append(q.exprs, expr)
+	return q
+}
+
+// Window selects the window function over the window as alias, e.g.
+//
+//	q.Window("sum(score)", "dept_total", Over{PartitionBy: []string{"dept"}})
+func (q *AggregateQuery) Window(fn, alias string, over Over) *AggregateQuery {
+	var parts = make([]string, 0, 2)
+	if len(over.PartitionBy) > 0 {
+		var columns = make([]string, len(over.PartitionBy))
+		for i, column := range over.PartitionBy {
+			columns[i] = q.quoteExpr(column)
+		}
+		parts = append(parts, "PARTITION BY "+strings.Join(columns, ", "))
+	}
+	if over.OrderBy != "" {
+		parts = append(parts, "ORDER BY "+over.OrderBy)
+	} else if strings.HasPrefix(strings.ToUpper(fn), "ROW_NUMBER") &&
+		q.session.engine.dialect.DBType() == phoenixormcore.MSSQL {
+		// MSSQL requires the order of the ranking functions
+		parts = append(parts, "ORDER BY (SELECT NULL)")
+	}
+	return q.Expr(fmt.Sprintf("%s OVER (%s)", fn, strings.Join(parts, " ")), alias)
+}
+
+// RowNumber selects the row number of the records in the window as alias
+func (q *AggregateQuery) RowNumber(alias string, over Over) *AggregateQuery {
+	return q.Window("ROW_NUMBER()", alias, over)
+}
+
+// Having filters the groups by the conditions of the aggregates, the aliases
+// could not be used by the conditions on MSSQL
+func (q *AggregateQuery) Having(conditions string, args ...interface{}) *AggregateQuery {
+	q.session.Having(conditions, args...)
+	return q
+}
+
+// OrderBy orders the results by the order string, e.g. "total DESC"
+func (q *AggregateQuery) OrderBy(order string) *AggregateQuery {
+	q.session.OrderBy(order)
+	return q
+}
+
+// Limit provide limit and offset query condition
+func (q *AggregateQuery) Limit(limit int, start ...int) *AggregateQuery {
+	q.session.Limit(limit, start...)
+	return q
+}
+
+// Find scans the results into rowsSlicePtr which should be a pointer of a
+// slice of structs or of map[string]interface{}
+func (q *AggregateQuery) Find(rowsSlicePtr interface{}) error {
+	var columns = make([]string, 0, len(q.groupBy)+len(q.exprs))
+	columns = append(columns, q.groupBy...)
+	columns = append(columns, q.exprs...)
+	if len(columns) == 0 {
+		return ErrNoAggregate
+	}
+
+	q.session.Select(strings.Join(columns, ", "))
+	if len(q.groupBy) > 0 {
+		q.session.GroupBy(strings.Join(q.groupBy, ", "))
+	}
+	return q.session.Find(rowsSlicePtr)
+}
+
+// quoteExpr quotes the column name, the expressions are not quoted
+func (q *AggregateQuery) quoteExpr(column string) string {
+	if column == "*" || strings.ContainsAny(column, "( ") {
+		return column
+	}
+	return q.session.engine.Quote(column)
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type AggregateSale struct {
+	Id      int64
+	Region  string
+	Product string
+	Amount  int
+	Deleted int64 `xorm:"deleted"`
+}
+
+func TestAggregateQuery(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(AggregateSale))
+
+	colMapper := testEngine.GetColumnMapper()
+	regionCol := colMapper.Obj2Table("Region")
+	productCol := colMapper.Obj2Table("Product")
+	amountCol := colMapper.Obj2Table("Amount")
+	quote := testEngine.Quote
+
+	var sales = []AggregateSale{
+		{Region: "east", Product: "a", Amount: 10},
+		{Region: "east", Product: "a", Amount: 20},
+		{Region: "east", Product: "b", Amount: 30},
+		{Region: "west", Product: "a", Amount: 5},
+	}
+	cnt, err := testEngine.Insert(&sales)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, cnt)
+
+	// the deleted records are not aggregated
+	var deleted = AggregateSale{Region: "north", Product: "c", Amount: 100}
+	cnt, err = testEngine.Insert(&deleted)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	cnt, err = testEngine.ID(deleted.Id).Delete(new(AggregateSale))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	type RegionStat struct {
+		Region   string
+		Total    int64
+		Products int64
+		Amount   int64
+		AvgAmt   float64
+		MinAmt   int
+		MaxAmt   int
+	}
+	var stats []RegionStat
+	err = testEngine.Aggregate(new(AggregateSale)).GroupBy(regionCol).
+		Count("*", "total").
+		CountDistinct(productCol, "products").
+		Sum(amountCol, "amount").
+		Avg(amountCol, "avg_amt").
+		Min(amountCol, "min_amt").
+		Max(amountCol, "max_amt").
+		OrderBy(quote(regionCol)).
+		Find(&stats)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(stats))
+	assert.EqualValues(t, RegionStat{"east", 3, 2, 60, 20, 10, 30}, stats[0])
+	assert.EqualValues(t, RegionStat{"west", 1, 1, 5, 5, 5, 5}, stats[1])
+
+	// having with args
+	var results []map[string]interface{}
+	err = testEngine.Aggregate(new(AggregateSale)).
+		Where(quote(amountCol)+" > ?", 0).
+		GroupBy(regionCol, productCol).
+		Count("", "total").
+		Having("count(*) > ?", 1).
+		Find(&results)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(results))
+	assert.EqualValues(t, "a", asString(results[0][productCol]))
+	assert.EqualValues(t, "2", asString(results[0]["total"]))
+
+	// paging of the groups
+	stats = nil
+	err = testEngine.Aggregate(new(AggregateSale)).GroupBy(regionCol).Count("*", "total").
+		OrderBy(quote(regionCol)).Limit(1, 1).Find(&stats)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(stats))
+	assert.EqualValues(t, "west", stats[0].Region)
+
+	// window functions
+	type RankedSale struct {
+		Product     string
+		Amount      int
+		Rn          int
+		RegionTotal int
+	}
+	var ranked []RankedSale
+	err = testEngine.Aggregate(new(AggregateSale)).
+		Where(quote(regionCol)+" = ?", "east").
+		Cols(productCol, amountCol).
+		RowNumber("rn", Over{PartitionBy: []string{productCol}, OrderBy: quote(amountCol) + " DESC"}).
+		Window("sum("+quote(amountCol)+")", "region_total", Over{PartitionBy: []string{regionCol}}).
+		OrderBy(quote(productCol) + ", rn").
+		Find(&ranked)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []RankedSale{
+		{"a", 20, 1, 60},
+		{"a", 10, 2, 60},
+		{"b", 30, 1, 60},
+	}, ranked)
+
+	err = testEngine.Aggregate(new(AggregateSale)).Find(&stats)
+	assert.EqualValues(t, ErrNoAggregate, err)
+}
@@ -0,0 +1,225 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// auditImage is the image of a row, the keys are the column names
+type auditImage map[string]interface{}
+
+func (image auditImage) get(colName string) (interface{}, bool) {
+	if v, ok := image[colName]; ok {
+		return v, true
+	}
+	for k, v := range image {
+		if strings.EqualFold(k, colName) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// auditTable returns the table of bean if it's audited, otherwise nil
+func (session *Session) auditTable(bean interface{}) *phoenixormcore.Table {
+	if atomic.LoadInt32(&session.engine.auditEnabled) == 0 {
+		return nil
+	}
+
+	v := rValue(bean)
+	if v.Kind() == reflect.Slice {
+		t := v.Type().Elem()
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		v = reflect.New(t).Elem()
+	}
+
+	var table = session.statement.RefTable
+	if v.Kind() == reflect.Struct {
+		var err error
+		if table, err = session.engine.autoMapType(v); err != nil {
+			return nil
+		}
+	}
+	if !session.engine.isAudited(table) {
+		return nil
+	}
+	return table
+}
+
+// auditRead reads the images of the rows, the statement will not be reset
+func (session *Session) auditRead(sqlStr string, args ...interface{}) ([]auditImage, error) {
+	for _, filter := range session.engine.dialect.Filters() {
+		sqlStr = filter.Do(sqlStr, session.engine.dialect, session.statement.RefTable)
+	}
+	session.engine.logSQL(sqlStr, args...)
+
+	var rows *phoenixormcore.Rows
+	var err error
+	if session.isAutoCommit {
+		rows, err = session.DB().QueryContext(session.ctx, sqlStr, args...)
+	} else {
+		rows, err = session.tx.QueryContext(session.ctx, sqlStr, args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var images []auditImage
+	for rows.Next() {
+		result, err := row2mapInterface(rows, fields)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range result {
+			if b, ok := v.([]byte); ok {
+				result[k] = string(b)
+			}
+		}
+		images = append(images, auditImage(result))
+	}
+	return images, rows.Err()
+}
+
+// auditBefore reads the images of the rows which will be changed
+func (session *Session) auditBefore(table *phoenixormcore.Table, sqlStr string, args ...interface{}) ([]auditImage, error) {
+	if len(table.PrimaryKeys) == 0 {
+		session.engine.logger.Warnf("[audit] table %s has no primary key, the changes will not be audited", table.Name)
+		return nil, nil
+	}
+	return session.auditRead(sqlStr, args...)
+}
+
+// auditPKs returns the primary keys of the images
+func auditPKs(table *phoenixormcore.Table, images []auditImage) []phoenixormcore.PK {
+	var pks = make([]phoenixormcore.PK, 0, len(images))
+	for _, image := range images {
+		var pk = make(phoenixormcore.PK, 0, len(table.PrimaryKeys))
+		for _, name := range table.PrimaryKeys {
+			v, _ := image.get(name)
+			pk = append(pk, v)
+		}
+		pks = append(pks, pk)
+	}
+	return pks
+}
+
+func auditKey(pk phoenixormcore.PK) string {
+	return fmt.Sprint([]interface{}(pk)...)
+}
+
+// auditInserted records the insert of bean
+func (session *Session) auditInserted(table *phoenixormcore.Table, tableName string, bean interface{}) error {
+	if len(table.PrimaryKeys) == 0 {
+		session.engine.logger.Warnf("[audit] table %s has no primary key, the changes will not be audited", table.Name)
+		return nil
+	}
+	pk, err := session.engine.idOfV(reflect.ValueOf(bean))
+	if err != nil {
+		return err
+	}
+	return session.auditAfter(table, tableName, AuditInsert, []phoenixormcore.PK{pk}, nil)
+}
+
+// auditAfter reads the images of the rows after changed and records the
+// differences with the before images
+func (session *Session) auditAfter(table *phoenixormcore.Table, tableName string, op AuditOp,
+	pks []phoenixormcore.PK, before []auditImage) error {
+	if len(pks) == 0 {
+		return nil
+	}
+
+	var cond = phoenixormbuilder.NewCond()
+	for _, pk := range pks {
+		var eq = phoenixormbuilder.Eq{}
+		for i, name := range table.PrimaryKeys {
+			eq[session.engine.Quote(name)] = pk[i]
+		}
+		cond = cond.Or(eq)
+	}
+	condSQL, condArgs, err := phoenixormbuilder.ToSQL(cond)
+	if err != nil {
+		return err
+	}
+	after, err := session.auditRead(fmt.Sprintf("SELECT * FROM %v WHERE %v",
+		session.engine.Quote(tableName), condSQL), condArgs...)
+	if err != nil {
+		return err
+	}
+
+	var afterImages = make(map[string]auditImage, len(after))
+	for i, pk := range auditPKs(table, after) {
+		afterImages[auditKey(pk)] = after[i]
+	}
+
+	actor, _ := ActorFromContext(session.ctx)
+	var now = time.Now().In(session.engine.TZLocation)
+	for i, pk := range pks {
+		var beforeImage auditImage
+		if i < len(before) {
+			beforeImage = before[i]
+		}
+		changes := auditChanges(table, beforeImage, afterImages[auditKey(pk)])
+		if len(changes) == 0 {
+			continue
+		}
+		session.auditRecords = append(session.auditRecords, &AuditRecord{
+			TableName: tableName,
+			PK:        pk,
+			Op:        op,
+			Changes:   changes,
+			Actor:     actor,
+			Time:      now,
+		})
+	}
+	return nil
+}
+
+// auditChanges returns the columns whose values are different in the images
+func auditChanges(table *phoenixormcore.Table, before, after auditImage) []AuditChange {
+	var changes []AuditChange
+	for _, col := range table.Columns() {
+		oldValue, _ := before.get(col.Name)
+		newValue, _ := after.get(col.Name)
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		changes = append(changes, AuditChange{
+			Column: col.Name,
+			Old:    oldValue,
+			New:    newValue,
+		})
+	}
+	return changes
+}
+
+// flushAudit writes the audit records to the sink after committed
+func (session *Session) flushAudit() {
+	if len(session.auditRecords) == 0 {
+		return
+	}
+	records := session.auditRecords
+	session.auditRecords = nil
+	if err := session.engine.getAuditSink().WriteAudit(session.ctx, records); err != nil {
+		session.engine.logger.Errorf("[audit] write %d records failed: %v", len(records), err)
+	}
+}
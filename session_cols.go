@@ -142,6 +142,40 @@ func (session *Session) Omit(columns ...string) *Session {
 	return session
 }
 
+// ColumnRef is a reference of a column, the typed columns generated by
+// cmd/xorm-cols implement it
+type ColumnRef interface {
+	ColumnName() string
+}
+
+func columnRefNames(cols []ColumnRef) []string {
+	var names = make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.ColumnName()
+	}
+	return names
+}
+
+// Columns is the same as Cols but accepts column references
+func (session *Session) Columns(cols ...ColumnRef) *Session {
+	return session.Cols(columnRefNames(cols)...)
+}
+
+// OmitColumns is the same as Omit but accepts column references
+func (session *Session) OmitColumns(cols ...ColumnRef) *Session {
+	return session.Omit(columnRefNames(cols)...)
+}
+
+// AscColumns is the same as Asc but accepts column references
+func (session *Session) AscColumns(cols ...ColumnRef) *Session {
+	return session.Asc(columnRefNames(cols)...)
+}
+
+// DescColumns is the same as Desc but accepts column references
+func (session *Session) DescColumns(cols ...ColumnRef) *Session {
+	return session.Desc(columnRefNames(cols)...)
+}
+
 // Nullable Set null when column is zero-value and nullable for update
 func (session *Session) Nullable(columns ...string) *Session {
 	session.statement.Nullable(columns...)
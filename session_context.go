@@ -21,3 +21,20 @@ func (session *Session) PingContext(ctx context.Context) error {
 	session.engine.logger.Infof("PING DATABASE %v", session.engine.DriverName())
 	return session.DB().PingContext(ctx)
 }
+
+type actorContextKey struct{}
+
+// WithActor returns a context which carries the actor of the operations, e.g.
+// the deleted_by column will be filled with it when soft deleting records.
+func WithActor(ctx context.Context, actor interface{}) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext returns the actor carried by the context
+func ActorFromContext(ctx context.Context) (interface{}, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	actor := ctx.Value(actorContextKey{})
+	return actor, actor != nil
+}
@@ -535,8 +535,18 @@ func (session *Session) bytes2Value(col *phoenixormcore.Column, fieldValue *refl
 	return nil
 }
 
-// convert a field value of a struct to interface for put into db
+// value2Interface converts the field value to the argument written to database,
+// the values of the encrypted columns are encrypted
 func (session *Session) value2Interface(col *phoenixormcore.Column, fieldValue reflect.Value) (interface{}, error) {
+	v, err := session.plainValue2Interface(col, fieldValue)
+	if err != nil {
+		return v, err
+	}
+	return session.engine.encryptColumnValue(col, v)
+}
+
+// plainValue2Interface converts the field value to the argument written to database
+func (session *Session) plainValue2Interface(col *phoenixormcore.Column, fieldValue reflect.Value) (interface{}, error) {
 	if fieldValue.CanAddr() {
 		if fieldConvert, ok := fieldValue.Addr().Interface().(phoenixormcore.Conversion); ok {
 			data, err := fieldConvert.ToDB()
@@ -8,7 +8,9 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
+	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
 	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
 )
 
@@ -82,11 +84,18 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 	if session.statement.lastError != nil {
 		return 0, session.statement.lastError
 	}
+	session.statement.policyOp = PolicyDelete
 
 	if err := session.statement.setRefBean(bean); err != nil {
 		return 0, err
 	}
 
+	if session.isAutoCommit && (session.auditTable(bean) != nil || hasAfterContextProcessor(bean, tpAfterDeleteContext)) {
+		return session.autoTx(func() (int64, error) {
+			return session.Delete(bean)
+		})
+	}
+
 	// handle before delete processors
 	for _, closure := range session.beforeClosures {
 		closure(bean)
@@ -96,14 +105,30 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 	if processor, ok := interface{}(bean).(BeforeDeleteProcessor); ok {
 		processor.BeforeDelete()
 	}
+	if processor, ok := interface{}(bean).(BeforeDeleteContextProcessor); ok {
+		if err := processor.BeforeDeleteContext(session.ctx, session); err != nil {
+			return 0, err
+		}
+	}
 
+	// the deleted column and the tenant of the session are not conditions of the
+	// records to be deleted
+	hasCond, err := session.statement.hasConds(bean)
+	if err != nil {
+		return 0, err
+	}
 	condSQL, condArgs, err := session.statement.genConds(bean)
 	if err != nil {
 		return 0, err
 	}
 	pLimitN := session.statement.LimitN
-	if len(condSQL) == 0 && (pLimitN == nil || *pLimitN == 0) {
-		return 0, ErrNeedDeletedCond
+	if !hasCond && (pLimitN == nil || *pLimitN == 0) {
+		if err := session.checkFullTable("DELETE", session.statement.TableName()); err != nil {
+			return 0, err
+		}
+		if !session.statement.allowFullTable {
+			return 0, ErrNeedDeletedCond
+		}
 	}
 
 	var tableNameNoQuote = session.statement.TableName()
@@ -149,6 +174,19 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 		}
 	}
 
+	var audited = session.engine.isAudited(table)
+	var beforeImages []auditImage
+	if audited {
+		var auditSQL = "SELECT * FROM " + tableName
+		if len(condSQL) > 0 {
+			auditSQL += " WHERE " + condSQL
+		}
+		beforeImages, err = session.auditBefore(table, auditSQL+orderSQL, condArgs...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
 	var realSQL string
 	argsForCache := make([]interface{}, 0, len(condArgs)*2)
 	if session.statement.unscoped || table.DeletedColumn() == nil { // tag "deleted" is disabled
@@ -161,9 +199,26 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 		argsForCache = append(condArgs, argsForCache...)
 
 		deletedColumn := table.DeletedColumn()
-		realSQL = fmt.Sprintf("UPDATE %v SET %v = ? WHERE %v",
+		var setSQL = session.engine.Quote(deletedColumn.Name) + " = ?"
+		var setArgs = make([]interface{}, 1, 2)
+
+		// record who deleted the record if there is an actor on the context
+		deletedByColumn := session.engine.deletedByColumn(table)
+		if deletedByColumn != nil {
+			if actor, ok := ActorFromContext(session.ctx); ok {
+				setSQL += ", " + session.engine.Quote(deletedByColumn.Name) + " = ?"
+				setArgs = append(setArgs, actor)
+
+				var colName = deletedByColumn.Name
+				session.afterClosures = append(session.afterClosures, func(bean interface{}) {
+					setColumnValue(bean, table.GetColumn(colName), actor)
+				})
+			}
+		}
+
+		realSQL = fmt.Sprintf("UPDATE %v SET %v WHERE %v",
 			session.engine.Quote(session.statement.TableName()),
-			session.engine.Quote(deletedColumn.Name),
+			setSQL,
 			condSQL)
 
 		if len(orderSQL) > 0 {
@@ -190,18 +245,15 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 			}
 		}
 
-		// !oinume! Insert nowTime to the head of session.statement.Params
-		condArgs = append(condArgs, "")
-		paramsLen := len(condArgs)
-		copy(condArgs[1:paramsLen], condArgs[0:paramsLen-1])
-
-		val, t := session.engine.nowTime(deletedColumn)
-		condArgs[0] = val
+		// !oinume! Insert the deleted value to the head of session.statement.Params
+		val, t := session.engine.deletedValue(deletedColumn)
+		setArgs[0] = val
+		condArgs = append(setArgs, condArgs...)
 
 		var colName = deletedColumn.Name
 		session.afterClosures = append(session.afterClosures, func(bean interface{}) {
 			col := table.GetColumn(colName)
-			setColumnTime(bean, col, t)
+			session.engine.setColumnDeleted(bean, col, t)
 		})
 	}
 
@@ -209,12 +261,41 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 		session.cacheDelete(table, tableNameNoQuote, deleteSQL, argsForCache...)
 	}
 
+	// the version is a condition if it's not zero
+	var version interface{}
+	if !session.statement.noAutoCondition && table.Version != "" {
+		verValue, err := table.VersionColumn().ValueOf(bean)
+		if err != nil {
+			return 0, err
+		}
+		if !isZero(verValue.Interface()) {
+			version = verValue.Interface()
+		}
+	}
+
 	session.statement.RefTable = table
+	// the statement will be reset after executing
+	var idParam = session.statement.idParam
+	var deletedCond = session.statement.condDeletedState(table)
 	res, err := session.exec(realSQL, condArgs...)
 	if err != nil {
 		return 0, err
 	}
 
+	if audited {
+		if err := session.auditAfter(table, tableNameNoQuote, AuditDelete, auditPKs(table, beforeImages), beforeImages); err != nil {
+			cleanupProcessorsClosures(&session.afterClosures)
+			return 0, err
+		}
+	}
+
+	if version != nil {
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			cleanupProcessorsClosures(&session.afterClosures)
+			return 0, session.versionConflict(table, tableNameNoQuote, idParam, deletedCond, bean, version)
+		}
+	}
+
 	// handle after delete processors
 	if session.isAutoCommit {
 		for _, closure := range session.afterClosures {
@@ -233,10 +314,8 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 				copy(afterClosures, session.afterClosures)
 				session.afterDeleteBeans[bean] = &afterClosures
 			}
-		} else {
-			if _, ok := interface{}(bean).(AfterDeleteProcessor); ok {
-				session.afterDeleteBeans[bean] = nil
-			}
+		} else if isAfterDeleteProcessor(bean) {
+			session.afterDeleteBeans[bean] = nil
 		}
 	}
 	cleanupProcessorsClosures(&session.afterClosures)
@@ -244,3 +323,126 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 
 	return res.RowsAffected()
 }
+
+// ForceDelete deletes records even if the table has a deleted column, bean's
+// non-empty fields are conditions
+func (session *Session) ForceDelete(bean interface{}) (int64, error) {
+	return session.Unscoped().Delete(bean)
+}
+
+// PurgeDeleted removes the records which were soft deleted before olderThan ago,
+// the records will be removed in batches, the batch size is the limit of the
+// session or 1000 by default. The batches of an audited table are removed in one
+// transaction.
+func (session *Session) PurgeDeleted(bean interface{}, olderThan time.Duration) (int64, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+
+	if session.statement.lastError != nil {
+		return 0, session.statement.lastError
+	}
+	session.statement.policyOp = PolicyDelete
+
+	if err := session.statement.setRefBean(bean); err != nil {
+		return 0, err
+	}
+
+	var deletedColumn = session.statement.RefTable.DeletedColumn()
+	if deletedColumn == nil {
+		return 0, ErrNoDeletedColumn
+	}
+
+	if session.isAutoCommit && session.auditTable(bean) != nil {
+		return session.autoTx(func() (int64, error) {
+			return session.PurgeDeleted(bean, olderThan)
+		})
+	}
+
+	var batchSize = 1000
+	if session.statement.LimitN != nil && *session.statement.LimitN > 0 {
+		batchSize = *session.statement.LimitN
+	}
+
+	// the batches are not the limit of the records to be purged
+	if !session.statement.cond.IsValid() {
+		if err := session.checkFullTable("DELETE", session.statement.TableName()); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := session.statement.applyScopes(false); err != nil {
+		return 0, err
+	}
+
+	var colName = session.engine.Quote(deletedColumn.Name)
+	condBefore, err := session.engine.condDeletedBefore(deletedColumn, colName, time.Now().Add(-olderThan))
+	if err != nil {
+		return 0, err
+	}
+	cond := session.statement.condWithScopes().And(
+		session.engine.condOnlyDeleted(deletedColumn, colName),
+		condBefore,
+	)
+	condSQL, condArgs, err := phoenixormbuilder.ToSQL(cond)
+	if err != nil {
+		return 0, err
+	}
+
+	var tableNameNoQuote = session.statement.TableName()
+	var tableName = session.engine.Quote(tableNameNoQuote)
+	var sqlStr string
+	switch session.engine.dialect.DBType() {
+	case phoenixormcore.POSTGRES:
+		sqlStr = fmt.Sprintf("DELETE FROM %s WHERE ctid IN (SELECT ctid FROM %s WHERE %s LIMIT %d)",
+			tableName, tableName, condSQL, batchSize)
+	case phoenixormcore.SQLITE:
+		sqlStr = fmt.Sprintf("DELETE FROM %s WHERE rowid IN (SELECT rowid FROM %s WHERE %s LIMIT %d)",
+			tableName, tableName, condSQL, batchSize)
+	case phoenixormcore.MSSQL:
+		sqlStr = fmt.Sprintf("DELETE TOP (%d) FROM %s WHERE %s", batchSize, tableName, condSQL)
+	case phoenixormcore.ORACLE:
+		sqlStr = fmt.Sprintf("DELETE FROM %s WHERE %s AND ROWNUM <= %d", tableName, condSQL, batchSize)
+	default:
+		sqlStr = fmt.Sprintf("DELETE FROM %s WHERE %s LIMIT %d", tableName, condSQL, batchSize)
+	}
+
+	var table = session.statement.RefTable
+	var audited = session.engine.isAudited(table)
+	var beforeImages []auditImage
+	if audited {
+		beforeImages, err = session.auditBefore(table, fmt.Sprintf("SELECT * FROM %s WHERE %s", tableName, condSQL), condArgs...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var total int64
+	for {
+		res, err := session.exec(sqlStr, condArgs...)
+		if err != nil {
+			return total, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += affected
+		if affected < int64(batchSize) {
+			break
+		}
+	}
+
+	if audited {
+		if err := session.auditAfter(table, tableNameNoQuote, AuditDelete, auditPKs(table, beforeImages), beforeImages); err != nil {
+			return total, err
+		}
+	}
+
+	// the soft deleted records could be cached as beans by the unscoped reads
+	if cacher := session.engine.getCacher(tableNameNoQuote); cacher != nil && total > 0 {
+		session.engine.logger.Debug("[cachePurge] clear cache table:", tableNameNoQuote)
+		cacher.ClearIds(tableNameNoQuote)
+		cacher.ClearBeans(tableNameNoQuote)
+	}
+	return total, nil
+}
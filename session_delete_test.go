@@ -5,10 +5,13 @@
 package xorm
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
 	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
 )
 
@@ -237,3 +240,215 @@ func TestUnscopeDelete(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, has)
 }
+
+func TestRestoreDeleted(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type RestoreDeleted struct {
+		Id        int64 `xorm:"pk"`
+		Name      string
+		Ver       int       `xorm:"version"`
+		UpdatedAt time.Time `xorm:"updated"`
+		DeletedAt time.Time `xorm:"deleted"`
+	}
+
+	assertSync(t, new(RestoreDeleted))
+
+	_, err := testEngine.Insert([]RestoreDeleted{{Id: 1, Name: "1"}, {Id: 2, Name: "2"}, {Id: 3, Name: "3"}})
+	assert.NoError(t, err)
+
+	affected, err := testEngine.In("id", 1, 2).Delete(&RestoreDeleted{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, affected)
+
+	var records []RestoreDeleted
+	assert.NoError(t, testEngine.OnlyDeleted().Asc("id").Find(&records))
+	assert.EqualValues(t, 2, len(records))
+	assert.EqualValues(t, 1, records[0].Id)
+	assert.False(t, records[0].DeletedAt.IsZero())
+
+	cnt, err := testEngine.OnlyDeleted().Count(new(RestoreDeleted))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	// restore with the loaded record
+	var record = records[0]
+	affected, err = testEngine.Restore(&record)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	assert.True(t, record.DeletedAt.IsZero())
+	assert.EqualValues(t, 2, record.Ver)
+	assert.False(t, record.UpdatedAt.IsZero())
+
+	var restored RestoreDeleted
+	has, err := testEngine.ID(1).NoCache().Get(&restored)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 2, restored.Ver)
+
+	// the bean is kept deleted when the version conflicts
+	var stale = records[1]
+	stale.Ver = 5
+	_, err = testEngine.Restore(&stale)
+	_, ok := err.(ErrVersionConflict)
+	assert.True(t, ok)
+	assert.False(t, stale.DeletedAt.IsZero())
+	assert.EqualValues(t, 5, stale.Ver)
+
+	// restoring a not deleted record affects nothing
+	affected, err = testEngine.Restore(&RestoreDeleted{Id: 3})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, affected)
+
+	affected, err = testEngine.ForceDelete(&RestoreDeleted{Id: 3})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	cnt, err = testEngine.Unscoped().Count(new(RestoreDeleted))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	_, err = testEngine.Restore(&Userinfo{})
+	assert.EqualValues(t, ErrNoDeletedColumn, err)
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type PurgeDeleted struct {
+		Id        int64
+		Name      string
+		DeletedAt time.Time `xorm:"deleted"`
+	}
+
+	assertSync(t, new(PurgeDeleted))
+
+	var beans = make([]PurgeDeleted, 0, 5)
+	for i := 0; i < 5; i++ {
+		beans = append(beans, PurgeDeleted{Name: fmt.Sprintf("%d", i)})
+	}
+	_, err := testEngine.Insert(beans)
+	assert.NoError(t, err)
+
+	affected, err := testEngine.Where("name <> ?", "0").Delete(new(PurgeDeleted))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, affected)
+
+	// nothing was deleted one hour ago
+	purged, err := testEngine.PurgeDeleted(new(PurgeDeleted), time.Hour)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, purged)
+
+	purged, err = testEngine.Limit(3).PurgeDeleted(new(PurgeDeleted), -time.Hour)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, purged)
+
+	cnt, err := testEngine.Unscoped().Count(new(PurgeDeleted))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// the purged records cached as beans are removed
+	var tableName = testEngine.TableName(new(PurgeDeleted))
+	cacher := NewLRUCacher(NewMemoryStore(), 1000)
+	testEngine.SetCacher(tableName, cacher)
+	defer testEngine.SetCacher(tableName, nil)
+
+	var deleted = PurgeDeleted{Name: "deleted"}
+	_, err = testEngine.Insert(&deleted)
+	assert.NoError(t, err)
+	_, err = testEngine.ID(deleted.Id).Delete(new(PurgeDeleted))
+	assert.NoError(t, err)
+	cacher.PutBean(tableName, fmt.Sprintf("[%d]", deleted.Id), &deleted)
+
+	purged, err = testEngine.PurgeDeleted(new(PurgeDeleted), -time.Hour)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, purged)
+	assert.Nil(t, cacher.GetBean(tableName, fmt.Sprintf("[%d]", deleted.Id)))
+}
+
+func TestDeletedModes(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type DeletedBoolMode struct {
+		Id        int64
+		Name      string
+		IsDeleted int8   `xorm:"deleted(bool)"`
+		DeletedBy string `xorm:"deleted_by"`
+	}
+
+	type DeletedUnixMode struct {
+		Id        int64
+		Name      string
+		DeletedAt int64 `xorm:"deleted(unix)"`
+	}
+
+	assertSync(t, new(DeletedBoolMode), new(DeletedUnixMode))
+
+	_, err := testEngine.Insert([]DeletedBoolMode{{Name: "1"}, {Name: "2"}})
+	assert.NoError(t, err)
+
+	var bean = DeletedBoolMode{Id: 1}
+	ctx := WithActor(context.Background(), "admin")
+	affected, err := testEngine.Context(ctx).Delete(&bean)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	assert.EqualValues(t, 1, bean.IsDeleted)
+	assert.EqualValues(t, "admin", bean.DeletedBy)
+
+	cnt, err := testEngine.Count(new(DeletedBoolMode))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	var deleted []DeletedBoolMode
+	assert.NoError(t, testEngine.OnlyDeleted().Find(&deleted))
+	assert.EqualValues(t, 1, len(deleted))
+	assert.EqualValues(t, 1, deleted[0].IsDeleted)
+	assert.EqualValues(t, "admin", deleted[0].DeletedBy)
+
+	affected, err = testEngine.Restore(&deleted[0])
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	assert.EqualValues(t, 0, deleted[0].IsDeleted)
+	assert.EqualValues(t, "", deleted[0].DeletedBy)
+
+	cnt, err = testEngine.Count(new(DeletedBoolMode))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	// there is no deleting time in bool mode
+	_, err = testEngine.PurgeDeleted(new(DeletedBoolMode), 0)
+	assert.EqualValues(t, ErrNotImplemented, err)
+
+	_, err = testEngine.Insert([]DeletedUnixMode{{Name: "1"}, {Name: "2"}})
+	assert.NoError(t, err)
+
+	var unixBean = DeletedUnixMode{Id: 2}
+	affected, err = testEngine.Delete(&unixBean)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	assert.True(t, unixBean.DeletedAt > 0)
+
+	var notDeleted []DeletedUnixMode
+	assert.NoError(t, testEngine.Find(&notDeleted))
+	assert.EqualValues(t, 1, len(notDeleted))
+	assert.EqualValues(t, 1, notDeleted[0].Id)
+
+	cnt, err = testEngine.OnlyDeleted().Count(new(DeletedUnixMode))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	affected, err = testEngine.PurgeDeleted(new(DeletedUnixMode), -time.Minute)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+
+	cnt, err = testEngine.Unscoped().Count(new(DeletedUnixMode))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	engine, ok := testEngine.(*Engine)
+	if !ok {
+		engine = testEngine.(*EngineGroup).Engine
+	}
+	cond, _, err := phoenixormbuilder.ToSQL(engine.CondDeleted("deleted", DeletedBool))
+	assert.NoError(t, err)
+	assert.EqualValues(t, "deleted IS NULL OR deleted=?", cond)
+}
@@ -29,6 +29,9 @@ func (session *Session) Exist(bean ...interface{}) (bool, error) {
 
 	if session.statement.RawSQL == "" {
 		if len(bean) == 0 {
+			if _, err := session.statement.applyScopes(false); err != nil {
+				return false, err
+			}
 			tableName := session.statement.TableName()
 			if len(tableName) <= 0 {
 				return false, ErrTableNotFound
@@ -36,8 +39,8 @@ func (session *Session) Exist(bean ...interface{}) (bool, error) {
 
 			tableName = session.statement.Engine.Quote(tableName)
 
-			if session.statement.cond.IsValid() {
-				condSQL, condArgs, err := phoenixormbuilder.ToSQL(session.statement.cond)
+			if cond := session.statement.condWithScopes(); cond.IsValid() {
+				condSQL, condArgs, err := phoenixormbuilder.ToSQL(cond)
 				if err != nil {
 					return false, err
 				}
@@ -8,7 +8,6 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"strings"
 
 	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
 	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
@@ -111,7 +110,9 @@ func (session *Session) find(rowsSlicePtr interface{}, condiBean ...interface{})
 		} else {
 			// !oinume! Add "<col> IS NULL" to WHERE whatever condiBean is given.
 			// See https://gitea.com/xorm/xorm/issues/179
-			if col := table.DeletedColumn(); col != nil && !session.statement.unscoped { // tag "deleted" is enabled
+			if col := table.DeletedColumn(); col != nil && session.statement.onlyDeleted {
+				autoCond = session.statement.condOnlyDeleted(col, addedTableName)
+			} else if col != nil && !session.statement.unscoped { // tag "deleted" is enabled
 				var colName = session.engine.Quote(col.Name)
 				if addedTableName {
 					var nm = session.statement.TableName()
@@ -121,7 +122,7 @@ func (session *Session) find(rowsSlicePtr interface{}, condiBean ...interface{})
 					colName = session.engine.Quote(nm) + "." + colName
 				}
 
-				autoCond = session.engine.CondDeleted(colName)
+				autoCond = session.engine.condNotDeleted(col, colName)
 			}
 		}
 	}
@@ -130,9 +131,15 @@ func (session *Session) find(rowsSlicePtr interface{}, condiBean ...interface{})
 	var args []interface{}
 	var err error
 	if session.statement.RawSQL == "" {
+		if _, err := session.statement.applyScopes(addedTableName); err != nil {
+			return err
+		}
 		if len(session.statement.TableName()) <= 0 {
 			return ErrTableNotFound
 		}
+		if err := session.checkUnlimitedFind(session.statement.TableName()); err != nil {
+			return err
+		}
 
 		var columnStr = session.statement.ColumnStr
 		if len(session.statement.selectStr) > 0 {
@@ -161,21 +168,15 @@ func (session *Session) find(rowsSlicePtr interface{}, condiBean ...interface{})
 		}
 
 		session.statement.cond = session.statement.cond.And(autoCond)
-		condSQL, condArgs, err := phoenixormbuilder.ToSQL(session.statement.cond)
+		condSQL, condArgs, err := phoenixormbuilder.ToSQL(session.statement.condWithScopes())
 		if err != nil {
 			return err
 		}
 
-		args = append(session.statement.joinArgs, condArgs...)
-		sqlStr, err = session.statement.genSelectSQL(columnStr, condSQL, true, true)
+		sqlStr, args, err = session.statement.genSelectSQL(columnStr, condSQL, condArgs, true, true)
 		if err != nil {
 			return err
 		}
-		// for mssql and use limit
-		qs := strings.Count(sqlStr, "?")
-		if len(args)*2 == qs {
-			args = append(args, args...)
-		}
 	} else {
 		sqlStr = session.statement.RawSQL
 		args = session.statement.RawParams
@@ -184,7 +185,8 @@ func (session *Session) find(rowsSlicePtr interface{}, condiBean ...interface{})
 	if session.canCache() {
 		if cacher := session.engine.getCacher(session.statement.TableName()); cacher != nil &&
 			!session.statement.IsDistinct &&
-			!session.statement.unscoped {
+			!session.statement.unscoped &&
+			!session.statement.bypassesScopes() {
 			err = session.cacheFind(sliceElementType, sqlStr, rowsSlicePtr, args...)
 			if err != ErrCacheFailed {
 				return err
@@ -441,6 +443,8 @@ func (session *Session) cacheFind(t reflect.Type, sqlStr string, rowsSlicePtr in
 			}
 		}
 
+		// the beans are found by primary keys, so no limit is needed
+		session.statement.noLimitCheck = true
 		err = session.NoCache().Table(tableName).find(beans)
 		if err != nil {
 			return err
@@ -65,7 +65,7 @@ func (session *Session) get(bean interface{}) (bool, error) {
 
 	if session.canCache() && beanValue.Elem().Kind() == reflect.Struct {
 		if cacher := session.engine.getCacher(session.statement.TableName()); cacher != nil &&
-			!session.statement.unscoped {
+			!session.statement.unscoped && !session.statement.bypassesScopes() {
 			has, err := session.cacheGet(bean, sqlStr, args...)
 			if err != ErrCacheFailed {
 				return has, err
@@ -5,6 +5,7 @@
 package xorm
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"reflect"
@@ -25,6 +26,18 @@ func (session *Session) Insert(beans ...interface{}) (int64, error) {
 		defer session.Close()
 	}
 
+	if session.isAutoCommit {
+		for _, bean := range beans {
+			// the inserted records denied by the row policies will be rolled back
+			if session.auditTable(bean) != nil || session.rowPolicyTable(bean) != nil ||
+				hasAfterContextProcessor(bean, tpAfterInsertContext) {
+				return session.autoTx(func() (int64, error) {
+					return session.Insert(beans...)
+				})
+			}
+		}
+	}
+
 	session.autoResetStatement = false
 	defer func() {
 		session.autoResetStatement = true
@@ -65,10 +78,14 @@ func (session *Session) Insert(beans ...interface{}) (int64, error) {
 			}
 		default:
 			sliceValue := reflect.Indirect(reflect.ValueOf(bean))
+			auditTable := session.auditTable(bean)
+			policyTable := session.rowPolicyTable(bean)
 			if sliceValue.Kind() == reflect.Slice {
 				size := sliceValue.Len()
 				if size > 0 {
-					if session.engine.SupportInsertMany() {
+					// the audited or the policy checked records are inserted one by one
+					// to get the primary keys
+					if session.engine.SupportInsertMany() && auditTable == nil && policyTable == nil {
 						cnt, err := session.innerInsertMulti(bean)
 						if err != nil {
 							return affected, err
@@ -76,20 +93,34 @@ func (session *Session) Insert(beans ...interface{}) (int64, error) {
 						affected += cnt
 					} else {
 						for i := 0; i < size; i++ {
-							cnt, err := session.innerInsert(sliceValue.Index(i).Interface())
+							elem := sliceValue.Index(i).Interface()
+							if auditTable != nil || policyTable != nil {
+								elem = reflect.Indirect(sliceValue.Index(i)).Addr().Interface()
+							}
+							cnt, err := session.insertOne(policyTable, elem)
 							if err != nil {
 								return affected, err
 							}
 							affected += cnt
+							if auditTable != nil {
+								if err := session.auditInserted(auditTable, session.statement.TableName(), elem); err != nil {
+									return affected, err
+								}
+							}
 						}
 					}
 				}
 			} else {
-				cnt, err := session.innerInsert(bean)
+				cnt, err := session.insertOne(policyTable, bean)
 				if err != nil {
 					return affected, err
 				}
 				affected += cnt
+				if auditTable != nil {
+					if err := session.auditInserted(auditTable, session.statement.TableName(), bean); err != nil {
+						return affected, err
+					}
+				}
 			}
 		}
 	}
@@ -97,6 +128,19 @@ func (session *Session) Insert(beans ...interface{}) (int64, error) {
 	return affected, err
 }
 
+// insertOne inserts the bean and checks it by the row level policy of the table
+// if policyTable is not nil
+func (session *Session) insertOne(policyTable *phoenixormcore.Table, bean interface{}) (int64, error) {
+	if policyTable == nil {
+		return session.innerInsert(bean)
+	}
+	return session.insertWithPolicy(func() (int64, error) {
+		return session.innerInsert(bean)
+	}, func() error {
+		return session.checkInsertPolicy(policyTable, bean)
+	})
+}
+
 func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error) {
 	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
 	if sliceValue.Kind() != reflect.Slice {
@@ -111,6 +155,11 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 		return 0, err
 	}
 
+	tenant, err := session.statement.scopeTenant(false)
+	if err != nil {
+		return 0, err
+	}
+
 	tableName := session.statement.TableName()
 	if len(tableName) <= 0 {
 		return 0, ErrTableNotFound
@@ -139,8 +188,17 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 		if processor, ok := interface{}(elemValue).(BeforeInsertProcessor); ok {
 			processor.BeforeInsert()
 		}
+		if processor, ok := interface{}(elemValue).(BeforeInsertContextProcessor); ok {
+			if err := processor.BeforeInsertContext(session.ctx, session); err != nil {
+				return 0, err
+			}
+		}
 		// --
 
+		if err := session.stampTenant(vv.Addr().Interface(), tenant); err != nil {
+			return 0, err
+		}
+
 		if i == 0 {
 			for _, col := range table.Columns() {
 				ptrFieldValue, err := col.ValueOfV(&vv)
@@ -157,10 +215,10 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 				if col.IsDeleted {
 					continue
 				}
-				if session.statement.omitColumnMap.contain(col.Name) {
+				if session.statement.omitColumnMap.contain(session.engine.columnFilterName(col)) {
 					continue
 				}
-				if len(session.statement.columnMap) > 0 && !session.statement.columnMap.contain(col.Name) {
+				if len(session.statement.columnMap) > 0 && !session.statement.columnMap.contain(session.engine.columnFilterName(col)) {
 					continue
 				}
 				if (col.IsCreated || col.IsUpdated) && session.statement.UseAutoTime {
@@ -208,10 +266,10 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 				if col.IsDeleted {
 					continue
 				}
-				if session.statement.omitColumnMap.contain(col.Name) {
+				if session.statement.omitColumnMap.contain(session.engine.columnFilterName(col)) {
 					continue
 				}
-				if len(session.statement.columnMap) > 0 && !session.statement.columnMap.contain(col.Name) {
+				if len(session.statement.columnMap) > 0 && !session.statement.columnMap.contain(session.engine.columnFilterName(col)) {
 					continue
 				}
 				if (col.IsCreated || col.IsUpdated) && session.statement.UseAutoTime {
@@ -270,6 +328,7 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 	lenAfterClosures := len(session.afterClosures)
 	for i := 0; i < size; i++ {
 		elemValue := reflect.Indirect(sliceValue.Index(i)).Addr().Interface()
+		session.takeSnapshot(elemValue, table)
 
 		// handle AfterInsertProcessor
 		if session.isAutoCommit {
@@ -289,10 +348,8 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 					copy(afterClosures, session.afterClosures)
 					session.afterInsertBeans[elemValue] = &afterClosures
 				}
-			} else {
-				if _, ok := interface{}(elemValue).(AfterInsertProcessor); ok {
-					session.afterInsertBeans[elemValue] = nil
-				}
+			} else if isAfterInsertProcessor(elemValue) {
+				session.afterInsertBeans[elemValue] = nil
 			}
 		}
 	}
@@ -303,6 +360,12 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 
 // InsertMulti insert multiple records
 func (session *Session) InsertMulti(rowsSlicePtr interface{}) (int64, error) {
+	// the audited or the policy checked records are inserted one by one by Insert
+	if reflect.Indirect(reflect.ValueOf(rowsSlicePtr)).Kind() == reflect.Slice &&
+		(session.auditTable(rowsSlicePtr) != nil || session.rowPolicyTable(rowsSlicePtr) != nil) {
+		return session.Insert(rowsSlicePtr)
+	}
+
 	if session.isAutoClose {
 		defer session.Close()
 	}
@@ -317,6 +380,11 @@ func (session *Session) InsertMulti(rowsSlicePtr interface{}) (int64, error) {
 		return 0, nil
 	}
 
+	if session.isAutoCommit && hasAfterContextProcessor(rowsSlicePtr, tpAfterInsertContext) {
+		return session.autoTx(func() (int64, error) {
+			return session.innerInsertMulti(rowsSlicePtr)
+		})
+	}
 	return session.innerInsertMulti(rowsSlicePtr)
 }
 
@@ -324,6 +392,10 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 	if err := session.statement.setRefBean(bean); err != nil {
 		return 0, err
 	}
+	tenant, err := session.statement.scopeTenant(false)
+	if err != nil {
+		return 0, err
+	}
 	if len(session.statement.TableName()) <= 0 {
 		return 0, ErrTableNotFound
 	}
@@ -339,6 +411,15 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 	if processor, ok := interface{}(bean).(BeforeInsertProcessor); ok {
 		processor.BeforeInsert()
 	}
+	if processor, ok := interface{}(bean).(BeforeInsertContextProcessor); ok {
+		if err := processor.BeforeInsertContext(session.ctx, session); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := session.stampTenant(bean, tenant); err != nil {
+		return 0, err
+	}
 
 	colNames, args, err := session.genInsertColumns(bean)
 	if err != nil {
@@ -435,6 +516,7 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 	args = buf.Args()
 
 	handleAfterInsertProcessorFunc := func(bean interface{}) {
+		session.takeSnapshot(bean, table)
 		if session.isAutoCommit {
 			for _, closure := range session.afterClosures {
 				closure(bean)
@@ -453,10 +535,8 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 					session.afterInsertBeans[bean] = &afterClosures
 				}
 
-			} else {
-				if _, ok := interface{}(bean).(AfterInsertProcessor); ok {
-					session.afterInsertBeans[bean] = nil
-				}
+			} else if isAfterInsertProcessor(bean) {
+				session.afterInsertBeans[bean] = nil
 			}
 		}
 		cleanupProcessorsClosures(&session.afterClosures) // cleanup after used
@@ -598,6 +678,10 @@ func (session *Session) InsertOne(bean interface{}) (int64, error) {
 		defer session.Close()
 	}
 
+	if session.auditTable(bean) != nil || session.rowPolicyTable(bean) != nil ||
+		hasAfterContextProcessor(bean, tpAfterInsertContext) {
+		return session.Insert(bean)
+	}
 	return session.innerInsert(bean)
 }
 
@@ -629,11 +713,11 @@ func (session *Session) genInsertColumns(bean interface{}) ([]string, []interfac
 			continue
 		}
 
-		if session.statement.omitColumnMap.contain(col.Name) {
+		if session.statement.omitColumnMap.contain(session.engine.columnFilterName(col)) {
 			continue
 		}
 
-		if len(session.statement.columnMap) > 0 && !session.statement.columnMap.contain(col.Name) {
+		if len(session.statement.columnMap) > 0 && !session.statement.columnMap.contain(session.engine.columnFilterName(col)) {
 			continue
 		}
 
@@ -729,6 +813,14 @@ func (session *Session) insertMapInterface(m map[string]interface{}) (int64, err
 		args = append(args, m[colName])
 	}
 
+	columns, args, err := session.stampMapTenant(columns, args)
+	if err != nil {
+		return 0, err
+	}
+	columns, args, err = session.engine.encryptMapValues(session.statement.mapTable(), columns, args)
+	if err != nil {
+		return 0, err
+	}
 	return session.insertMap(columns, args)
 }
 
@@ -757,6 +849,14 @@ func (session *Session) insertMapString(m map[string]string) (int64, error) {
 		args = append(args, m[colName])
 	}
 
+	columns, args, err := session.stampMapTenant(columns, args)
+	if err != nil {
+		return 0, err
+	}
+	columns, args, err = session.engine.encryptMapValues(session.statement.mapTable(), columns, args)
+	if err != nil {
+		return 0, err
+	}
 	return session.insertMap(columns, args)
 }
 
@@ -831,20 +931,29 @@ func (session *Session) insertMap(columns []string, args []interface{}) (int64,
 		}
 	}
 
-	sql := w.String()
-	args = w.Args()
+	sqlStr := w.String()
 
 	if err := session.cacheInsert(tableName); err != nil {
 		return 0, err
 	}
 
-	res, err := session.exec(sql, args...)
-	if err != nil {
-		return 0, err
+	var res sql.Result
+	var insert = func() (int64, error) {
+		var err error
+		if res, err = session.exec(sqlStr, w.Args()...); err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
 	}
-	affected, err := res.RowsAffected()
-	if err != nil {
-		return 0, err
+	policyTable := session.statement.mapTable()
+	if session.engine.rowPolicy(policyTable) == nil {
+		return insert()
 	}
-	return affected, nil
+	return session.insertWithPolicy(insert, func() error {
+		// nothing is inserted by the INSERT ... SELECT
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			return nil
+		}
+		return session.checkMapInsertPolicy(policyTable, columns, args, res)
+	})
 }
@@ -0,0 +1,59 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package xorm
+
+import (
+	"errors"
+	"iter"
+)
+
+// errStopIteration stops Iterate when the loop over All is broken
+var errStopIteration = errors.New("stop iteration")
+
+// All returns an iterator of the records, bean's non-empty fields are conditions
+// and the yielded beans are pointers of the same type as bean. The rows will be
+// closed when the loop is finished or broken, the iteration stops with the error
+// of the session context when it's done. The records will be read in batches if
+// BufferSize is set. For example:
+//
+//	for bean, err := range engine.Where("age > ?", 18).All(new(User)) {
+//		if err != nil {
+//			return err
+//		}
+//		user := bean.(*User)
+//	}
+func (session *Session) All(bean interface{}) iter.Seq2[interface{}, error] {
+	return func(yield func(interface{}, error) bool) {
+		var ctx = session.ctx
+		err := session.Iterate(bean, func(idx int, b interface{}) error {
+			if ctx != nil && ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if !yield(b, nil) {
+				return errStopIteration
+			}
+			return nil
+		})
+		if err == errStopIteration {
+			return
+		}
+		// the rows are just ended when the context is done
+		if err == nil && ctx != nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// All returns an iterator of the records, bean's non-empty fields are conditions
+func (engine *Engine) All(bean interface{}) iter.Seq2[interface{}, error] {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.All(bean)
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package xorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterateAll(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type UserIterateAll struct {
+		Id   int64
+		Name string
+	}
+
+	assertSync(t, new(UserIterateAll))
+
+	var users = make([]UserIterateAll, 0, 10)
+	for i := 0; i < 10; i++ {
+		users = append(users, UserIterateAll{Name: "user"})
+	}
+	_, err := testEngine.Insert(&users)
+	assert.NoError(t, err)
+
+	var ids []int64
+	for bean, err := range testEngine.Asc("id").All(new(UserIterateAll)) {
+		assert.NoError(t, err)
+		ids = append(ids, bean.(*UserIterateAll).Id)
+	}
+	assert.EqualValues(t, 10, len(ids))
+
+	// the rows will be closed when the loop is broken
+	session := testEngine.Where("id > ?", 0)
+	var cnt int
+	for _, err := range session.All(new(UserIterateAll)) {
+		assert.NoError(t, err)
+		cnt++
+		if cnt == 3 {
+			break
+		}
+	}
+	assert.EqualValues(t, 3, cnt)
+	assert.True(t, session.IsClosed())
+
+	// buffered mode
+	session = testEngine.NewSession()
+	defer session.Close()
+	ids = ids[:0]
+	for bean, err := range session.BufferSize(3).Asc("id").All(new(UserIterateAll)) {
+		assert.NoError(t, err)
+		ids = append(ids, bean.(*UserIterateAll).Id)
+	}
+	assert.EqualValues(t, 10, len(ids))
+	assert.True(t, ids[0] < ids[9])
+
+	// the context is honored
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cnt = 0
+	var iterErr error
+	for _, err := range session.Context(ctx).BufferSize(3).All(new(UserIterateAll)) {
+		if err != nil {
+			iterErr = err
+			break
+		}
+		cnt++
+		cancel()
+	}
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, context.Canceled, iterErr)
+}
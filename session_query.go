@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
-	"strings"
 	"time"
 
 	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
@@ -57,24 +56,16 @@ func (session *Session) genQuerySQL(sqlOrArgs ...interface{}) (string, []interfa
 	if err := session.statement.processIDParam(); err != nil {
 		return "", nil, err
 	}
-
-	condSQL, condArgs, err := phoenixormbuilder.ToSQL(session.statement.cond)
-	if err != nil {
+	if _, err := session.statement.applyScopes(len(session.statement.JoinStr) > 0); err != nil {
 		return "", nil, err
 	}
 
-	args := append(session.statement.joinArgs, condArgs...)
-	sqlStr, err := session.statement.genSelectSQL(columnStr, condSQL, true, true)
+	condSQL, condArgs, err := phoenixormbuilder.ToSQL(session.statement.condWithScopes())
 	if err != nil {
 		return "", nil, err
 	}
-	// for mssql and use limit
-	qs := strings.Count(sqlStr, "?")
-	if len(args)*2 == qs {
-		args = append(args, args...)
-	}
 
-	return sqlStr, args, nil
+	return session.statement.genSelectSQL(columnStr, condSQL, condArgs, true, true)
 }
 
 // Query runs a raw sql and return records as []map[string][]byte
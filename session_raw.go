@@ -50,12 +50,7 @@ func (session *Session) queryRows(sqlStr string, args ...interface{}) (*phoenixo
 	}
 
 	if session.isAutoCommit {
-		var db *phoenixormcore.DB
-		if session.sessionType == groupSession {
-			db = session.engine.engineGroup.Slave().DB()
-		} else {
-			db = session.DB()
-		}
+		db := session.readDB()
 
 		if session.prepareStmt {
 			// don't clear stmt since session will cache them
@@ -176,6 +171,17 @@ func (session *Session) exec(sqlStr string, args ...interface{}) (sql.Result, er
 		}
 	}
 
+	limited, err := session.checkExec(sqlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	markGroupWrite(session.ctx)
+
+	if limited {
+		return session.execLimited(session.engine.safetyPolicy.MaxAffectedRows, sqlStr, args...)
+	}
+
 	if !session.isAutoCommit {
 		return session.tx.ExecContext(session.ctx, sqlStr, args...)
 	}
@@ -225,5 +231,9 @@ func (session *Session) Exec(sqlOrArgs ...interface{}) (sql.Result, error) {
 		return nil, err
 	}
 
+	if err := session.checkRawSQL(sqlStr); err != nil {
+		return nil, err
+	}
+
 	return session.exec(sqlStr, args...)
 }
@@ -0,0 +1,168 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Restore restores the soft deleted records, bean's non-empty fields are conditions.
+// The deleted column will be reset, the updated column will be set to now and the
+// version column will be increased.
+func (session *Session) Restore(bean interface{}) (int64, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+
+	if session.statement.lastError != nil {
+		return 0, session.statement.lastError
+	}
+	session.statement.policyOp = PolicyUpdate
+
+	if err := session.statement.setRefBean(bean); err != nil {
+		return 0, err
+	}
+
+	if session.isAutoCommit && session.auditTable(bean) != nil {
+		return session.autoTx(func() (int64, error) {
+			return session.Restore(bean)
+		})
+	}
+
+	var table = session.statement.RefTable
+	var deletedColumn = table.DeletedColumn()
+	if deletedColumn == nil {
+		return 0, ErrNoDeletedColumn
+	}
+
+	deletedValue, err := deletedColumn.ValueOf(bean)
+	if err != nil {
+		return 0, err
+	}
+	if !deletedValue.CanSet() {
+		return 0, fmt.Errorf("deleted field %s of table %s could not be set", deletedColumn.FieldName, table.Name)
+	}
+
+	// the deleted time of bean should not be a condition, it's kept until restored
+	var deletedAt = reflect.New(deletedValue.Type()).Elem()
+	deletedAt.Set(*deletedValue)
+	deletedValue.Set(reflect.Zero(deletedValue.Type()))
+	session.statement.OnlyDeleted()
+	hasCond, err := session.statement.hasConds(bean)
+	if err == nil && !hasCond {
+		err = session.checkFullTable("UPDATE", session.statement.TableName())
+	}
+	if err != nil {
+		deletedValue.Set(deletedAt)
+		return 0, err
+	}
+	condSQL, condArgs, err := session.statement.genConds(bean)
+	deletedValue.Set(deletedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	var colNames = []string{session.engine.Quote(deletedColumn.Name) + " = ?"}
+	var args = []interface{}{session.engine.restoredValue(deletedColumn)}
+
+	if col := session.engine.deletedByColumn(table); col != nil {
+		colNames = append(colNames, session.engine.Quote(col.Name)+" = ?")
+		args = append(args, deletedByRestoredValue(col))
+
+		var colName = col.Name
+		session.afterClosures = append(session.afterClosures, func(bean interface{}) {
+			setColumnValue(bean, table.GetColumn(colName), nil)
+		})
+	}
+
+	if session.statement.UseAutoTime && table.Updated != "" {
+		col := table.UpdatedColumn()
+		val, t := session.engine.nowTime(col)
+		colNames = append(colNames, session.engine.Quote(col.Name)+" = ?")
+		args = append(args, val)
+
+		var colName = col.Name
+		session.afterClosures = append(session.afterClosures, func(bean interface{}) {
+			col := table.GetColumn(colName)
+			setColumnTime(bean, col, t)
+		})
+	}
+
+	var verValue *reflect.Value
+	if table.Version != "" && session.statement.checkVersion {
+		verValue, err = table.VersionColumn().ValueOf(bean)
+		if err != nil {
+			return 0, err
+		}
+		colNames = append(colNames, session.engine.Quote(table.Version)+" = "+session.engine.Quote(table.Version)+" + 1")
+	}
+
+	var tableName = session.statement.TableName()
+	sqlStr := fmt.Sprintf("UPDATE %v SET %v WHERE %v",
+		session.engine.Quote(tableName),
+		strings.Join(colNames, ", "),
+		condSQL)
+
+	var audited = session.engine.isAudited(table)
+	var beforeImages []auditImage
+	if audited {
+		beforeImages, err = session.auditBefore(table, fmt.Sprintf("SELECT * FROM %v WHERE %v",
+			session.engine.Quote(tableName), condSQL), condArgs...)
+		if err != nil {
+			cleanupProcessorsClosures(&session.afterClosures)
+			return 0, err
+		}
+	}
+
+	// the statement will be reset after executing
+	var idParam = session.statement.idParam
+	var deletedCond = session.statement.condDeletedState(table)
+	var useCache = session.statement.UseCache
+	res, err := session.exec(sqlStr, append(args, condArgs...)...)
+	if err != nil {
+		cleanupProcessorsClosures(&session.afterClosures)
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		cleanupProcessorsClosures(&session.afterClosures)
+		return 0, err
+	}
+
+	// the version is a condition if it's not zero
+	if verValue != nil && !isZero(verValue.Interface()) {
+		if affected == 0 {
+			cleanupProcessorsClosures(&session.afterClosures)
+			return 0, session.versionConflict(table, tableName, idParam, deletedCond, bean, verValue.Interface())
+		}
+		if verValue.CanSet() {
+			session.incrVersionFieldValue(verValue)
+		}
+	}
+
+	if audited {
+		if err := session.auditAfter(table, tableName, AuditUpdate, auditPKs(table, beforeImages), beforeImages); err != nil {
+			cleanupProcessorsClosures(&session.afterClosures)
+			return 0, err
+		}
+	}
+
+	deletedValue.Set(reflect.Zero(deletedValue.Type()))
+
+	if cacher := session.engine.getCacher(tableName); cacher != nil && useCache {
+		session.engine.logger.Debug("[cacheRestore] clear table ", tableName)
+		cacher.ClearIds(tableName)
+		cacher.ClearBeans(tableName)
+	}
+
+	for _, closure := range session.afterClosures {
+		closure(bean)
+	}
+	cleanupProcessorsClosures(&session.afterClosures)
+
+	return affected, nil
+}
@@ -0,0 +1,259 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// scanKind is how a column is scanned by a scan plan
+type scanKind int
+
+const (
+	// scanSkip columns have no field, they are scanned and dropped
+	scanSkip scanKind = iota
+	// scanGeneric columns are scanned into interface{} and converted by assignField
+	scanGeneric
+	scanString
+	scanInt
+	scanFloat
+	scanBool
+)
+
+var conversionType = reflect.TypeOf((*phoenixormcore.Conversion)(nil)).Elem()
+
+// scanColumn is the compiled mapping of a result column to a field
+type scanColumn struct {
+	key  string
+	col  *phoenixormcore.Column
+	kind scanKind
+	// index is the field index of the column, the field is looked up by
+	// Column.ValueOfV if it's nil, e.g. the field is in an embedded pointer
+	index []int
+}
+
+// scanPlan is the compiled mapping of the result columns to the fields of a
+// struct type. The plans are cached by Engine per table and column list, so
+// that the fields are looked up once but not for every row.
+type scanPlan struct {
+	columns []scanColumn
+}
+
+type scanPlanKey struct {
+	table  *phoenixormcore.Table
+	fields string
+}
+
+// scanPlan returns the cached scan plan of the table and the result columns
+func (engine *Engine) scanPlan(table *phoenixormcore.Table, structType reflect.Type, fields []string) *scanPlan {
+	key := scanPlanKey{table: table, fields: strings.Join(fields, "\x00")}
+	if plan, ok := engine.scanPlans.Load(key); ok {
+		return plan.(*scanPlan)
+	}
+	plan, _ := engine.scanPlans.LoadOrStore(key, engine.compileScanPlan(table, structType, fields))
+	return plan.(*scanPlan)
+}
+
+func (engine *Engine) compileScanPlan(table *phoenixormcore.Table, structType reflect.Type, fields []string) *scanPlan {
+	var plan = scanPlan{columns: make([]scanColumn, len(fields))}
+	var tempMap = make(map[string]int)
+	for i, key := range fields {
+		var lKey = strings.ToLower(key)
+		idx, ok := tempMap[lKey]
+		if ok {
+			idx = idx + 1
+		}
+		tempMap[lKey] = idx
+
+		plan.columns[i] = engine.compileScanColumn(table, structType, key, idx)
+	}
+	return &plan
+}
+
+func (engine *Engine) compileScanColumn(table *phoenixormcore.Table, structType reflect.Type, key string, idx int) scanColumn {
+	var sc = scanColumn{key: key, kind: scanSkip}
+	if sc.col = table.GetColumnIdx(key, idx); sc.col == nil {
+		return sc
+	}
+
+	sc.kind = scanGeneric
+	index, ok := fieldIndex(structType, sc.col.FieldName)
+	if !ok {
+		return sc
+	}
+	field := reflect.New(structType).Elem().FieldByIndex(index)
+	if !field.CanSet() {
+		sc.kind = scanSkip
+		return sc
+	}
+	sc.index = index
+	// the encrypted columns are decrypted by assignField
+	if engine.columnEncryption(sc.col) == nil {
+		sc.kind = scanKindOf(sc.col, field.Type())
+	}
+	return sc
+}
+
+// fieldIndex returns the index of the field whose name could be a path, e.g.
+// "Base.Id". It returns false if the field is in an embedded pointer.
+func fieldIndex(t reflect.Type, fieldName string) ([]int, bool) {
+	var index []int
+	for _, name := range strings.Split(fieldName, ".") {
+		if t.Kind() != reflect.Struct {
+			return nil, false
+		}
+		field, ok := t.FieldByName(name)
+		if !ok {
+			return nil, false
+		}
+		// the promoted fields have the index of the embedded structs
+		for _, i := range field.Index[:len(field.Index)-1] {
+			if t.Kind() != reflect.Struct {
+				return nil, false
+			}
+			t = t.Field(i).Type
+		}
+		if t.Kind() != reflect.Struct {
+			return nil, false
+		}
+		index = append(index, field.Index...)
+		t = field.Type
+	}
+	return index, true
+}
+
+// scanKindOf returns how the column of the field type could be scanned. Only the
+// plain fields of the columns whose values are returned by the drivers as the
+// same kind are scanned into the typed destinations, the others are converted
+// by assignField.
+func scanKindOf(col *phoenixormcore.Column, fieldType reflect.Type) scanKind {
+	if col.IsPrimaryKey || col.SQLType.IsJson() ||
+		fieldType.Implements(conversionType) || reflect.PtrTo(fieldType).Implements(conversionType) {
+		return scanGeneric
+	}
+
+	switch fieldType.Kind() {
+	case reflect.String:
+		if col.SQLType.IsText() {
+			return scanString
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch col.SQLType.Name {
+		case phoenixormcore.TinyInt, phoenixormcore.SmallInt, phoenixormcore.MediumInt,
+			phoenixormcore.Int, phoenixormcore.Integer, phoenixormcore.BigInt:
+			return scanInt
+		}
+	case reflect.Float32, reflect.Float64:
+		switch col.SQLType.Name {
+		case phoenixormcore.Float, phoenixormcore.Double, phoenixormcore.Real:
+			return scanFloat
+		}
+	case reflect.Bool:
+		if col.SQLType.Name == phoenixormcore.Bool {
+			return scanBool
+		}
+	}
+	return scanGeneric
+}
+
+// canScanByPlan returns true if the bean could be loaded by a scan plan, the
+// beans which need the scanned cells are loaded by row2Slice and slice2Bean
+func canScanByPlan(bean interface{}) bool {
+	if _, ok := bean.(BeforeSetProcessor); ok {
+		return false
+	}
+	if _, ok := bean.(AfterSetProcessor); ok {
+		return false
+	}
+	return true
+}
+
+// planScanner holds the scan destinations of a plan which are reused by the rows
+type planScanner struct {
+	plan  *scanPlan
+	dests []interface{}
+}
+
+func (plan *scanPlan) newScanner() *planScanner {
+	var scanner = planScanner{
+		plan:  plan,
+		dests: make([]interface{}, len(plan.columns)),
+	}
+	for i, sc := range plan.columns {
+		switch sc.kind {
+		case scanString:
+			scanner.dests[i] = new(sql.NullString)
+		case scanInt:
+			scanner.dests[i] = new(sql.NullInt64)
+		case scanFloat:
+			scanner.dests[i] = new(sql.NullFloat64)
+		case scanBool:
+			scanner.dests[i] = new(sql.NullBool)
+		default:
+			scanner.dests[i] = new(interface{})
+		}
+	}
+	return &scanner
+}
+
+// scan scans the current row into the fields of dataStruct
+func (scanner *planScanner) scan(session *Session, rows *phoenixormcore.Rows, dataStruct *reflect.Value) (phoenixormcore.PK, error) {
+	if err := rows.Scan(scanner.dests...); err != nil {
+		return nil, err
+	}
+
+	var pk phoenixormcore.PK
+	for i, sc := range scanner.plan.columns {
+		var fieldValue reflect.Value
+		switch sc.kind {
+		case scanSkip:
+			continue
+		case scanString:
+			if v := scanner.dests[i].(*sql.NullString); v.Valid {
+				dataStruct.FieldByIndex(sc.index).SetString(v.String)
+			}
+			continue
+		case scanInt:
+			if v := scanner.dests[i].(*sql.NullInt64); v.Valid {
+				dataStruct.FieldByIndex(sc.index).SetInt(v.Int64)
+			}
+			continue
+		case scanFloat:
+			if v := scanner.dests[i].(*sql.NullFloat64); v.Valid {
+				dataStruct.FieldByIndex(sc.index).SetFloat(v.Float64)
+			}
+			continue
+		case scanBool:
+			if v := scanner.dests[i].(*sql.NullBool); v.Valid {
+				dataStruct.FieldByIndex(sc.index).SetBool(v.Bool)
+			}
+			continue
+		}
+
+		if sc.index != nil {
+			fieldValue = dataStruct.FieldByIndex(sc.index)
+		} else {
+			v, err := sc.col.ValueOfV(dataStruct)
+			if err != nil || !v.IsValid() || !v.CanSet() {
+				continue
+			}
+			fieldValue = *v
+		}
+
+		rawValue := reflect.Indirect(reflect.ValueOf(scanner.dests[i]))
+		// if row is null then ignore
+		if rawValue.Interface() == nil {
+			continue
+		}
+		if err := session.assignField(sc.col, sc.key, &fieldValue, rawValue, &pk); err != nil {
+			return nil, err
+		}
+	}
+	return pk, nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ScanPlanBase struct {
+	Code  string
+	Score float64
+}
+
+type ScanPlan struct {
+	Id           int64
+	Name         string `xorm:"varchar(50)"`
+	Age          int
+	Ratio        float32
+	Active       bool
+	Nick         *string
+	Conv         ConvString `xorm:"varchar(100)"`
+	Tags         []string
+	Created      time.Time `xorm:"created"`
+	ScanPlanBase `xorm:"extends"`
+}
+
+type ScanPlanAfterSet struct {
+	ScanPlan `xorm:"extends"`
+	Sets     int `xorm:"-"`
+}
+
+func (s *ScanPlanAfterSet) AfterSet(string, Cell) {
+	s.Sets++
+}
+
+func TestScanPlan(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(ScanPlan))
+
+	nick := "nick"
+	var beans = []ScanPlan{
+		{
+			Name:         "a",
+			Age:          18,
+			Ratio:        0.5,
+			Active:       true,
+			Nick:         &nick,
+			Conv:         "conv",
+			Tags:         []string{"x", "y"},
+			ScanPlanBase: ScanPlanBase{Code: "c1", Score: 1.5},
+		},
+		{Name: "b", Age: -1},
+	}
+	for i := range beans {
+		_, err := testEngine.Insert(&beans[i])
+		assert.NoError(t, err)
+	}
+
+	colMapper := testEngine.GetColumnMapper()
+	_, err := testEngine.Table(new(ScanPlan)).ID(beans[1].Id).Update(map[string]interface{}{
+		colMapper.Obj2Table("Name"): nil,
+	})
+	assert.NoError(t, err)
+
+	var results []ScanPlan
+	assert.NoError(t, testEngine.Asc("id").Find(&results))
+	assert.EqualValues(t, 2, len(results))
+
+	assert.EqualValues(t, beans[0].Id, results[0].Id)
+	assert.EqualValues(t, "a", results[0].Name)
+	assert.EqualValues(t, 18, results[0].Age)
+	assert.EqualValues(t, 0.5, results[0].Ratio)
+	assert.True(t, results[0].Active)
+	if assert.NotNil(t, results[0].Nick) {
+		assert.EqualValues(t, "nick", *results[0].Nick)
+	}
+	assert.EqualValues(t, "prefix---conv", results[0].Conv)
+	assert.EqualValues(t, []string{"x", "y"}, results[0].Tags)
+	assert.False(t, results[0].Created.IsZero())
+	assert.EqualValues(t, "c1", results[0].Code)
+	assert.EqualValues(t, 1.5, results[0].Score)
+
+	assert.EqualValues(t, "", results[1].Name)
+	assert.EqualValues(t, -1, results[1].Age)
+	assert.False(t, results[1].Active)
+	assert.Nil(t, results[1].Nick)
+
+	// the beans need the scanned cells are loaded without the plans
+	var setResults []ScanPlanAfterSet
+	assert.NoError(t, testEngine.Table(new(ScanPlan)).Asc("id").Find(&setResults))
+	assert.EqualValues(t, 2, len(setResults))
+	for i, result := range setResults {
+		assert.True(t, result.Sets > 0)
+		assert.EqualValues(t, results[i], result.ScanPlan)
+	}
+
+	// a map keyed by the primary keys
+	var resultMap = make(map[int64]ScanPlan)
+	assert.NoError(t, testEngine.Find(&resultMap))
+	assert.EqualValues(t, 2, len(resultMap))
+	assert.EqualValues(t, "a", resultMap[beans[0].Id].Name)
+
+	// the column list of the result is a part of the plan
+	sess := testEngine.NewSession()
+	defer sess.Close()
+	var names []ScanPlan
+	assert.NoError(t, sess.NoCache().Cols(colMapper.Obj2Table("Name"), colMapper.Obj2Table("Age")).Asc("id").Find(&names))
+	assert.EqualValues(t, 2, len(names))
+	assert.EqualValues(t, 0, names[0].Id)
+	assert.EqualValues(t, "a", names[0].Name)
+	assert.EqualValues(t, 18, names[0].Age)
+	assert.EqualValues(t, "", names[0].Code)
+}
+
+type ScanPlanBench struct {
+	Id      int64
+	Name    string
+	Email   string
+	Age     int
+	Score   float64
+	Active  bool
+	Created time.Time `xorm:"created"`
+}
+
+type ScanPlanBenchAfterSet struct {
+	ScanPlanBench `xorm:"extends"`
+}
+
+func (*ScanPlanBenchAfterSet) AfterSet(string, Cell) {}
+
+func prepareScanPlanBench(b *testing.B, rows int) {
+	if err := prepareEngine(); err != nil {
+		b.Fatal(err)
+	}
+	if err := testEngine.DropTables(new(ScanPlanBench)); err != nil {
+		b.Fatal(err)
+	}
+	if err := testEngine.Sync2(new(ScanPlanBench)); err != nil {
+		b.Fatal(err)
+	}
+
+	var beans = make([]ScanPlanBench, 0, 100)
+	for i := 0; i < rows; i++ {
+		beans = append(beans, ScanPlanBench{
+			Name:   fmt.Sprintf("name%d", i),
+			Email:  fmt.Sprintf("name%d@example.com", i),
+			Age:    i % 100,
+			Score:  float64(i) / 10,
+			Active: i%2 == 0,
+		})
+		if len(beans) == cap(beans) || i == rows-1 {
+			if _, err := testEngine.Insert(&beans); err != nil {
+				b.Fatal(err)
+			}
+			beans = beans[:0]
+		}
+	}
+}
+
+// BenchmarkFindScanPlan finds the rows by a compiled scan plan
+func BenchmarkFindScanPlan(b *testing.B) {
+	prepareScanPlanBench(b, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var beans []ScanPlanBench
+		if err := testEngine.Find(&beans); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkFindSlice2Bean finds the rows without scan plans since the beans
+// implement AfterSetProcessor, as a baseline of BenchmarkFindScanPlan
+func BenchmarkFindSlice2Bean(b *testing.B) {
+	prepareScanPlanBench(b, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var beans []ScanPlanBenchAfterSet
+		if err := testEngine.Table(new(ScanPlanBench)).Find(&beans); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"reflect"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// Snapshot records the column values of a bean when it's loaded from or written
+// to database. Embed it into a struct to enable the dirty tracking of UpdateChanged,
+// it will not be mapped as a column.
+type Snapshot struct {
+	values map[string]interface{}
+}
+
+// HasSnapshot returns true if the values have been recorded
+func (s *Snapshot) HasSnapshot() bool {
+	return s != nil && s.values != nil
+}
+
+func (s *Snapshot) xormSnapshot() *Snapshot {
+	return s
+}
+
+type snapshotter interface {
+	xormSnapshot() *Snapshot
+}
+
+var (
+	snapshotType    = reflect.TypeOf(Snapshot{})
+	snapshotPtrType = reflect.TypeOf(&Snapshot{})
+)
+
+func isSnapshotType(t reflect.Type) bool {
+	return t == snapshotType || t == snapshotPtrType
+}
+
+// snapshotValues returns the values of the columns which will be written to database
+func (session *Session) snapshotValues(bean interface{}, table *phoenixormcore.Table) (map[string]interface{}, error) {
+	var values = make(map[string]interface{}, len(table.ColumnsSeq()))
+	for _, col := range table.Columns() {
+		fieldValue, err := col.ValueOf(bean)
+		if err != nil {
+			return nil, err
+		}
+		value, err := session.plainValue2Interface(col, *fieldValue)
+		if err != nil {
+			return nil, err
+		}
+		// the bytes may be modified in place
+		if b, ok := value.([]byte); ok {
+			value = append([]byte(nil), b...)
+		}
+		values[col.Name] = value
+	}
+	return values, nil
+}
+
+// takeSnapshot records the column values of bean if it embeds Snapshot
+func (session *Session) takeSnapshot(bean interface{}, table *phoenixormcore.Table) {
+	s, ok := bean.(snapshotter)
+	if !ok || s.xormSnapshot() == nil {
+		return
+	}
+	values, err := session.snapshotValues(bean, table)
+	if err != nil {
+		session.engine.logger.Error(err)
+		return
+	}
+	s.xormSnapshot().values = values
+}
+
+// changedColumns returns the columns which are changed since the snapshot was taken
+func (session *Session) changedColumns(bean interface{}, table *phoenixormcore.Table) ([]string, error) {
+	s, ok := bean.(snapshotter)
+	if !ok || !s.xormSnapshot().HasSnapshot() {
+		return nil, ErrNoSnapshot
+	}
+
+	values, err := session.snapshotValues(bean, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot = s.xormSnapshot().values
+	var colNames []string
+	for _, col := range table.Columns() {
+		// they are maintained by xorm
+		if col.IsPrimaryKey || col.IsAutoIncrement || col.IsVersion ||
+			col.IsCreated || col.IsUpdated || col.IsDeleted ||
+			col.MapType == phoenixormcore.ONLYFROMDB {
+			continue
+		}
+		if !reflect.DeepEqual(snapshot[col.Name], values[col.Name]) {
+			colNames = append(colNames, col.Name)
+		}
+	}
+	return colNames, nil
+}
+
+// UpdateChanged updates exactly the columns which are changed since bean was
+// loaded by Get or Find, zero values included. bean should embed Snapshot. The
+// primary key of bean is the condition if there are no other conditions, the
+// version and updated columns are handled as Update.
+func (session *Session) UpdateChanged(bean interface{}) (int64, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+
+	if session.statement.lastError != nil {
+		return 0, session.statement.lastError
+	}
+
+	if err := session.statement.setRefBean(bean); err != nil {
+		return 0, err
+	}
+	var table = session.statement.RefTable
+
+	colNames, err := session.changedColumns(bean, table)
+	if err != nil {
+		return 0, err
+	}
+	if len(colNames) == 0 {
+		session.resetStatement()
+		return 0, nil
+	}
+
+	if session.statement.idParam == nil && !session.statement.cond.IsValid() {
+		pk, err := session.engine.idOfV(reflect.ValueOf(bean))
+		if err != nil {
+			return 0, err
+		}
+		session.ID(pk)
+	}
+
+	affected, err := session.Cols(colNames...).Update(bean)
+	if err != nil {
+		return affected, err
+	}
+	session.takeSnapshot(bean, table)
+	return affected, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+// With adds a common table expression of the name to a new session, the
+// subquery could be a *phoenixormbuilder.Builder or a *Session, for example:
+//
+//	engine.With("recent", engine.Table("order").Where("created > ?", since)).
+//		Table("recent").Find(&orders)
+func (engine *Engine) With(name string, subquery interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.With(name, subquery)
+}
+
+// WithRecursive adds a recursive common table expression of the name to a new
+// session
+func (engine *Engine) WithRecursive(name string, subquery interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.WithRecursive(name, subquery)
+}
+
+// With adds a common table expression of the name to the session, the name
+// could contain the columns, e.g. "tree(id, parent_id)"
+func (session *Session) With(name string, subquery interface{}) *Session {
+	session.statement.With(name, subquery)
+	return session
+}
+
+// WithRecursive adds a recursive common table expression of the name to the
+// session, for example:
+//
+//	anchor := engine.Table("category").Cols("id", "parent_id").Where("id = ?", 1)
+//	recursive := engine.Table("category").Alias("c").Cols("c.id", "c.parent_id").
+//		Join("INNER", "tree", "c.parent_id = tree.id")
+//	engine.WithRecursive("tree", anchor.UnionAll(recursive)).Table("tree").Find(&categories)
+func (session *Session) WithRecursive(name string, subquery interface{}) *Session {
+	session.statement.WithRecursive(name, subquery)
+	return session
+}
+
+// Union combines the results of the subquery with the session and removes the
+// duplicated records, the order and the limit of the session are applied to
+// the combined results
+func (session *Session) Union(subquery interface{}) *Session {
+	session.statement.Union(subquery)
+	return session
+}
+
+// UnionAll combines the results of the subquery with the session, the order
+// and the limit of the session are applied to the combined results
+func (session *Session) UnionAll(subquery interface{}) *Session {
+	session.statement.UnionAll(subquery)
+	return session
+}
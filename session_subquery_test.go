@@ -0,0 +1,105 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
+)
+
+type SubqueryNode struct {
+	Id       int64
+	ParentId int64
+	Name     string
+	Score    int
+}
+
+func TestSubquery(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(SubqueryNode))
+
+	colMapper := testEngine.GetColumnMapper()
+	tableName := testEngine.GetTableMapper().Obj2Table("SubqueryNode")
+	idCol := colMapper.Obj2Table("Id")
+	parentCol := colMapper.Obj2Table("ParentId")
+	nameCol := colMapper.Obj2Table("Name")
+	scoreCol := colMapper.Obj2Table("Score")
+	quote := testEngine.Quote
+
+	cnt, err := testEngine.Insert([]SubqueryNode{
+		{ParentId: 0, Name: "a", Score: 10},
+		{ParentId: 1, Name: "b", Score: 20},
+		{ParentId: 2, Name: "c", Score: 30},
+		{ParentId: 0, Name: "d", Score: 40},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, cnt)
+
+	// derived tables
+	highScore := testEngine.Table(new(SubqueryNode)).Where(quote(scoreCol)+" > ?", 10)
+	cnt, err = testEngine.Table([]interface{}{highScore, "s"}).Where(quote("s")+"."+quote(scoreCol)+" < ?", 40).Count()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	var nodes []SubqueryNode
+	assert.NoError(t, testEngine.Table(highScore).Asc(idCol).Limit(2, 1).Find(&nodes))
+	assert.EqualValues(t, 2, len(nodes))
+	assert.EqualValues(t, "c", nodes[0].Name)
+	assert.EqualValues(t, "d", nodes[1].Name)
+
+	parents := testEngine.Table(new(SubqueryNode)).Cols(idCol, nameCol)
+	results, err := testEngine.Table(new(SubqueryNode)).Alias("n").
+		Select("n."+quote(nameCol)+", p."+quote(nameCol)+" AS parent").
+		Join("INNER", []interface{}{parents, "p"}, "p."+quote(idCol)+" = n."+quote(parentCol)).
+		Where("n."+quote(scoreCol)+" > ?", 20).
+		QueryString()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(results))
+	assert.EqualValues(t, "c", results[0][nameCol])
+	assert.EqualValues(t, "b", results[0]["parent"])
+
+	// common table expressions
+	nodes = nil
+	assert.NoError(t, testEngine.With("high", highScore).Table("high").
+		Where(quote(scoreCol)+" < ?", 40).Asc(idCol).Find(&nodes))
+	assert.EqualValues(t, 2, len(nodes))
+	assert.EqualValues(t, "b", nodes[0].Name)
+
+	anchor := testEngine.Table(new(SubqueryNode)).Where(quote(idCol)+" = ?", 1)
+	children := testEngine.Table(new(SubqueryNode)).Alias("c").Select("c.*").
+		Join("INNER", "tree", "c."+quote(parentCol)+" = "+quote("tree")+"."+quote(idCol))
+	nodes = nil
+	assert.NoError(t, testEngine.WithRecursive("tree", anchor.UnionAll(children)).Table("tree").
+		Asc(idCol).Find(&nodes))
+	assert.EqualValues(t, 3, len(nodes))
+	assert.EqualValues(t, "c", nodes[2].Name)
+
+	// unions are ordered and paged as a whole
+	results, err = testEngine.Table(new(SubqueryNode)).Cols(nameCol).Where(quote(idCol)+" = ?", 1).
+		UnionAll(testEngine.Table(new(SubqueryNode)).Cols(nameCol).Where(quote(idCol)+" > ?", 2)).
+		Desc(nameCol).Limit(2).QueryString()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(results))
+	assert.EqualValues(t, "d", results[0][nameCol])
+	assert.EqualValues(t, "c", results[1][nameCol])
+
+	// in subqueries
+	roots := testEngine.Table(new(SubqueryNode)).Cols(idCol).Where(quote(parentCol)+" = ?", 0)
+	nodes = nil
+	assert.NoError(t, testEngine.In(parentCol, roots).Find(&nodes))
+	assert.EqualValues(t, 1, len(nodes))
+	assert.EqualValues(t, "b", nodes[0].Name)
+
+	cnt, err = testEngine.NotIn(idCol, roots).Where(quote(scoreCol)+" > ?", 20).Count(new(SubqueryNode))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	rootIds := phoenixormbuilder.Select(idCol).From(tableName).Where(phoenixormbuilder.Eq{parentCol: 0})
+	cnt, err = testEngine.In(parentCol, rootIds).Count(new(SubqueryNode))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+}
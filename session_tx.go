@@ -4,6 +4,12 @@
 
 package xorm
 
+import (
+	"fmt"
+
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
 // Begin a transaction
 func (session *Session) Begin() error {
 	if session.isAutoCommit {
@@ -13,6 +19,7 @@ func (session *Session) Begin() error {
 		}
 		session.isAutoCommit = false
 		session.isCommitedOrRollbacked = false
+		session.savepointSeq = 0
 		session.tx = tx
 		session.saveLastSQL("BEGIN TRANSACTION")
 	}
@@ -25,12 +32,102 @@ func (session *Session) Rollback() error {
 		session.saveLastSQL(session.engine.dialect.RollBackStr())
 		session.isCommitedOrRollbacked = true
 		session.isAutoCommit = true
+		session.auditRecords = nil
+		session.afterInsertBeans = make(map[interface{}]*[]func(interface{}), 0)
+		session.afterUpdateBeans = make(map[interface{}]*[]func(interface{}), 0)
+		session.afterDeleteBeans = make(map[interface{}]*[]func(interface{}), 0)
 		return session.tx.Rollback()
 	}
 	return nil
 }
 
-// Commit When using transaction, Commit will commit all operations.
+// savepoint creates a savepoint in the transaction and returns its name, so that
+// a single statement could be undone without rolling back the whole transaction
+func (session *Session) savepoint() (string, error) {
+	session.savepointSeq++
+	var name = fmt.Sprintf("xorm_sp_%d", session.savepointSeq)
+	var sqlStr = "SAVEPOINT " + name
+	if session.engine.dialect.DBType() == phoenixormcore.MSSQL {
+		sqlStr = "SAVE TRANSACTION " + name
+	}
+	session.saveLastSQL(sqlStr)
+	_, err := session.tx.ExecContext(session.ctx, sqlStr)
+	return name, err
+}
+
+// rollbackToSavepoint undoes the changes after the savepoint
+func (session *Session) rollbackToSavepoint(name string) error {
+	var sqlStr = "ROLLBACK TO SAVEPOINT " + name
+	if session.engine.dialect.DBType() == phoenixormcore.MSSQL {
+		sqlStr = "ROLLBACK TRANSACTION " + name
+	}
+	session.saveLastSQL(sqlStr)
+	_, err := session.tx.ExecContext(session.ctx, sqlStr)
+	return err
+}
+
+// releaseSavepoint releases the savepoint, the changes after it are kept
+func (session *Session) releaseSavepoint(name string) error {
+	switch session.engine.dialect.DBType() {
+	case phoenixormcore.MSSQL, phoenixormcore.ORACLE:
+		// savepoints could not be released, they are gone with the transaction
+		return nil
+	}
+	var sqlStr = "RELEASE SAVEPOINT " + name
+	session.saveLastSQL(sqlStr)
+	_, err := session.tx.ExecContext(session.ctx, sqlStr)
+	return err
+}
+
+// autoTx runs fn in a transaction so that the audit images could be read and the
+// context aware after processors are executed by Commit as in an explicit
+// transaction. The transaction is rolled back if fn returns an error.
+func (session *Session) autoTx(fn func() (int64, error)) (int64, error) {
+	var isAutoClose = session.isAutoClose
+	session.isAutoClose = false
+	defer func() {
+		session.isAutoClose = isAutoClose
+	}()
+
+	if err := session.Begin(); err != nil {
+		return 0, err
+	}
+	session.isAutoTx = true
+	defer func() {
+		session.isAutoTx = false
+	}()
+
+	affected, err := fn()
+	if err != nil {
+		session.Rollback()
+		return affected, err
+	}
+	return affected, session.Commit()
+}
+
+// executeAfterClosures executes the after closures of the beans in the transaction
+// which fill the fields such as created, updated and version. The beans are kept
+// so that the after processors of them could be executed later.
+func (session *Session) executeAfterClosures() {
+	for _, beans := range []map[interface{}]*[]func(interface{}){
+		session.afterInsertBeans,
+		session.afterUpdateBeans,
+		session.afterDeleteBeans,
+	} {
+		for bean, closuresPtr := range beans {
+			if closuresPtr != nil {
+				for _, closure := range *closuresPtr {
+					closure(bean)
+				}
+				beans[bean] = nil
+			}
+		}
+	}
+}
+
+// Commit When using transaction, Commit will commit all operations. When a
+// context aware after processor returns an error, the changes have been
+// committed and an ErrAfterCommit wrapping the error is returned.
 func (session *Session) Commit() error {
 	if !session.isAutoCommit && !session.isCommitedOrRollbacked {
 		session.saveLastSQL("COMMIT")
@@ -38,32 +135,24 @@ func (session *Session) Commit() error {
 		session.isAutoCommit = true
 		var err error
 		if err = session.tx.Commit(); err == nil {
-			// handle processors after tx committed
-			closureCallFunc := func(closuresPtr *[]func(interface{}), bean interface{}) {
-				if closuresPtr != nil {
-					for _, closure := range *closuresPtr {
-						closure(bean)
-					}
-				}
+			// handle processors after tx committed, the context aware processors
+			// should see the beans filled by the closures
+			session.executeAfterClosures()
+			if processorErr := session.executeAfterContextProcessors(); processorErr != nil {
+				err = ErrAfterCommit{Err: processorErr}
 			}
 
-			for bean, closuresPtr := range session.afterInsertBeans {
-				closureCallFunc(closuresPtr, bean)
-
+			for bean := range session.afterInsertBeans {
 				if processor, ok := interface{}(bean).(AfterInsertProcessor); ok {
 					processor.AfterInsert()
 				}
 			}
-			for bean, closuresPtr := range session.afterUpdateBeans {
-				closureCallFunc(closuresPtr, bean)
-
+			for bean := range session.afterUpdateBeans {
 				if processor, ok := interface{}(bean).(AfterUpdateProcessor); ok {
 					processor.AfterUpdate()
 				}
 			}
-			for bean, closuresPtr := range session.afterDeleteBeans {
-				closureCallFunc(closuresPtr, bean)
-
+			for bean := range session.afterDeleteBeans {
 				if processor, ok := interface{}(bean).(AfterDeleteProcessor); ok {
 					processor.AfterDelete()
 				}
@@ -76,7 +165,11 @@ func (session *Session) Commit() error {
 			cleanUpFunc(&session.afterInsertBeans)
 			cleanUpFunc(&session.afterUpdateBeans)
 			cleanUpFunc(&session.afterDeleteBeans)
+
+			session.flushAudit()
 		}
+		// the changes are not committed
+		session.auditRecords = nil
 		return err
 	}
 	return nil
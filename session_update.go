@@ -5,6 +5,7 @@
 package xorm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -151,6 +152,13 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 	if session.statement.lastError != nil {
 		return 0, session.statement.lastError
 	}
+	session.statement.policyOp = PolicyUpdate
+
+	if session.isAutoCommit && (session.auditTable(bean) != nil || hasAfterContextProcessor(bean, tpAfterUpdateContext)) {
+		return session.autoTx(func() (int64, error) {
+			return session.Update(bean, condiBean...)
+		})
+	}
 
 	v := rValue(bean)
 	t := v.Type()
@@ -166,6 +174,11 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 	if processor, ok := interface{}(bean).(BeforeUpdateProcessor); ok {
 		processor.BeforeUpdate()
 	}
+	if processor, ok := interface{}(bean).(BeforeUpdateContextProcessor); ok {
+		if err := processor.BeforeUpdateContext(session.ctx, session); err != nil {
+			return 0, err
+		}
+	}
 	// --
 
 	var err error
@@ -180,9 +193,20 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 			return 0, ErrTableNotFound
 		}
 
+		tenant, err := session.statement.applyScopes(false)
+		if err != nil {
+			return 0, err
+		}
+		if err := session.stampTenant(bean, tenant); err != nil {
+			return 0, err
+		}
+
 		if session.statement.ColumnStr == "" {
-			colNames, args = session.statement.buildUpdates(bean, false, false,
+			colNames, args, err = session.statement.buildUpdates(bean, false, false,
 				false, false, true)
+			if err != nil {
+				return 0, err
+			}
 		} else {
 			colNames, args, err = session.genUpdateColumns(bean)
 			if err != nil {
@@ -190,13 +214,26 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 			}
 		}
 	} else if isMap {
+		if _, err := session.statement.applyScopes(false); err != nil {
+			return 0, err
+		}
 		colNames = make([]string, 0)
 		args = make([]interface{}, 0)
 		bValue := reflect.Indirect(reflect.ValueOf(bean))
 
+		var columns []string
+		var values []interface{}
 		for _, v := range bValue.MapKeys() {
-			colNames = append(colNames, session.engine.Quote(v.String())+" = ?")
-			args = append(args, bValue.MapIndex(v).Interface())
+			columns = append(columns, v.String())
+			values = append(values, bValue.MapIndex(v).Interface())
+		}
+		columns, values, err = session.engine.encryptMapValues(session.statement.mapTable(), columns, values)
+		if err != nil {
+			return 0, err
+		}
+		for i, colName := range columns {
+			colNames = append(colNames, session.engine.Quote(colName)+" = ?")
+			args = append(args, values[i])
 		}
 	} else {
 		return 0, ErrParamsType
@@ -261,6 +298,7 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 	}
 
 	var autoCond phoenixormbuilder.Cond
+	var hasCond = session.statement.cond.IsValid()
 	if !session.statement.noAutoCondition {
 		condBeanIsStruct := false
 		if len(condiBean) > 0 {
@@ -285,9 +323,13 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 			}
 		}
 
+		hasCond = hasCond || (autoCond != nil && autoCond.IsValid())
+
 		if !condBeanIsStruct && table != nil {
-			if col := table.DeletedColumn(); col != nil && !session.statement.unscoped { // tag "deleted" is enabled
-				autoCond1 := session.engine.CondDeleted(session.engine.Quote(col.Name))
+			if col := table.DeletedColumn(); col != nil && session.statement.onlyDeleted {
+				autoCond = session.statement.condOnlyDeleted(col, false).And(autoCond)
+			} else if col != nil && !session.statement.unscoped { // tag "deleted" is enabled
+				autoCond1 := session.engine.condNotDeleted(col, session.engine.Quote(col.Name))
 
 				if autoCond == nil {
 					autoCond = autoCond1
@@ -299,12 +341,17 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 	}
 
 	st := &session.statement
+	if !hasCond && st.LimitN == nil {
+		if err := session.checkFullTable("UPDATE", st.TableName()); err != nil {
+			return 0, err
+		}
+	}
 
 	var (
 		sqlStr   string
 		condArgs []interface{}
 		condSQL  string
-		cond     = session.statement.cond.And(autoCond)
+		cond     = session.statement.condWithScopes().And(autoCond)
 
 		doIncVer = isStruct && (table != nil && table.Version != "" && session.statement.checkVersion)
 		verValue *reflect.Value
@@ -407,15 +454,45 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 		fromSQL,
 		condSQL)
 
+	var audited = session.engine.isAudited(table)
+	var beforeImages []auditImage
+	if audited {
+		var from = session.engine.Quote(tableName)
+		if session.statement.TableAlias != "" {
+			from += " " + session.statement.TableAlias
+		}
+		beforeImages, err = session.auditBefore(table, fmt.Sprintf("SELECT %v* FROM %v %v", top, from, condSQL), condArgs...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// the statement will be reset after executing
+	var idParam = session.statement.idParam
+	var deletedCond phoenixormbuilder.Cond
+	if doIncVer {
+		deletedCond = session.statement.condDeletedState(table)
+	}
 	res, err := session.exec(sqlStr, append(args, condArgs...)...)
 	if err != nil {
 		return 0, err
-	} else if doIncVer {
-		if verValue != nil && verValue.IsValid() && verValue.CanSet() {
+	} else if doIncVer && verValue != nil {
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			cleanupProcessorsClosures(&session.afterClosures)
+			return 0, session.versionConflict(table, tableName, idParam, deletedCond, bean, verValue.Interface())
+		}
+		if verValue.IsValid() && verValue.CanSet() {
 			session.incrVersionFieldValue(verValue)
 		}
 	}
 
+	if audited {
+		if err := session.auditAfter(table, tableName, AuditUpdate, auditPKs(table, beforeImages), beforeImages); err != nil {
+			cleanupProcessorsClosures(&session.afterClosures)
+			return 0, err
+		}
+	}
+
 	if cacher := session.engine.getCacher(tableName); cacher != nil && session.statement.UseCache {
 		// session.cacheUpdate(table, tableName, sqlStr, args...)
 		session.engine.logger.Debug("[cacheUpdate] clear table ", tableName)
@@ -444,10 +521,8 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 				session.afterUpdateBeans[bean] = &afterClosures
 			}
 
-		} else {
-			if _, ok := interface{}(bean).(AfterUpdateProcessor); ok {
-				session.afterUpdateBeans[bean] = nil
-			}
+		} else if isAfterUpdateProcessor(bean) {
+			session.afterUpdateBeans[bean] = nil
 		}
 	}
 	cleanupProcessorsClosures(&session.afterClosures) // cleanup after used
@@ -463,7 +538,7 @@ func (session *Session) genUpdateColumns(bean interface{}) ([]string, []interfac
 
 	for _, col := range table.Columns() {
 		if !col.IsVersion && !col.IsCreated && !col.IsUpdated {
-			if session.statement.omitColumnMap.contain(col.Name) {
+			if session.statement.omitColumnMap.contain(session.engine.columnFilterName(col)) {
 				continue
 			}
 		}
@@ -503,7 +578,7 @@ func (session *Session) genUpdateColumns(bean interface{}) ([]string, []interfac
 		}
 
 		// if only update specify columns
-		if len(session.statement.columnMap) > 0 && !session.statement.columnMap.contain(col.Name) {
+		if len(session.statement.columnMap) > 0 && !session.statement.columnMap.contain(session.engine.columnFilterName(col)) {
 			continue
 		}
 
@@ -547,3 +622,103 @@ func (session *Session) genUpdateColumns(bean interface{}) ([]string, []interfac
 	}
 	return colNames, args, nil
 }
+
+// versionConflict returns the version conflict error of bean, or nil if the row
+// of the primary keys is missing so that nothing is updated or deleted. The
+// deleted state of the row is checked by deletedCond.
+func (session *Session) versionConflict(table *phoenixormcore.Table, tableName string, idParam *phoenixormcore.PK, deletedCond phoenixormbuilder.Cond, bean interface{}, version interface{}) error {
+	var pk phoenixormcore.PK
+	if idParam != nil {
+		pk = *idParam
+	} else {
+		pk, _ = session.engine.idOfV(reflect.ValueOf(bean))
+	}
+
+	// the missing row could not be told from a conflict without the primary keys
+	if len(pk) > 0 && len(pk) == len(table.PrimaryKeys) && !isPKZero(pk) {
+		var cond = deletedCond
+		for i, name := range table.PrimaryKeys {
+			cond = cond.And(phoenixormbuilder.Eq{session.engine.Quote(name): pk[i]})
+		}
+		condSQL, condArgs, err := phoenixormbuilder.ToSQL(cond)
+		if err != nil {
+			return err
+		}
+
+		var total int64
+		sqlStr := fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", session.engine.Quote(tableName), condSQL)
+		if err := session.queryRow(sqlStr, condArgs...).Scan(&total); err != nil {
+			return err
+		}
+		if total == 0 {
+			return nil
+		}
+	}
+
+	return ErrVersionConflict{
+		TableName: tableName,
+		PK:        pk,
+		Version:   version,
+	}
+}
+
+// UpdateWithRetry applies mutate to bean and updates it. When the version check
+// fails, the row will be reloaded by the primary key into bean and mutate will be
+// applied again, at most maxAttempts times. The conditions of the session are
+// applied to every attempt. ErrNeedPrimaryKey will be returned if neither ID nor
+// bean gives the primary keys to reload the row.
+func (session *Session) UpdateWithRetry(bean interface{}, mutate func(bean interface{}) error, maxAttempts int) (int64, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+
+	// the row of the conditions only could not be reloaded when the version conflicts
+	var pk phoenixormcore.PK
+	if session.statement.idParam != nil {
+		pk = *session.statement.idParam
+	} else {
+		var err error
+		if pk, err = session.engine.idOfV(reflect.ValueOf(bean)); err != nil {
+			return 0, err
+		}
+	}
+	if len(pk) == 0 || isPKZero(pk) {
+		return 0, ErrNeedPrimaryKey
+	}
+
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	// the session should not be closed or reset between attempts
+	var isAutoClose = session.isAutoClose
+	var statement = session.statement
+	session.isAutoClose = false
+	defer func() {
+		session.isAutoClose = isAutoClose
+	}()
+
+	for attempt := 1; ; attempt++ {
+		if err := mutate(bean); err != nil {
+			return 0, err
+		}
+
+		session.statement = statement
+		affected, err := session.Update(bean)
+		conflict, ok := err.(ErrVersionConflict)
+		if !ok || attempt >= maxAttempts {
+			return affected, err
+		}
+
+		// reload the row from master without cache
+		var ctx = session.ctx
+		session.ctx = context.WithValue(ctx, groupRouteKey{}, groupRouteMaster)
+		has, err := session.NoCache().NoAutoCondition().ID(conflict.PK).Get(bean)
+		session.ctx = ctx
+		if err != nil {
+			return 0, err
+		} else if !has {
+			return 0, ErrNotExist
+		}
+	}
+}
@@ -1446,3 +1446,84 @@ func TestUpdateMap3(t *testing.T) {
 	assert.Error(t, err)
 	assert.EqualValues(t, 0, rows)
 }
+
+func TestUpdateChanged(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type UpdateChangedStruct struct {
+		Snapshot
+		Id        int64
+		Name      string
+		Age       int
+		IsMan     bool
+		Ver       int       `xorm:"version"`
+		UpdatedAt time.Time `xorm:"updated"`
+	}
+
+	assertSync(t, new(UpdateChangedStruct))
+
+	cols, err := testEngine.DBMetas()
+	assert.NoError(t, err)
+	for _, table := range cols {
+		if table.Name == testEngine.TableName(new(UpdateChangedStruct)) {
+			assert.Nil(t, table.GetColumn("snapshot"))
+		}
+	}
+
+	var bean = UpdateChangedStruct{Name: "lunny", Age: 18, IsMan: true}
+	_, err = testEngine.Insert(&bean)
+	assert.NoError(t, err)
+	assert.True(t, bean.HasSnapshot())
+
+	var loaded UpdateChangedStruct
+	has, err := testEngine.ID(bean.Id).NoCache().Get(&loaded)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.True(t, loaded.HasSnapshot())
+
+	// nothing changed
+	affected, err := testEngine.UpdateChanged(&loaded)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, affected)
+
+	// zero values are updated too
+	loaded.Age = 0
+	loaded.IsMan = false
+	affected, err = testEngine.UpdateChanged(&loaded)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	assert.EqualValues(t, 2, loaded.Ver)
+
+	var updated UpdateChangedStruct
+	has, err = testEngine.ID(bean.Id).NoCache().Get(&updated)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "lunny", updated.Name)
+	assert.EqualValues(t, 0, updated.Age)
+	assert.False(t, updated.IsMan)
+	assert.EqualValues(t, 2, updated.Ver)
+	assert.True(t, updated.UpdatedAt.Unix() >= bean.UpdatedAt.Unix())
+
+	// the snapshot is refreshed after updated
+	affected, err = testEngine.UpdateChanged(&loaded)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, affected)
+
+	// the stale bean will fail the version check
+	bean.Name = "xlw"
+	_, err = testEngine.UpdateChanged(&bean)
+	_, ok := err.(ErrVersionConflict)
+	assert.True(t, ok)
+
+	var beans []UpdateChangedStruct
+	assert.NoError(t, testEngine.Where("id = ?", bean.Id).NoCache().Find(&beans))
+	if assert.EqualValues(t, 1, len(beans)) {
+		beans[0].Name = "xlw"
+		affected, err = testEngine.UpdateChanged(&beans[0])
+		assert.NoError(t, err)
+		assert.EqualValues(t, 1, affected)
+	}
+
+	_, err = testEngine.UpdateChanged(&UpdateChangedStruct{Id: bean.Id})
+	assert.EqualValues(t, ErrNoSnapshot, err)
+}
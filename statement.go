@@ -19,14 +19,20 @@ import (
 type Statement struct {
 	RefTable        *phoenixormcore.Table
 	Engine          *Engine
+	session         *Session
 	Start           int
 	LimitN          *int
 	idParam         *phoenixormcore.PK
 	OrderStr        string
 	JoinStr         string
 	joinArgs        []interface{}
+	fromSQL         string
+	fromArgs        []interface{}
+	ctes            []commonTable
+	unions          []unionQuery
 	GroupByStr      string
 	HavingStr       string
+	havingArgs      []interface{}
 	ColumnStr       string
 	selectStr       string
 	useAllCols      bool
@@ -47,7 +53,13 @@ type Statement struct {
 	TableAlias      string
 	allUseBool      bool
 	checkVersion    bool
+	allowFullTable  bool
+	noLimitCheck    bool
+	onlyDeleted     bool
 	unscoped        bool
+	allTenants      bool
+	scopesApplied   bool
+	withoutScopes   map[string]bool
 	columnMap       columnMap
 	omitColumnMap   columnMap
 	mustColumnMap   map[string]bool
@@ -56,6 +68,10 @@ type Statement struct {
 	decrColumns     exprParams
 	exprColumns     exprParams
 	cond            phoenixormbuilder.Cond
+	scopeCond       phoenixormbuilder.Cond
+	policyOp        PolicyOp
+	policyCond      phoenixormbuilder.Cond
+	tenantCond      phoenixormbuilder.Cond
 	bufferSize      int
 	context         ContextCache
 	lastError       error
@@ -70,8 +86,13 @@ func (statement *Statement) Init() {
 	statement.UseCascade = true
 	statement.JoinStr = ""
 	statement.joinArgs = make([]interface{}, 0)
+	statement.fromSQL = ""
+	statement.fromArgs = nil
+	statement.ctes = nil
+	statement.unions = nil
 	statement.GroupByStr = ""
 	statement.HavingStr = ""
+	statement.havingArgs = nil
 	statement.ColumnStr = ""
 	statement.OmitStr = ""
 	statement.columnMap = columnMap{}
@@ -93,11 +114,21 @@ func (statement *Statement) Init() {
 	statement.mustColumnMap = make(map[string]bool)
 	statement.nullableMap = make(map[string]bool)
 	statement.checkVersion = true
+	statement.allowFullTable = false
+	statement.noLimitCheck = false
+	statement.onlyDeleted = false
 	statement.unscoped = false
+	statement.allTenants = false
+	statement.scopesApplied = false
+	statement.withoutScopes = nil
 	statement.incrColumns = exprParams{}
 	statement.decrColumns = exprParams{}
 	statement.exprColumns = exprParams{}
 	statement.cond = phoenixormbuilder.NewCond()
+	statement.scopeCond = phoenixormbuilder.NewCond()
+	statement.policyOp = PolicyRead
+	statement.policyCond = phoenixormbuilder.NewCond()
+	statement.tenantCond = phoenixormbuilder.NewCond()
 	statement.bufferSize = 0
 	statement.context = nil
 	statement.lastError = nil
@@ -193,15 +224,25 @@ func (statement *Statement) Or(query interface{}, args ...interface{}) *Statemen
 	return statement
 }
 
-// In generate "Where column IN (?) " statement
+// In generate "Where column IN (?) " statement, the only arg could be a
+// subquery of a *phoenixormbuilder.Builder or a *Session
 func (statement *Statement) In(column string, args ...interface{}) *Statement {
+	if in, ok := statement.inSubquery(column, false, args); ok {
+		statement.cond = statement.cond.And(in)
+		return statement
+	}
 	in := phoenixormbuilder.In(statement.Engine.Quote(column), args...)
 	statement.cond = statement.cond.And(in)
 	return statement
 }
 
-// NotIn generate "Where column NOT IN (?) " statement
+// NotIn generate "Where column NOT IN (?) " statement, the only arg could be a
+// subquery of a *phoenixormbuilder.Builder or a *Session
 func (statement *Statement) NotIn(column string, args ...interface{}) *Statement {
+	if notIn, ok := statement.inSubquery(column, true, args); ok {
+		statement.cond = statement.cond.And(notIn)
+		return statement
+	}
 	notIn := phoenixormbuilder.NotIn(statement.Engine.Quote(column), args...)
 	statement.cond = statement.cond.And(notIn)
 	return statement
@@ -230,7 +271,7 @@ func (statement *Statement) setRefBean(bean interface{}) error {
 // Auto generating update columnes and values according a struct
 func (statement *Statement) buildUpdates(bean interface{},
 	includeVersion, includeUpdated, includeNil,
-	includeAutoIncr, update bool) ([]string, []interface{}) {
+	includeAutoIncr, update bool) ([]string, []interface{}, error) {
 	engine := statement.Engine
 	table := statement.RefTable
 	allUseBool := statement.allUseBool
@@ -259,10 +300,10 @@ func (statement *Statement) buildUpdates(bean interface{},
 		if col.IsDeleted && !unscoped {
 			continue
 		}
-		if omitColumnMap.contain(col.Name) {
+		if omitColumnMap.contain(engine.columnFilterName(col)) {
 			continue
 		}
-		if len(columnMap) > 0 && !columnMap.contain(col.Name) {
+		if len(columnMap) > 0 && !columnMap.contain(engine.columnFilterName(col)) {
 			continue
 		}
 
@@ -398,8 +439,7 @@ func (statement *Statement) buildUpdates(bean interface{},
 				val, _ = nulType.Value()
 			} else {
 				if !col.SQLType.IsJson() {
-					engine.autoMapType(fieldValue)
-					if table, ok := engine.Tables[fieldValue.Type()]; ok {
+					if table, err := engine.autoMapType(fieldValue); err == nil {
 						if len(table.PrimaryKeys) == 1 {
 							pkField := reflect.Indirect(fieldValue).FieldByName(table.PKColumns()[0].FieldName)
 							// fix non-int pk issues
@@ -482,6 +522,10 @@ func (statement *Statement) buildUpdates(bean interface{},
 		}
 
 	APPEND:
+		val, err = engine.encryptColumnValue(col, val)
+		if err != nil {
+			return nil, nil, err
+		}
 		args = append(args, val)
 		if col.IsPrimaryKey && engine.dialect.DBType() == "ql" {
 			continue
@@ -489,7 +533,7 @@ func (statement *Statement) buildUpdates(bean interface{},
 		colNames = append(colNames, fmt.Sprintf("%v = ?", engine.Quote(col.Name)))
 	}
 
-	return colNames, args
+	return colNames, args, nil
 }
 
 func (statement *Statement) needTableName() bool {
@@ -516,6 +560,25 @@ func (statement *Statement) TableName() string {
 	return statement.tableName
 }
 
+// quotedTableName returns the quoted name of the table, the MSSQL names with
+// the database and the schema are kept as they are
+func (statement *Statement) quotedTableName() string {
+	var tableName = statement.TableName()
+	if statement.Engine.Dialect().DBType() == phoenixormcore.MSSQL && strings.Contains(tableName, "..") {
+		return tableName
+	}
+	return statement.Engine.Quote(tableName)
+}
+
+// mapTable returns the table of the map beans, it's the RefTable or the mapped
+// table of the name set by Table
+func (statement *Statement) mapTable() *phoenixormcore.Table {
+	if statement.RefTable != nil {
+		return statement.RefTable
+	}
+	return statement.Engine.tableByName(statement.TableName())
+}
+
 // ID generate "where id = ? " statement or for composite key "where key1 = ? and key2 = ?"
 func (statement *Statement) ID(id interface{}) *Statement {
 	idValue := reflect.ValueOf(id)
@@ -711,8 +774,14 @@ func (statement *Statement) Asc(colNames ...string) *Statement {
 	return statement
 }
 
-// Table tempororily set table name, the parameter could be a string or a pointer of struct
+// Table tempororily set table name, the parameter could be a string or a pointer of struct,
+// or a derived table of a *phoenixormbuilder.Builder or a *Session which could be
+// aliased as []interface{}{subquery, alias}
 func (statement *Statement) Table(tableNameOrBean interface{}) *Statement {
+	if subquery, alias, ok := splitSubquery(tableNameOrBean); ok {
+		return statement.tableSubquery(subquery, alias)
+	}
+
 	v := rValue(tableNameOrBean)
 	t := v.Type()
 	if t.Kind() == reflect.Struct {
@@ -728,7 +797,8 @@ func (statement *Statement) Table(tableNameOrBean interface{}) *Statement {
 	return statement
 }
 
-// Join The joinOP should be one of INNER, LEFT OUTER, CROSS etc - this will be prepended to JOIN
+// Join The joinOP should be one of INNER, LEFT OUTER, CROSS etc - this will be prepended to JOIN,
+// the tablename could be a subquery of a *phoenixormbuilder.Builder or a *Session
 func (statement *Statement) Join(joinOP string, tablename interface{}, condition string, args ...interface{}) *Statement {
 	var buf strings.Builder
 	if len(statement.JoinStr) > 0 {
@@ -737,32 +807,18 @@ func (statement *Statement) Join(joinOP string, tablename interface{}, condition
 		fmt.Fprintf(&buf, "%v JOIN ", joinOP)
 	}
 
-	switch tp := tablename.(type) {
-	case phoenixormbuilder.Builder:
-		subSQL, subQueryArgs, err := tp.ToSQL()
+	if subquery, aliasName, ok := splitSubquery(tablename); ok {
+		subSQL, subQueryArgs, err := statement.subquerySQL(subquery)
 		if err != nil {
 			statement.lastError = err
 			return statement
 		}
-		tbs := strings.Split(tp.TableName(), ".")
-		quotes := append(strings.Split(statement.Engine.Quote(""), ""), "`")
-
-		var aliasName = strings.Trim(tbs[len(tbs)-1], strings.Join(quotes, ""))
-		fmt.Fprintf(&buf, "(%s) %s ON %v", subSQL, aliasName, condition)
-		statement.joinArgs = append(statement.joinArgs, subQueryArgs...)
-	case *phoenixormbuilder.Builder:
-		subSQL, subQueryArgs, err := tp.ToSQL()
-		if err != nil {
-			statement.lastError = err
-			return statement
+		if aliasName == "" {
+			aliasName = statement.subqueryAlias(subquery)
 		}
-		tbs := strings.Split(tp.TableName(), ".")
-		quotes := append(strings.Split(statement.Engine.Quote(""), ""), "`")
-
-		var aliasName = strings.Trim(tbs[len(tbs)-1], strings.Join(quotes, ""))
 		fmt.Fprintf(&buf, "(%s) %s ON %v", subSQL, aliasName, condition)
 		statement.joinArgs = append(statement.joinArgs, subQueryArgs...)
-	default:
+	} else {
 		tbName := statement.Engine.TableName(tablename, true)
 		fmt.Fprintf(&buf, "%s ON %v", tbName, condition)
 	}
@@ -779,8 +835,16 @@ func (statement *Statement) GroupBy(keys string) *Statement {
 }
 
 // Having generate "Having conditions" statement
-func (statement *Statement) Having(conditions string) *Statement {
+func (statement *Statement) Having(conditions string, args ...interface{}) *Statement {
 	statement.HavingStr = fmt.Sprintf("HAVING %v", conditions)
+	statement.havingArgs = args
+	return statement
+}
+
+// OnlyDeleted only queries the soft deleted records
+func (statement *Statement) OnlyDeleted() *Statement {
+	statement.unscoped = true
+	statement.onlyDeleted = true
 	return statement
 }
 
@@ -901,8 +965,61 @@ func (statement *Statement) genAddColumnStr(col *phoenixormcore.Column) (string,
 }
 
 func (statement *Statement) buildConds(table *phoenixormcore.Table, bean interface{}, includeVersion bool, includeUpdated bool, includeNil bool, includeAutoIncr bool, addedTableName bool) (phoenixormbuilder.Cond, error) {
-	return statement.Engine.buildConds(table, bean, includeVersion, includeUpdated, includeNil, includeAutoIncr, statement.allUseBool, statement.useAllCols,
+	cond, err := statement.Engine.buildConds(table, bean, includeVersion, includeUpdated, includeNil, includeAutoIncr, statement.allUseBool, statement.useAllCols,
 		statement.unscoped, statement.mustColumnMap, statement.TableName(), statement.TableAlias, addedTableName)
+	if err != nil {
+		return nil, err
+	}
+	if statement.onlyDeleted && table.DeletedColumn() != nil {
+		cond = cond.And(statement.condOnlyDeleted(table.DeletedColumn(), addedTableName))
+	}
+	return cond, nil
+}
+
+// condOnlyDeleted returns the condition of the soft deleted records
+func (statement *Statement) condOnlyDeleted(col *phoenixormcore.Column, addedTableName bool) phoenixormbuilder.Cond {
+	var colName = statement.Engine.Quote(col.Name)
+	if addedTableName {
+		var nm = statement.TableName()
+		if len(statement.TableAlias) > 0 {
+			nm = statement.TableAlias
+		}
+		colName = statement.Engine.Quote(nm) + "." + colName
+	}
+	return statement.Engine.condOnlyDeleted(col, colName)
+}
+
+// condDeletedState returns the condition of the deleted column of the records
+// operated by the statement
+func (statement *Statement) condDeletedState(table *phoenixormcore.Table) phoenixormbuilder.Cond {
+	col := table.DeletedColumn()
+	switch {
+	case col == nil || statement.noAutoCondition:
+		return phoenixormbuilder.NewCond()
+	case statement.onlyDeleted:
+		return statement.condOnlyDeleted(col, false)
+	case statement.unscoped:
+		return phoenixormbuilder.NewCond()
+	}
+	return statement.Engine.condNotDeleted(col, statement.Engine.Quote(col.Name))
+}
+
+// hasConds returns true if the caller gives any condition of the records by
+// Where, ID or bean, the conditions of the deleted column and the scopes are
+// not counted. It should be called before the conditions are merged.
+func (statement *Statement) hasConds(bean interface{}) (bool, error) {
+	if statement.cond.IsValid() || statement.idParam != nil {
+		return true, nil
+	}
+	if statement.noAutoCondition || bean == nil || statement.RefTable == nil {
+		return false, nil
+	}
+	cond, err := statement.Engine.buildConds(statement.RefTable, bean, true, true, false, true, statement.allUseBool, statement.useAllCols,
+		true, statement.mustColumnMap, statement.TableName(), statement.TableAlias, false)
+	if err != nil {
+		return false, err
+	}
+	return cond.IsValid(), nil
 }
 
 func (statement *Statement) mergeConds(bean interface{}) error {
@@ -918,7 +1035,8 @@ func (statement *Statement) mergeConds(bean interface{}) error {
 	if err := statement.processIDParam(); err != nil {
 		return err
 	}
-	return nil
+	_, err := statement.applyScopes(len(statement.JoinStr) > 0)
+	return err
 }
 
 func (statement *Statement) genConds(bean interface{}) (string, []interface{}, error) {
@@ -926,7 +1044,7 @@ func (statement *Statement) genConds(bean interface{}) (string, []interface{}, e
 		return "", nil, err
 	}
 
-	return phoenixormbuilder.ToSQL(statement.cond)
+	return phoenixormbuilder.ToSQL(statement.condWithScopes())
 }
 
 func (statement *Statement) genGetSQL(bean interface{}) (string, []interface{}, error) {
@@ -970,18 +1088,16 @@ func (statement *Statement) genGetSQL(bean interface{}) (string, []interface{},
 		if err := statement.processIDParam(); err != nil {
 			return "", nil, err
 		}
+		if _, err := statement.applyScopes(len(statement.JoinStr) > 0); err != nil {
+			return "", nil, err
+		}
 	}
-	condSQL, condArgs, err := phoenixormbuilder.ToSQL(statement.cond)
-	if err != nil {
-		return "", nil, err
-	}
-
-	sqlStr, err := statement.genSelectSQL(columnStr, condSQL, true, true)
+	condSQL, condArgs, err := phoenixormbuilder.ToSQL(statement.condWithScopes())
 	if err != nil {
 		return "", nil, err
 	}
 
-	return sqlStr, append(statement.joinArgs, condArgs...), nil
+	return statement.genSelectSQL(columnStr, condSQL, condArgs, true, true)
 }
 
 func (statement *Statement) genCountSQL(beans ...interface{}) (string, []interface{}, error) {
@@ -992,7 +1108,10 @@ func (statement *Statement) genCountSQL(beans ...interface{}) (string, []interfa
 		statement.setRefBean(beans[0])
 		condSQL, condArgs, err = statement.genConds(beans[0])
 	} else {
-		condSQL, condArgs, err = phoenixormbuilder.ToSQL(statement.cond)
+		if _, err := statement.applyScopes(len(statement.JoinStr) > 0); err != nil {
+			return "", nil, err
+		}
+		condSQL, condArgs, err = phoenixormbuilder.ToSQL(statement.condWithScopes())
 	}
 	if err != nil {
 		return "", nil, err
@@ -1006,12 +1125,7 @@ func (statement *Statement) genCountSQL(beans ...interface{}) (string, []interfa
 			selectSQL = "count(*)"
 		}
 	}
-	sqlStr, err := statement.genSelectSQL(selectSQL, condSQL, false, false)
-	if err != nil {
-		return "", nil, err
-	}
-
-	return sqlStr, append(statement.joinArgs, condArgs...), nil
+	return statement.genSelectSQL(selectSQL, condSQL, condArgs, false, false)
 }
 
 func (statement *Statement) genSumSQL(bean interface{}, columns ...string) (string, []interface{}, error) {
@@ -1031,22 +1145,36 @@ func (statement *Statement) genSumSQL(bean interface{}, columns ...string) (stri
 		return "", nil, err
 	}
 
-	sqlStr, err := statement.genSelectSQL(sumSelect, condSQL, true, true)
-	if err != nil {
-		return "", nil, err
-	}
-
-	return sqlStr, append(statement.joinArgs, condArgs...), nil
+	return statement.genSelectSQL(sumSelect, condSQL, condArgs, true, true)
 }
 
-func (statement *Statement) genSelectSQL(columnStr, condSQL string, needLimit, needOrderBy bool) (string, error) {
+// genSelectSQL generates the select SQL and returns it with the args of the
+// common table expressions, the derived table, the joins, the conditions and
+// the unions in the order of their placeholders
+func (statement *Statement) genSelectSQL(columnStr, condSQL string, condArgs []interface{}, needLimit, needOrderBy bool) (string, []interface{}, error) {
+	if statement.lastError != nil {
+		return "", nil, statement.lastError
+	}
+
 	var (
 		distinct                  string
 		dialect                   = statement.Engine.Dialect()
 		quote                     = statement.Engine.Quote
 		fromStr                   = " FROM "
 		top, mssqlCondi, whereStr string
+		mssqlOffset               bool
+		args                      []interface{}
 	)
+
+	withSQL, withArgs := statement.genWithSQL()
+	if len(statement.unions) > 0 {
+		sqlStr, unionArgs, err := statement.genUnionSQL(columnStr, condSQL, condArgs, needLimit, needOrderBy)
+		if err != nil {
+			return "", nil, err
+		}
+		return withSQL + sqlStr, append(withArgs, unionArgs...), nil
+	}
+
 	if statement.IsDistinct && !strings.HasPrefix(columnStr, "count") {
 		distinct = "DISTINCT "
 	}
@@ -1054,10 +1182,10 @@ func (statement *Statement) genSelectSQL(columnStr, condSQL string, needLimit, n
 		whereStr = " WHERE " + condSQL
 	}
 
-	if dialect.DBType() == phoenixormcore.MSSQL && strings.Contains(statement.TableName(), "..") {
-		fromStr += statement.TableName()
+	if statement.fromSQL != "" {
+		fromStr += "(" + statement.fromSQL + ")"
 	} else {
-		fromStr += quote(statement.TableName())
+		fromStr += statement.quotedTableName()
 	}
 
 	if statement.TableAlias != "" {
@@ -1070,14 +1198,23 @@ func (statement *Statement) genSelectSQL(columnStr, condSQL string, needLimit, n
 	if statement.JoinStr != "" {
 		fromStr = fmt.Sprintf("%v %v", fromStr, statement.JoinStr)
 	}
+	args = append(args, statement.fromArgs...)
+	args = append(args, statement.joinArgs...)
+	args = append(args, condArgs...)
 
 	pLimitN := statement.LimitN
 	if dialect.DBType() == phoenixormcore.MSSQL {
-		if pLimitN != nil {
+		// the grouped records have no key to be skipped by NOT IN, they are
+		// paged by OFFSET FETCH which is supported since SQL Server 2012
+		mssqlOffset = needLimit && statement.Start > 0 && statement.GroupByStr != ""
+		if pLimitN != nil && !mssqlOffset {
 			LimitNValue := *pLimitN
 			top = fmt.Sprintf("TOP %d ", LimitNValue)
 		}
-		if statement.Start > 0 {
+		if statement.Start > 0 && !mssqlOffset {
+			if statement.RefTable == nil {
+				return "", nil, ErrOffsetWithoutTable
+			}
 			var column string
 			if len(statement.RefTable.PKColumns()) == 0 {
 				for _, index := range statement.RefTable.Indexes {
@@ -1111,9 +1248,13 @@ func (statement *Statement) genSelectSQL(columnStr, condSQL string, needLimit, n
 			}
 			mssqlCondi = fmt.Sprintf("(%s NOT IN (SELECT TOP %d %s%s%s%s%s))",
 				column, statement.Start, column, fromStr, whereStr, orderStr, groupStr)
+			// the placeholders of the subquery are after the ones of the query
+			args = append(args, args...)
 		}
 	}
 
+	args = append(args, statement.havingArgs...)
+
 	var buf strings.Builder
 	fmt.Fprintf(&buf, "SELECT %v%v%v%v%v", distinct, top, columnStr, fromStr, whereStr)
 	if len(mssqlCondi) > 0 {
@@ -1132,6 +1273,14 @@ func (statement *Statement) genSelectSQL(columnStr, condSQL string, needLimit, n
 	}
 	if needOrderBy && statement.OrderStr != "" {
 		fmt.Fprint(&buf, " ORDER BY ", statement.OrderStr)
+	} else if mssqlOffset {
+		fmt.Fprint(&buf, " ORDER BY (SELECT NULL)")
+	}
+	if mssqlOffset {
+		fmt.Fprintf(&buf, " OFFSET %d ROWS", statement.Start)
+		if pLimitN != nil {
+			fmt.Fprintf(&buf, " FETCH NEXT %d ROWS ONLY", *pLimitN)
+		}
 	}
 	if needLimit {
 		if dialect.DBType() != phoenixormcore.MSSQL && dialect.DBType() != phoenixormcore.ORACLE {
@@ -1157,11 +1306,12 @@ func (statement *Statement) genSelectSQL(columnStr, condSQL string, needLimit, n
 			}
 		}
 	}
+	var sqlStr = buf.String()
 	if statement.IsForUpdate {
-		return dialect.ForUpdateSql(buf.String()), nil
+		sqlStr = dialect.ForUpdateSql(sqlStr)
 	}
 
-	return buf.String(), nil
+	return withSQL + sqlStr, append(withArgs, args...), nil
 }
 
 func (statement *Statement) processIDParam() error {
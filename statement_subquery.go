@@ -0,0 +1,272 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// commonTable is a common table expression of the statement
+type commonTable struct {
+	name      string
+	sql       string
+	args      []interface{}
+	recursive bool
+}
+
+// unionQuery is a query combined with the statement by UNION or UNION ALL
+type unionQuery struct {
+	op   string
+	sql  string
+	args []interface{}
+}
+
+// isSubquery returns true if v could be used as a subquery
+func isSubquery(v interface{}) bool {
+	switch v.(type) {
+	case *phoenixormbuilder.Builder, phoenixormbuilder.Builder, *Session:
+		return true
+	}
+	return false
+}
+
+// subquerySQL returns the SQL and the args of a subquery which could be a
+// *phoenixormbuilder.Builder or a *Session, the placeholders of the SQL are
+// always ? and will be converted by the dialect when the query is executed
+func (statement *Statement) subquerySQL(subquery interface{}) (string, []interface{}, error) {
+	switch q := subquery.(type) {
+	case phoenixormbuilder.Builder:
+		return builderSQL(&q)
+	case *phoenixormbuilder.Builder:
+		return builderSQL(q)
+	case *Session:
+		return q.genQuerySQL()
+	}
+	return "", nil, fmt.Errorf("unsupported subquery type %T", subquery)
+}
+
+// builderSQL writes the builder with ? placeholders
+func builderSQL(b *phoenixormbuilder.Builder) (string, []interface{}, error) {
+	w := phoenixormbuilder.NewWriter()
+	if err := b.WriteTo(w); err != nil {
+		return "", nil, err
+	}
+	args := w.Args()
+	for i, arg := range args {
+		if namedArg, ok := arg.(sql.NamedArg); ok {
+			args[i] = namedArg.Value
+		}
+	}
+	return w.String(), args, nil
+}
+
+// subqueryAlias returns the default alias of a derived table
+func (statement *Statement) subqueryAlias(subquery interface{}) string {
+	var name string
+	switch q := subquery.(type) {
+	case phoenixormbuilder.Builder:
+		name = q.TableName()
+	case *phoenixormbuilder.Builder:
+		name = q.TableName()
+	case *Session:
+		name = q.statement.TableName()
+	}
+	tbs := strings.Split(name, ".")
+	quotes := append(strings.Split(statement.Engine.Quote(""), ""), "`")
+	return strings.Trim(tbs[len(tbs)-1], strings.Join(quotes, ""))
+}
+
+// splitSubquery returns the subquery and the alias of a derived table which
+// could be given as the subquery or as []interface{}{subquery, alias}
+func splitSubquery(v interface{}) (interface{}, string, bool) {
+	if isSubquery(v) {
+		return v, "", true
+	}
+	if vs, ok := v.([]interface{}); ok && len(vs) == 2 && isSubquery(vs[0]) {
+		alias, ok := vs[1].(string)
+		return vs[0], alias, ok
+	}
+	return nil, "", false
+}
+
+// tableSubquery sets the derived table of the statement
+func (statement *Statement) tableSubquery(subquery interface{}, alias string) *Statement {
+	subSQL, subArgs, err := statement.subquerySQL(subquery)
+	if err != nil {
+		statement.lastError = err
+		return statement
+	}
+	if alias == "" {
+		alias = statement.TableAlias
+	}
+	if alias == "" {
+		alias = statement.subqueryAlias(subquery)
+	}
+	statement.fromSQL = subSQL
+	statement.fromArgs = subArgs
+	statement.AltTableName = alias
+	statement.TableAlias = alias
+	return statement
+}
+
+// With adds a common table expression of the name to the statement, the name
+// could contain the columns, e.g. "tree(id, parent_id)"
+func (statement *Statement) With(name string, subquery interface{}) *Statement {
+	return statement.with(name, subquery, false)
+}
+
+// WithRecursive adds a recursive common table expression of the name to the
+// statement, the subquery usually is a union of the anchor and the recursive
+// queries
+func (statement *Statement) WithRecursive(name string, subquery interface{}) *Statement {
+	return statement.with(name, subquery, true)
+}
+
+func (statement *Statement) with(name string, subquery interface{}, recursive bool) *Statement {
+	subSQL, subArgs, err := statement.subquerySQL(subquery)
+	if err != nil {
+		statement.lastError = err
+		return statement
+	}
+	statement.ctes = append(statement.ctes, commonTable{
+		name:      name,
+		sql:       subSQL,
+		args:      subArgs,
+		recursive: recursive,
+	})
+	return statement
+}
+
+// Union combines the results of the subquery with the statement and removes
+// the duplicated records
+func (statement *Statement) Union(subquery interface{}) *Statement {
+	return statement.union("UNION", subquery)
+}
+
+// UnionAll combines the results of the subquery with the statement
+func (statement *Statement) UnionAll(subquery interface{}) *Statement {
+	return statement.union("UNION ALL", subquery)
+}
+
+func (statement *Statement) union(op string, subquery interface{}) *Statement {
+	subSQL, subArgs, err := statement.subquerySQL(subquery)
+	if err != nil {
+		statement.lastError = err
+		return statement
+	}
+	statement.unions = append(statement.unions, unionQuery{op: op, sql: subSQL, args: subArgs})
+	return statement
+}
+
+// inSubquery generates "column [NOT] IN (subquery)" if the only arg is a
+// *Session, the builders are supported by phoenixormbuilder.In directly
+func (statement *Statement) inSubquery(column string, not bool, args []interface{}) (phoenixormbuilder.Cond, bool) {
+	if len(args) != 1 {
+		return nil, false
+	}
+	sub, ok := args[0].(*Session)
+	if !ok {
+		return nil, false
+	}
+	subSQL, subArgs, err := statement.subquerySQL(sub)
+	if err != nil {
+		statement.lastError = err
+		return phoenixormbuilder.NewCond(), true
+	}
+	var op = " IN "
+	if not {
+		op = " NOT IN "
+	}
+	return phoenixormbuilder.Expr(statement.Engine.Quote(column)+op+"("+subSQL+")", subArgs...), true
+}
+
+// isComposed returns true if the statement selects from a derived table, or
+// has common table expressions or unions, whose results could not be cached
+func (statement *Statement) isComposed() bool {
+	return statement.fromSQL != "" || len(statement.ctes) > 0 || len(statement.unions) > 0
+}
+
+// genWithSQL returns the WITH clause of the common table expressions
+func (statement *Statement) genWithSQL() (string, []interface{}) {
+	if len(statement.ctes) == 0 {
+		return "", nil
+	}
+
+	var (
+		buf       strings.Builder
+		args      []interface{}
+		recursive bool
+		quote     = statement.Engine.Quote
+	)
+	for i, cte := range statement.ctes {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		// the columns of the name are not quoted
+		if idx := strings.Index(cte.name, "("); idx > 0 {
+			buf.WriteString(quote(strings.TrimSpace(cte.name[:idx])))
+			buf.WriteString(cte.name[idx:])
+		} else {
+			buf.WriteString(quote(cte.name))
+		}
+		fmt.Fprintf(&buf, " AS (%s)", cte.sql)
+		args = append(args, cte.args...)
+		recursive = recursive || cte.recursive
+	}
+
+	var with = "WITH "
+	// MSSQL and Oracle detect the recursive queries themselves
+	switch statement.Engine.Dialect().DBType() {
+	case phoenixormcore.MSSQL, phoenixormcore.ORACLE:
+	default:
+		if recursive {
+			with = "WITH RECURSIVE "
+		}
+	}
+	return with + buf.String() + " ", args
+}
+
+// genUnionSQL combines the statement with the unions, the union is wrapped as
+// a derived table if it should be ordered or paged
+func (statement *Statement) genUnionSQL(columnStr, condSQL string, condArgs []interface{}, needLimit, needOrderBy bool) (string, []interface{}, error) {
+	var first = *statement
+	first.ctes = nil
+	first.unions = nil
+	first.OrderStr = ""
+	first.LimitN = nil
+	first.Start = 0
+	first.IsForUpdate = false
+	sqlStr, args, err := first.genSelectSQL(columnStr, condSQL, condArgs, false, false)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, u := range statement.unions {
+		sqlStr += " " + u.op + " " + u.sql
+		args = append(args, u.args...)
+	}
+
+	var ordered = needOrderBy && statement.OrderStr != ""
+	var paged = needLimit && (statement.LimitN != nil || statement.Start > 0)
+	if !ordered && !paged {
+		return sqlStr, args, nil
+	}
+
+	var outer = Statement{
+		Engine:       statement.Engine,
+		fromSQL:      sqlStr,
+		fromArgs:     args,
+		AltTableName: "u",
+		TableAlias:   "u",
+		OrderStr:     statement.OrderStr,
+		LimitN:       statement.LimitN,
+		Start:        statement.Start,
+	}
+	return outer.genSelectSQL("*", "", nil, needLimit, needOrderBy)
+}
@@ -14,7 +14,9 @@ import (
 	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
 )
 
-type tagContext struct {
+// TagContext is the context of a tag handler, it's created for every field
+// when mapping a struct
+type TagContext struct {
 	tagName         string
 	params          []string
 	preTag, nextTag string
@@ -30,31 +32,104 @@ type tagContext struct {
 	ignoreNext      bool
 }
 
-// tagHandler describes tag handler for XORM
-type tagHandler func(ctx *tagContext) error
+// TagHandler describes tag handler for XORM
+type TagHandler func(ctx *TagContext) error
+
+// TagName returns the upper case name of the tag which is being handled
+func (ctx *TagContext) TagName() string {
+	return ctx.tagName
+}
+
+// Params returns the parameters in the parentheses of the tag, e.g. the
+// parameters of index(a,b) are a and b
+func (ctx *TagContext) Params() []string {
+	return ctx.params
+}
+
+// PreTag returns the upper case previous tag of the field
+func (ctx *TagContext) PreTag() string {
+	return ctx.preTag
+}
+
+// NextTag returns the next tag of the field
+func (ctx *TagContext) NextTag() string {
+	return ctx.nextTag
+}
+
+// IgnoreNext skips the next tag, it's used when the next tag is the value of
+// the current one, e.g. default 'abc'
+func (ctx *TagContext) IgnoreNext() {
+	ctx.ignoreNext = true
+}
+
+// Table returns the table which is being mapped
+func (ctx *TagContext) Table() *phoenixormcore.Table {
+	return ctx.table
+}
+
+// Column returns the column of the field
+func (ctx *TagContext) Column() *phoenixormcore.Column {
+	return ctx.col
+}
+
+// FieldValue returns the value of the field
+func (ctx *TagContext) FieldValue() reflect.Value {
+	return ctx.fieldValue
+}
+
+// Engine returns the engine which is mapping the struct
+func (ctx *TagContext) Engine() *Engine {
+	return ctx.engine
+}
+
+// SetColumnAttr records a custom attribute of the column, it could be read by
+// Engine.ColumnAttr
+func (ctx *TagContext) SetColumnAttr(key string, value interface{}) {
+	meta := ctx.columnMeta()
+	if meta.attrs == nil {
+		meta.attrs = make(map[string]interface{})
+	}
+	meta.attrs[key] = value
+}
+
+// newTagHandlers returns a copy of the default tag handlers
+func newTagHandlers() map[string]TagHandler {
+	var handlers = make(map[string]TagHandler, len(defaultTagHandlers))
+	for name, handler := range defaultTagHandlers {
+		handlers[name] = handler
+	}
+	return handlers
+}
 
 var (
-	// defaultTagHandlers enumerates all the default tag handler
-	defaultTagHandlers = map[string]tagHandler{
-		"<-":       OnlyFromDBTagHandler,
-		"->":       OnlyToDBTagHandler,
-		"PK":       PKTagHandler,
-		"NULL":     NULLTagHandler,
-		"NOT":      IgnoreTagHandler,
-		"AUTOINCR": AutoIncrTagHandler,
-		"DEFAULT":  DefaultTagHandler,
-		"CREATED":  CreatedTagHandler,
-		"UPDATED":  UpdatedTagHandler,
-		"DELETED":  DeletedTagHandler,
-		"VERSION":  VersionTagHandler,
-		"UTC":      UTCTagHandler,
-		"LOCAL":    LocalTagHandler,
-		"NOTNULL":  NotNullTagHandler,
-		"INDEX":    IndexTagHandler,
-		"UNIQUE":   UniqueTagHandler,
-		"CACHE":    CacheTagHandler,
-		"NOCACHE":  NoCacheTagHandler,
-		"COMMENT":  CommentTagHandler,
+	// defaultTagHandlers enumerates all the default tag handler. A bare word of the
+	// tag which is a tag name is never a column name, so a column which is named
+	// as a tag should be quoted, e.g. xorm:"'tenant'". BREAKING: DELETED_BY, AUDIT,
+	// ENCRYPTED and TENANT were column names before they became tags.
+	defaultTagHandlers = map[string]TagHandler{
+		"<-":         OnlyFromDBTagHandler,
+		"->":         OnlyToDBTagHandler,
+		"PK":         PKTagHandler,
+		"NULL":       NULLTagHandler,
+		"NOT":        IgnoreTagHandler,
+		"AUTOINCR":   AutoIncrTagHandler,
+		"DEFAULT":    DefaultTagHandler,
+		"CREATED":    CreatedTagHandler,
+		"UPDATED":    UpdatedTagHandler,
+		"DELETED":    DeletedTagHandler,
+		"DELETED_BY": DeletedByTagHandler,
+		"VERSION":    VersionTagHandler,
+		"UTC":        UTCTagHandler,
+		"LOCAL":      LocalTagHandler,
+		"NOTNULL":    NotNullTagHandler,
+		"INDEX":      IndexTagHandler,
+		"UNIQUE":     UniqueTagHandler,
+		"CACHE":      CacheTagHandler,
+		"NOCACHE":    NoCacheTagHandler,
+		"COMMENT":    CommentTagHandler,
+		"AUDIT":      AuditTagHandler,
+		"ENCRYPTED":  EncryptedTagHandler,
+		"TENANT":     TenantTagHandler,
 	}
 )
 
@@ -65,43 +140,43 @@ func init() {
 }
 
 // IgnoreTagHandler describes ignored tag handler
-func IgnoreTagHandler(ctx *tagContext) error {
+func IgnoreTagHandler(ctx *TagContext) error {
 	return nil
 }
 
 // OnlyFromDBTagHandler describes mapping direction tag handler
-func OnlyFromDBTagHandler(ctx *tagContext) error {
+func OnlyFromDBTagHandler(ctx *TagContext) error {
 	ctx.col.MapType = phoenixormcore.ONLYFROMDB
 	return nil
 }
 
 // OnlyToDBTagHandler describes mapping direction tag handler
-func OnlyToDBTagHandler(ctx *tagContext) error {
+func OnlyToDBTagHandler(ctx *TagContext) error {
 	ctx.col.MapType = phoenixormcore.ONLYTODB
 	return nil
 }
 
 // PKTagHandler decribes primary key tag handler
-func PKTagHandler(ctx *tagContext) error {
+func PKTagHandler(ctx *TagContext) error {
 	ctx.col.IsPrimaryKey = true
 	ctx.col.Nullable = false
 	return nil
 }
 
 // NULLTagHandler describes null tag handler
-func NULLTagHandler(ctx *tagContext) error {
+func NULLTagHandler(ctx *TagContext) error {
 	ctx.col.Nullable = (strings.ToUpper(ctx.preTag) != "NOT")
 	return nil
 }
 
 // NotNullTagHandler describes notnull tag handler
-func NotNullTagHandler(ctx *tagContext) error {
+func NotNullTagHandler(ctx *TagContext) error {
 	ctx.col.Nullable = false
 	return nil
 }
 
 // AutoIncrTagHandler describes autoincr tag handler
-func AutoIncrTagHandler(ctx *tagContext) error {
+func AutoIncrTagHandler(ctx *TagContext) error {
 	ctx.col.IsAutoIncrement = true
 	/*
 		if len(ctx.params) > 0 {
@@ -118,7 +193,7 @@ func AutoIncrTagHandler(ctx *tagContext) error {
 }
 
 // DefaultTagHandler describes default tag handler
-func DefaultTagHandler(ctx *tagContext) error {
+func DefaultTagHandler(ctx *TagContext) error {
 	if len(ctx.params) > 0 {
 		ctx.col.Default = ctx.params[0]
 	} else {
@@ -130,26 +205,26 @@ func DefaultTagHandler(ctx *tagContext) error {
 }
 
 // CreatedTagHandler describes created tag handler
-func CreatedTagHandler(ctx *tagContext) error {
+func CreatedTagHandler(ctx *TagContext) error {
 	ctx.col.IsCreated = true
 	return nil
 }
 
 // VersionTagHandler describes version tag handler
-func VersionTagHandler(ctx *tagContext) error {
+func VersionTagHandler(ctx *TagContext) error {
 	ctx.col.IsVersion = true
 	ctx.col.Default = "1"
 	return nil
 }
 
 // UTCTagHandler describes utc tag handler
-func UTCTagHandler(ctx *tagContext) error {
+func UTCTagHandler(ctx *TagContext) error {
 	ctx.col.TimeZone = time.UTC
 	return nil
 }
 
 // LocalTagHandler describes local tag handler
-func LocalTagHandler(ctx *tagContext) error {
+func LocalTagHandler(ctx *TagContext) error {
 	if len(ctx.params) == 0 {
 		ctx.col.TimeZone = time.Local
 	} else {
@@ -163,19 +238,64 @@ func LocalTagHandler(ctx *tagContext) error {
 }
 
 // UpdatedTagHandler describes updated tag handler
-func UpdatedTagHandler(ctx *tagContext) error {
+func UpdatedTagHandler(ctx *TagContext) error {
 	ctx.col.IsUpdated = true
 	return nil
 }
 
 // DeletedTagHandler describes deleted tag handler
-func DeletedTagHandler(ctx *tagContext) error {
+func DeletedTagHandler(ctx *TagContext) error {
 	ctx.col.IsDeleted = true
+	if len(ctx.params) == 0 {
+		return nil
+	}
+
+	meta := ctx.columnMeta()
+	switch strings.ToUpper(strings.TrimSpace(ctx.params[0])) {
+	case "", "TIME":
+		meta.deletedMode = DeletedTime
+	case "BOOL":
+		meta.deletedMode = DeletedBool
+	case "UNIX":
+		meta.deletedMode = DeletedUnix
+	default:
+		return fmt.Errorf("field %s has unknown deleted mode %s", ctx.col.FieldName, ctx.params[0])
+	}
+
+	// the value of the records which are not deleted
+	if len(ctx.params) > 1 {
+		sentinel := strings.Trim(strings.TrimSpace(ctx.params[1]), "'")
+		switch meta.deletedMode {
+		case DeletedUnix:
+			n, err := strconv.ParseInt(sentinel, 10, 64)
+			if err != nil {
+				return err
+			}
+			meta.deletedSentinel = n
+		case DeletedTime:
+			meta.deletedSentinel = sentinel
+		}
+	}
+	return nil
+}
+
+// DeletedByTagHandler describes deleted_by tag handler, the column will be
+// filled with the actor of the context when the record is soft deleted. A column
+// named deleted_by without the behavior needs the tag 'deleted_by'.
+func DeletedByTagHandler(ctx *TagContext) error {
+	ctx.columnMeta().isDeletedBy = true
+	return nil
+}
+
+// AuditTagHandler describes audit tag handler, the changes of the table will
+// be audited if any of its fields has the tag, 'audit' is a plain column name
+func AuditTagHandler(ctx *TagContext) error {
+	ctx.engine.enableAudit(ctx.table.Type)
 	return nil
 }
 
 // IndexTagHandler describes index tag handler
-func IndexTagHandler(ctx *tagContext) error {
+func IndexTagHandler(ctx *TagContext) error {
 	if len(ctx.params) > 0 {
 		ctx.indexNames[ctx.params[0]] = phoenixormcore.IndexType
 	} else {
@@ -185,7 +305,7 @@ func IndexTagHandler(ctx *tagContext) error {
 }
 
 // UniqueTagHandler describes unique tag handler
-func UniqueTagHandler(ctx *tagContext) error {
+func UniqueTagHandler(ctx *TagContext) error {
 	if len(ctx.params) > 0 {
 		ctx.indexNames[ctx.params[0]] = phoenixormcore.UniqueType
 	} else {
@@ -195,7 +315,7 @@ func UniqueTagHandler(ctx *tagContext) error {
 }
 
 // CommentTagHandler add comment to column
-func CommentTagHandler(ctx *tagContext) error {
+func CommentTagHandler(ctx *TagContext) error {
 	if len(ctx.params) > 0 {
 		ctx.col.Comment = strings.Trim(ctx.params[0], "' ")
 	}
@@ -203,7 +323,7 @@ func CommentTagHandler(ctx *tagContext) error {
 }
 
 // SQLTypeTagHandler describes SQL Type tag handler
-func SQLTypeTagHandler(ctx *tagContext) error {
+func SQLTypeTagHandler(ctx *TagContext) error {
 	ctx.col.SQLType = phoenixormcore.SQLType{Name: ctx.tagName}
 	if len(ctx.params) > 0 {
 		if ctx.tagName == phoenixormcore.Enum {
@@ -243,7 +363,7 @@ func SQLTypeTagHandler(ctx *tagContext) error {
 }
 
 // ExtendsTagHandler describes extends tag handler
-func ExtendsTagHandler(ctx *tagContext) error {
+func ExtendsTagHandler(ctx *TagContext) error {
 	var fieldValue = ctx.fieldValue
 	var isPtr = false
 	switch fieldValue.Kind() {
@@ -263,6 +383,9 @@ func ExtendsTagHandler(ctx *tagContext) error {
 		if err != nil {
 			return err
 		}
+		if ctx.engine.isAudited(parentTable) {
+			ctx.engine.enableAudit(ctx.table.Type)
+		}
 		for _, col := range parentTable.Columns() {
 			col.FieldName = fmt.Sprintf("%v.%v", ctx.col.FieldName, col.FieldName)
 
@@ -295,7 +418,7 @@ func ExtendsTagHandler(ctx *tagContext) error {
 }
 
 // CacheTagHandler describes cache tag handler
-func CacheTagHandler(ctx *tagContext) error {
+func CacheTagHandler(ctx *TagContext) error {
 	if !ctx.hasCacheTag {
 		ctx.hasCacheTag = true
 	}
@@ -303,7 +426,7 @@ func CacheTagHandler(ctx *tagContext) error {
 }
 
 // NoCacheTagHandler describes nocache tag handler
-func NoCacheTagHandler(ctx *tagContext) error {
+func NoCacheTagHandler(ctx *TagContext) error {
 	if !ctx.hasNoCacheTag {
 		ctx.hasNoCacheTag = true
 	}
@@ -6,6 +6,7 @@ package xorm
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -598,3 +599,101 @@ func TestTagPrimarykey(t *testing.T) {
 	assert.True(t, col2.IsPrimaryKey)
 	assert.False(t, col2.IsAutoIncrement)
 }
+
+type TagSearchable struct {
+	Id    int64
+	Title string `xorm:"searchable(3,title) varchar(50)"`
+	Body  string `xorm:"searchable 'ignored' text"`
+}
+
+func TestRegisterTag(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type searchable struct {
+		weight  string
+		preTag  string
+		nextTag string
+	}
+	testEngine.RegisterTag("searchable", func(ctx *TagContext) error {
+		var s = searchable{
+			weight:  "1",
+			preTag:  ctx.PreTag(),
+			nextTag: ctx.NextTag(),
+		}
+		if len(ctx.Params()) > 0 {
+			s.weight = ctx.Params()[0]
+		}
+		if strings.HasPrefix(ctx.NextTag(), "'") {
+			ctx.IgnoreNext()
+		}
+		assert.EqualValues(t, "SEARCHABLE", ctx.TagName())
+		assert.NotNil(t, ctx.Table())
+		assert.NotNil(t, ctx.Engine())
+		assert.True(t, ctx.FieldValue().IsValid())
+		ctx.SetColumnAttr("searchable", s)
+		return nil
+	})
+	_, ok := defaultTagHandlers["SEARCHABLE"]
+	assert.False(t, ok)
+
+	assertSync(t, new(TagSearchable))
+
+	table := testEngine.TableInfo(new(TagSearchable))
+	colName := testEngine.GetColumnMapper().Obj2Table
+
+	title := table.GetColumn(colName("Title"))
+	assert.NotNil(t, title)
+	attr, ok := testEngine.ColumnAttr(title, "searchable")
+	assert.True(t, ok)
+	assert.EqualValues(t, searchable{weight: "3", nextTag: "varchar(50)"}, attr)
+
+	// the quoted name is skipped by IgnoreNext
+	body := table.GetColumn(colName("Body"))
+	assert.NotNil(t, body)
+	attr, ok = testEngine.ColumnAttr(body, "searchable")
+	assert.True(t, ok)
+	assert.EqualValues(t, searchable{weight: "1", nextTag: "'ignored'"}, attr)
+
+	id := table.GetColumn(colName("Id"))
+	_, ok = testEngine.ColumnAttr(id, "searchable")
+	assert.False(t, ok)
+}
+
+type TagQuotedKeywords struct {
+	Id        int64
+	Tenant    string `xorm:"'tenant'"`
+	Audit     string `xorm:"'audit'"`
+	Encrypted string `xorm:"'encrypted'"`
+	DeletedBy string `xorm:"'deleted_by'"`
+}
+
+type TagTenantKeyword struct {
+	Id     int64
+	Tenant string `xorm:"tenant"`
+}
+
+func TestTagQuotedKeywords(t *testing.T) {
+	engine, err := newMappingEngine(&ColumnsOptions{})
+	assert.NoError(t, err)
+
+	// the quoted keywords are the names of plain columns
+	table, err := engine.autoMapType(reflect.ValueOf(TagQuotedKeywords{}))
+	assert.NoError(t, err)
+	for _, name := range []string{"tenant", "audit", "encrypted", "deleted_by"} {
+		col := table.GetColumn(name)
+		if assert.NotNil(t, col, name) {
+			assert.Nil(t, engine.columnEncryption(col), name)
+		}
+	}
+	assert.Nil(t, engine.tenantColumn(table))
+	assert.Nil(t, engine.deletedByColumn(table))
+	assert.False(t, engine.isAudited(table))
+
+	// the bare keyword is a tag
+	table, err = engine.autoMapType(reflect.ValueOf(TagTenantKeyword{}))
+	assert.NoError(t, err)
+	col := engine.tenantColumn(table)
+	if assert.NotNil(t, col) {
+		assert.EqualValues(t, "tenant", col.Name)
+	}
+}
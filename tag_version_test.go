@@ -240,3 +240,106 @@ func TestVersion4(t *testing.T) {
 		}
 	}
 }
+
+func TestVersionConflict(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(VersionS))
+
+	ver := &VersionS{Name: "conflict"}
+	_, err := testEngine.Insert(ver)
+	assert.NoError(t, err)
+
+	var stale VersionS
+	has, err := testEngine.ID(ver.Id).NoCache().Get(&stale)
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	ver.Name = "first"
+	cnt, err := testEngine.ID(ver.Id).Update(ver)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, 2, ver.Ver)
+
+	stale.Name = "second"
+	cnt, err = testEngine.ID(stale.Id).Update(&stale)
+	assert.EqualValues(t, 0, cnt)
+	conflict, ok := err.(ErrVersionConflict)
+	assert.True(t, ok)
+	assert.EqualValues(t, testEngine.TableName(new(VersionS)), conflict.TableName)
+	assert.EqualValues(t, ver.Id, conflict.PK[0])
+	assert.EqualValues(t, 1, conflict.Version)
+	assert.EqualValues(t, 1, stale.Ver)
+
+	cnt, err = testEngine.Delete(&stale)
+	assert.EqualValues(t, 0, cnt)
+	_, ok = err.(ErrVersionConflict)
+	assert.True(t, ok)
+
+	cnt, err = testEngine.Delete(ver)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// the missing row is not a conflict
+	ver.Name = "missing"
+	cnt, err = testEngine.ID(ver.Id).Update(ver)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+	assert.EqualValues(t, 2, ver.Ver)
+
+	cnt, err = testEngine.Delete(ver)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+}
+
+func TestUpdateWithRetry(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(VersionS))
+
+	ver := &VersionS{Name: "retry"}
+	_, err := testEngine.Insert(ver)
+	assert.NoError(t, err)
+
+	var stale VersionS
+	has, err := testEngine.ID(ver.Id).NoCache().Get(&stale)
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	ver.Name = "updated"
+	_, err = testEngine.ID(ver.Id).Update(ver)
+	assert.NoError(t, err)
+
+	var attempts int
+	sess := testEngine.NewSession()
+	defer sess.Close()
+	cnt, err := sess.ID(stale.Id).UpdateWithRetry(&stale, func(bean interface{}) error {
+		attempts++
+		bean.(*VersionS).Name += "-retried"
+		return nil
+	}, 3)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, 2, attempts)
+	assert.EqualValues(t, 3, stale.Ver)
+	assert.EqualValues(t, "updated-retried", stale.Name)
+
+	// the conflict is returned when all the attempts fail
+	stale.Ver = 1
+	attempts = 0
+	_, err = sess.UpdateWithRetry(&stale, func(bean interface{}) error {
+		attempts++
+		bean.(*VersionS).Ver = 1
+		return nil
+	}, 2)
+	_, ok := err.(ErrVersionConflict)
+	assert.True(t, ok)
+	assert.EqualValues(t, 2, attempts)
+
+	// the row could not be reloaded without the primary keys
+	attempts = 0
+	_, err = sess.Where("name = ?", "updated-retried").UpdateWithRetry(&VersionS{Ver: 1}, func(bean interface{}) error {
+		attempts++
+		return nil
+	}, 2)
+	assert.EqualValues(t, ErrNeedPrimaryKey, err)
+	assert.EqualValues(t, 0, attempts)
+}
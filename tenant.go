@@ -0,0 +1,206 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	phoenixormbuilder "github.com/yongjacky/phoenix-go-orm-builder"
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+// TenantResolver returns the tenant of the operations from the context of the
+// session, e.g. the tenant id of the current request
+type TenantResolver func(ctx context.Context) (interface{}, error)
+
+// TenantSchemaResolver returns the schema of the tables of the tenant, the
+// tables of the tenant will not be prefixed if an empty schema is returned
+type TenantSchemaResolver func(tenant interface{}) (string, error)
+
+// TenantTagHandler describes tenant tag handler, the records of the table are
+// scoped by the column with the tenant resolved by the engine's TenantResolver.
+// The tag 'tenant' names a column which is not scoped.
+func TenantTagHandler(ctx *TagContext) error {
+	ctx.columnMeta().isTenant = true
+	return nil
+}
+
+// SetTenantResolver sets the TenantResolver of the tables with a tenant column
+// or of all the tables if a TenantSchemaResolver is set
+func (engine *Engine) SetTenantResolver(resolver TenantResolver) {
+	engine.tenantResolver = resolver
+}
+
+// SetTenantSchema routes the tables to the schemas of the tenants, the schema
+// returned by the resolver is used instead of the one set by SetSchema
+func (engine *Engine) SetTenantSchema(resolver TenantSchemaResolver) {
+	engine.tenantSchema = resolver
+}
+
+// tenantColumn returns the column which records the tenant of the record
+func (engine *Engine) tenantColumn(table *phoenixormcore.Table) *phoenixormcore.Column {
+	if table == nil {
+		return nil
+	}
+	for _, col := range table.Columns() {
+		if meta := engine.columnMeta(col); meta != nil && meta.isTenant {
+			return col
+		}
+	}
+	return nil
+}
+
+type tenantBypassContextKey struct{}
+
+// WithTenantBypass returns a context which permits the sessions to operate the
+// records of all the tenants by AllTenants
+func WithTenantBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantBypassContextKey{}, true)
+}
+
+// tenantBypassed returns true if the context permits to bypass the tenants
+func tenantBypassed(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	bypass, _ := ctx.Value(tenantBypassContextKey{}).(bool)
+	return bypass
+}
+
+// AllTenants operates the records of all the tenants, the context of the
+// session should be permitted by WithTenantBypass
+func (session *Session) AllTenants() *Session {
+	session.statement.allTenants = true
+	return session
+}
+
+// AllTenants operates the records of all the tenants, the context of the
+// session should be permitted by WithTenantBypass
+func (engine *Engine) AllTenants() *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.AllTenants()
+}
+
+// scopeTenant resolves the tenant of the operation, the records of the other
+// tenants will be filtered by statement.tenantCond and the table is routed to
+// the schema of the tenant. A nil tenant will be returned if the statement is
+// not scoped.
+func (statement *Statement) scopeTenant(addedTableName bool) (interface{}, error) {
+	engine := statement.Engine
+	col := engine.tenantColumn(statement.RefTable)
+	if col == nil && engine.tenantSchema == nil {
+		return nil, nil
+	}
+
+	var ctx = statement.session.ctx
+	if statement.allTenants {
+		if !tenantBypassed(ctx) {
+			return nil, ErrTenantBypassDenied
+		}
+		return nil, nil
+	}
+	if engine.tenantResolver == nil {
+		return nil, ErrNoTenantResolver
+	}
+	tenant, err := engine.tenantResolver(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenant == nil {
+		return nil, ErrNoTenant
+	}
+
+	if col != nil {
+		var colName = engine.Quote(col.Name)
+		if addedTableName {
+			var nm = statement.TableName()
+			if len(statement.TableAlias) > 0 {
+				nm = statement.TableAlias
+			}
+			colName = engine.Quote(nm) + "." + colName
+		}
+		statement.tenantCond = phoenixormbuilder.Eq{colName: tenant}
+	}
+
+	if engine.tenantSchema != nil && statement.fromSQL == "" {
+		schema, err := engine.tenantSchema(tenant)
+		if err != nil {
+			return nil, err
+		}
+		if statement.AltTableName != "" {
+			statement.AltTableName = engine.tbNameWithTenantSchema(statement.AltTableName, schema)
+		} else {
+			statement.tableName = engine.tbNameWithTenantSchema(statement.tableName, schema)
+		}
+	}
+	return tenant, nil
+}
+
+// tbNameWithTenantSchema replaces the schema set by SetSchema with the schema
+// of the tenant, the aliased or the tenant prefixed names are not changed
+func (engine *Engine) tbNameWithTenantSchema(v, schema string) string {
+	if schema == "" || v == "" || strings.ContainsAny(v, " `\"[") {
+		return v
+	}
+	if engineSchema := engine.dialect.URI().Schema; engineSchema != "" {
+		v = strings.TrimPrefix(v, engineSchema+".")
+	}
+	if strings.Contains(v, ".") {
+		return v
+	}
+	return schema + "." + v
+}
+
+// stampTenant sets the tenant of the bean if the field is empty, the bean of
+// another tenant could not be written
+func (session *Session) stampTenant(bean interface{}, tenant interface{}) error {
+	col := session.engine.tenantColumn(session.statement.RefTable)
+	if col == nil || tenant == nil {
+		return nil
+	}
+	fieldValue, err := col.ValueOf(bean)
+	if err != nil {
+		return err
+	}
+	if isZero(fieldValue.Interface()) {
+		setColumnValue(bean, col, tenant)
+	}
+	if value := fieldValue.Interface(); fmt.Sprint(value) != fmt.Sprint(tenant) {
+		return ErrTenantMismatch{TableName: session.statement.TableName(), Tenant: tenant, Value: value}
+	}
+	return nil
+}
+
+// stampMapTenant resolves the tenant of a map insert and sets the tenant column
+// of the map if it's empty, the map of another tenant could not be inserted
+func (session *Session) stampMapTenant(columns []string, args []interface{}) ([]string, []interface{}, error) {
+	if session.statement.RefTable == nil {
+		session.statement.RefTable = session.statement.mapTable()
+	}
+	tenant, err := session.statement.scopeTenant(false)
+	if err != nil {
+		return nil, nil, err
+	}
+	col := session.engine.tenantColumn(session.statement.RefTable)
+	if col == nil || tenant == nil {
+		return columns, args, nil
+	}
+
+	for i, name := range columns {
+		if !strings.EqualFold(name, col.Name) {
+			continue
+		}
+		if args[i] == nil || isZero(args[i]) {
+			args[i] = tenant
+		} else if fmt.Sprint(args[i]) != fmt.Sprint(tenant) {
+			return nil, nil, ErrTenantMismatch{TableName: session.statement.TableName(), Tenant: tenant, Value: args[i]}
+		}
+		return columns, args, nil
+	}
+	return append(columns, col.Name), append(args, tenant), nil
+}
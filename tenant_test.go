@@ -0,0 +1,163 @@
+// Copyright 2020 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	phoenixormcore "github.com/yongjacky/phoenix-go-orm-core"
+)
+
+type TenantNote struct {
+	Id       int64
+	TenantId int64 `xorm:"tenant index"`
+	Title    string
+}
+
+type tenantContextKey struct{}
+
+func withTestTenant(tenant int64) context.Context {
+	return context.WithValue(context.Background(), tenantContextKey{}, tenant)
+}
+
+func resolveTestTenant(ctx context.Context) (interface{}, error) {
+	return ctx.Value(tenantContextKey{}), nil
+}
+
+func TestTenantScope(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(TenantNote))
+
+	testEngine.SetTenantResolver(resolveTestTenant)
+	defer testEngine.SetTenantResolver(nil)
+
+	var ctx1, ctx2 = withTestTenant(1), withTestTenant(2)
+
+	// the tenant is stamped on insert
+	note := TenantNote{Title: "note1"}
+	cnt, err := testEngine.Context(ctx1).Insert(&note)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, 1, note.TenantId)
+
+	cnt, err = testEngine.Context(ctx2).Insert([]TenantNote{{Title: "note2"}, {Title: "note3"}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	_, err = testEngine.Context(ctx1).Insert(&TenantNote{TenantId: 2, Title: "other"})
+	assert.IsType(t, ErrTenantMismatch{}, err)
+
+	_, err = testEngine.Insert(&TenantNote{Title: "none"})
+	assert.EqualValues(t, ErrNoTenant, err)
+
+	// the tenant is stamped on the maps too
+	colMapper := testEngine.GetColumnMapper()
+	tenantCol, titleCol := colMapper.Obj2Table("TenantId"), colMapper.Obj2Table("Title")
+	_, err = testEngine.Table(new(TenantNote)).Insert(map[string]interface{}{titleCol: "none"})
+	assert.EqualValues(t, ErrNoTenant, err)
+	_, err = testEngine.Context(ctx1).Table(new(TenantNote)).Insert(map[string]interface{}{
+		tenantCol: 2,
+		titleCol:  "other",
+	})
+	assert.IsType(t, ErrTenantMismatch{}, err)
+	_, err = testEngine.Context(ctx1).Table(testEngine.TableName(new(TenantNote), true)).
+		Insert(map[string]string{titleCol: "other"})
+	assert.NoError(t, err)
+	var stamped TenantNote
+	has, err := testEngine.Context(ctx1).Where(titleCol+" = ?", "other").Get(&stamped)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 1, stamped.TenantId)
+	_, err = testEngine.Context(ctx1).Delete(&stamped)
+	assert.NoError(t, err)
+
+	// the records of the other tenants could not be read
+	var notes []TenantNote
+	assert.NoError(t, testEngine.Context(ctx2).Asc("id").Find(&notes))
+	assert.EqualValues(t, 2, len(notes))
+	assert.EqualValues(t, "note2", notes[0].Title)
+
+	var got TenantNote
+	has, err = testEngine.Context(ctx2).ID(note.Id).Get(&got)
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	has, err = testEngine.Context(ctx1).Exist(&TenantNote{Title: "note2"})
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	cnt, err = testEngine.Context(ctx1).Count(new(TenantNote))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	cnt, err = testEngine.Context(ctx2).Table(new(TenantNote)).Count()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	// the records of the other tenants could not be written
+	cnt, err = testEngine.Context(ctx2).ID(note.Id).Update(&TenantNote{Title: "changed"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	cnt, err = testEngine.Context(ctx2).ID(note.Id).Delete(new(TenantNote))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	_, err = testEngine.Context(ctx2).Delete(new(TenantNote))
+	assert.EqualValues(t, ErrNeedDeletedCond, err)
+
+	cnt, err = testEngine.Context(ctx1).ID(note.Id).Update(&TenantNote{Title: "changed"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// all the tenants could be read only by the permitted contexts
+	notes = nil
+	err = testEngine.Context(ctx1).AllTenants().Find(&notes)
+	assert.EqualValues(t, ErrTenantBypassDenied, err)
+
+	assert.NoError(t, testEngine.Context(WithTenantBypass(ctx1)).AllTenants().Asc("id").Find(&notes))
+	assert.EqualValues(t, 3, len(notes))
+	assert.EqualValues(t, "changed", notes[0].Title)
+
+	cnt, err = testEngine.Context(WithTenantBypass(context.Background())).AllTenants().Count(new(TenantNote))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, cnt)
+}
+
+func TestTenantSchema(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	if testEngine.Dialect().DBType() != phoenixormcore.SQLITE {
+		t.Skip("the schemas of the tenants are attached databases on sqlite")
+	}
+	assertSync(t, new(Userinfo))
+
+	testEngine.SetTenantResolver(resolveTestTenant)
+	testEngine.SetTenantSchema(func(tenant interface{}) (string, error) {
+		return "main", nil
+	})
+	defer func() {
+		testEngine.SetTenantResolver(nil)
+		testEngine.SetTenantSchema(nil)
+	}()
+
+	sess := testEngine.NewSession()
+	defer sess.Close()
+
+	_, err := sess.Insert(&Userinfo{Username: "none"})
+	assert.EqualValues(t, ErrNoTenant, err)
+
+	sess.Context(withTestTenant(1))
+	_, err = sess.Insert(&Userinfo{Username: "tenant"})
+	assert.NoError(t, err)
+
+	var users []Userinfo
+	assert.NoError(t, sess.Find(&users))
+	assert.EqualValues(t, 1, len(users))
+	sql, _ := sess.LastSQL()
+	assert.True(t, strings.Contains(sql, testEngine.Quote("main."+testEngine.TableName(new(Userinfo)))), sql)
+}
@@ -2,15 +2,12 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// +build go1.8
-
 package xorm
 
 import (
 	"context"
 	"fmt"
 	"os"
-	"reflect"
 	"runtime"
 	"sync"
 	"time"
@@ -88,11 +85,10 @@ func NewEngine(driverName string, dataSourceName string) (*Engine, error) {
 	engine := &Engine{
 		db:             db,
 		dialect:        dialect,
-		Tables:         make(map[reflect.Type]*phoenixormcore.Table),
 		mutex:          &sync.RWMutex{},
 		TagIdentifier:  "xorm",
 		TZLocation:     time.Local,
-		tagHandlers:    defaultTagHandlers,
+		tagHandlers:    newTagHandlers(),
 		cachers:        make(map[string]phoenixormcore.Cacher),
 		defaultContext: context.Background(),
 	}